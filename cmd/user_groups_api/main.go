@@ -1,47 +1,104 @@
-package main
-
-import (
-	"API/internal/app"
-	"API/internal/config"
-	"log"
-	"log/slog"
-
-	_ "API/docs"
-
-	"github.com/labstack/echo/v4"
-	echoSwagger "github.com/swaggo/echo-swagger"
-)
-
-// @title Dynamic User Groups API
-// @version 1.0
-// @description API documentation.
-
-// @contact.name API Support
-// @contact.email artorison@gmail.com
-
-// @host localhost:8080
-// @BasePath /
-func main() {
-
-	cfg, err := config.LoadDBConfig("config/config.yml")
-	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
-	}
-
-	container := app.InitDI(*cfg)
-
-	router := echo.New()
-	application := app.NewApp(router, container)
-
-	app.StartTTLConsumer(container.KafkaConsumer, container.UserSegmentService)
-
-	application.Router.GET("/swagger/*", echoSwagger.WrapHandler)
-	slog.Info("Swagger page: http://localhost:8080/swagger/index.html")
-	slog.Info("pgadmin: http://localhost:5050")
-
-	app.RegisterMiddleware(application.Router)
-	app.RegisterRoutes(application.Router, application.DIContainer)
-
-	application.Start(cfg.Server.Address)
-	slog.Error("Server stopped")
-}
+package main
+
+import (
+	"API/internal/app"
+	"API/internal/config"
+	"API/internal/logging"
+	"API/internal/tracing"
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	_ "API/docs"
+
+	"github.com/labstack/echo/v4"
+	echoSwagger "github.com/swaggo/echo-swagger"
+)
+
+// @title Dynamic User Groups API
+// @version 1.0
+// @description API documentation.
+
+// @contact.name API Support
+// @contact.email artorison@gmail.com
+
+// @host localhost:8080
+// @BasePath /
+
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description "Bearer <JWT or API key>"
+func main() {
+
+	cfg, err := config.LoadDBConfig("config/config.yml")
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	logger, err := logging.New(cfg.Log)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		logger.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	container := app.InitDI(*cfg, logger)
+
+	router := echo.New()
+	router.HideBanner = true
+	router.HidePort = true
+	application := app.NewApp(router, container)
+
+	application.StartConsumer(ctx, cfg.Kafka.CommandTopic)
+	application.StartExpiryWorker(ctx)
+	application.StartSegmentPurger(ctx)
+	application.StartReportCleaner(ctx)
+	application.StartJobWorkers(ctx)
+	application.StartOutboxRelay(ctx)
+
+	application.Router.GET("/swagger/*", echoSwagger.WrapHandler)
+	logger.Info("Swagger page: http://localhost:8080/swagger/index.html")
+	logger.Info("pgadmin: http://localhost:5050")
+
+	app.RegisterMiddleware(application.Router, cfg.Tracing.ServiceName, logger)
+	app.RegisterRoutes(application.Router, application.DIContainer)
+
+	go func() {
+		if err := application.Start(cfg.Server.Address); err != nil {
+			logger.Error("Server stopped", "error", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	logger.Info("Shutting down", "timeout", cfg.Shutdown.Timeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+	defer cancel()
+
+	if err := application.Shutdown(shutdownCtx, cfg.Server.Timeout); err != nil {
+		logger.Error("Shutdown was not clean", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("Failed to flush traces", "error", err)
+		return
+	}
+	logger.Info("Server stopped")
+}
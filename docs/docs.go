@@ -18,28 +18,36 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/segments": {
+        "/admin/api_keys": {
             "get": {
-                "description": "Fetches a list of all segments stored in the database.",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the stored API keys without their secrets.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Segments"
+                    "Admin"
                 ],
-                "summary": "Retrieve all segments",
+                "summary": "List API keys",
                 "responses": {
                     "200": {
-                        "description": "List of segments",
+                        "description": "API keys",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.Segments"
+                                "$ref": "#/definitions/models.APIKey"
                             }
                         }
                     },
                     "500": {
-                        "description": "Failed to retrieve segments",
+                        "description": "Failed to list API keys",
                         "schema": {
                             "$ref": "#/definitions/models.ResponseError"
                         }
@@ -47,7 +55,15 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Adds a new segment to the database.",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Generates an API key with the given scopes. The key is returned only once,\nthe service stores its hash.",
                 "consumes": [
                     "application/json"
                 ],
@@ -55,79 +71,96 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Segments"
+                    "Admin"
                 ],
-                "summary": "Create a new segment",
+                "summary": "Create an API key",
                 "parameters": [
                     {
-                        "description": "Segment data",
-                        "name": "segment",
+                        "description": "Name and scopes of the key",
+                        "name": "key",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.SegmentRequest"
+                            "$ref": "#/definitions/models.CreateAPIKeyRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Segment created successfully",
+                    "201": {
+                        "description": "Created API key",
                         "schema": {
-                            "$ref": "#/definitions/models.Response"
+                            "$ref": "#/definitions/models.CreatedAPIKey"
                         }
                     },
                     "400": {
-                        "description": "Invalid slug",
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "409": {
+                        "description": "API key name is taken",
                         "schema": {
                             "$ref": "#/definitions/models.ResponseError"
                         }
                     },
                     "500": {
-                        "description": "Failed to create segment",
+                        "description": "Failed to create API key",
                         "schema": {
                             "$ref": "#/definitions/models.ResponseError"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/admin/api_keys/{id}": {
             "delete": {
-                "description": "Deletes a segment from the system using the provided slug.",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Revokes the API key, requests with it are rejected from then on.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Segments"
+                    "Admin"
                 ],
-                "summary": "Delete a segment",
+                "summary": "Delete an API key",
                 "parameters": [
                     {
-                        "description": "Segment data",
-                        "name": "segment",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.SegmentRequest"
-                        }
+                        "type": "integer",
+                        "description": "API key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Segment deleted successfully",
+                        "description": "API key deleted",
                         "schema": {
                             "$ref": "#/definitions/models.Response"
                         }
                     },
                     "400": {
-                        "description": "Invalid slug",
+                        "description": "Invalid API key ID",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "404": {
+                        "description": "API key not found",
                         "schema": {
                             "$ref": "#/definitions/models.ResponseError"
                         }
                     },
                     "500": {
-                        "description": "Failed to delete segment",
+                        "description": "Failed to delete API key",
                         "schema": {
                             "$ref": "#/definitions/models.ResponseError"
                         }
@@ -135,36 +168,75 @@ const docTemplate = `{
                 }
             }
         },
-        "/user_segments": {
+        "/admin/dlq/{topic}": {
             "get": {
-                "description": "Fetches all user-to-segment mappings stored in the database.",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the latest messages of every partition of the \u003ctopic\u003e.dlq topic\ntogether with the processing error and the source position.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "UserSegments"
+                    "Admin"
+                ],
+                "summary": "List dead-letter messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Source topic",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Messages per partition, 50 by default, at most 500",
+                        "name": "limit",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Retrieve all user-segment relationships",
                 "responses": {
                     "200": {
-                        "description": "List of user-segment relationships",
+                        "description": "Dead-letter messages",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.UserSegment"
+                                "$ref": "#/definitions/models.DLQMessage"
                             }
                         }
                     },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
                     "500": {
-                        "description": "Failed to retrieve user segments",
+                        "description": "Failed to read dead-letter topic",
                         "schema": {
                             "$ref": "#/definitions/models.ResponseError"
                         }
                     }
                 }
-            },
-            "patch": {
-                "description": "Adds or removes segments associated with a user.",
+            }
+        },
+        "/admin/dlq/{topic}/replay": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Publishes the message at the given partition and offset of \u003ctopic\u003e.dlq back to the topic.",
                 "consumes": [
                     "application/json"
                 ],
@@ -172,23 +244,30 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "UserSegments"
+                    "Admin"
                 ],
-                "summary": "Update a user's segments",
+                "summary": "Replay a dead-letter message",
                 "parameters": [
                     {
-                        "description": "Segments to add or remove",
-                        "name": "userSegment",
+                        "type": "string",
+                        "description": "Source topic",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Dead-letter message position",
+                        "name": "message",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.UpdateSegmentsRequest"
+                            "$ref": "#/definitions/models.DLQReplayRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "User segments updated successfully",
+                        "description": "Message replayed",
                         "schema": {
                             "$ref": "#/definitions/models.Response"
                         }
@@ -199,8 +278,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ResponseError"
                         }
                     },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
                     "500": {
-                        "description": "Failed to update user segments",
+                        "description": "Failed to replay message",
                         "schema": {
                             "$ref": "#/definitions/models.ResponseError"
                         }
@@ -208,91 +293,145 @@ const docTemplate = `{
                 }
             }
         },
-        "/user_segments/history/{user_id}": {
+        "/healthz": {
             "get": {
-                "description": "Generate a CSV file containing the user's segment history for a specific month.",
+                "description": "Returns 200 while the process is able to serve HTTP requests. Dependencies are not checked.",
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "UserSegmentHistory"
+                    "Health"
                 ],
-                "summary": "Generate User History CSV",
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "Process is alive",
+                        "schema": {
+                            "$ref": "#/definitions/models.HealthReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the status, progress and, once it succeeded, the result of a background job.\nOnly the principal that submitted the job or an admin may see it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Jobs"
+                ],
+                "summary": "Get a background job",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
-                        "name": "user_id",
+                        "description": "Job ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Year-Month in YYYY-MM format",
-                        "name": "date",
-                        "in": "query",
-                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "URL to the generated CSV file",
+                        "description": "Job",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.Job"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid job ID",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "403": {
+                        "description": "Job of another principal",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "Failed to get the job",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.ResponseError"
                         }
                     }
                 }
-            }
-        },
-        "/user_segments/{user_id}": {
-            "get": {
-                "description": "Retrieves all segments associated with a user by user ID.",
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Cancels a queued job at once. A running job is asked to stop and is cancelled at\nits next heartbeat; work it has done until then is kept. Only the principal that\nsubmitted the job or an admin may cancel it.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "UserSegments"
+                    "Jobs"
                 ],
-                "summary": "Get segments for a user",
+                "summary": "Cancel a background job",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
-                        "name": "user_id",
+                        "description": "Job ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "List of user segments",
+                        "description": "Cancelled job, or running job asked to stop",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.UserSegment"
-                            }
+                            "$ref": "#/definitions/models.Job"
                         }
                     },
                     "400": {
-                        "description": "Invalid user ID",
+                        "description": "Invalid job ID",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "403": {
+                        "description": "Job of another principal",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "409": {
+                        "description": "Job already finished",
                         "schema": {
                             "$ref": "#/definitions/models.ResponseError"
                         }
                     },
                     "500": {
-                        "description": "Failed to retrieve user segments",
+                        "description": "Failed to cancel the job",
                         "schema": {
                             "$ref": "#/definitions/models.ResponseError"
                         }
@@ -300,72 +439,87 @@ const docTemplate = `{
                 }
             }
         },
-        "/users": {
+        "/readyz": {
             "get": {
-                "description": "Retrieves all users from the database.",
+                "description": "Checks Postgres, Kafka broker reachability from the producer and the consumer\nand the consumer lag. Fails while the service is shutting down.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Health"
                 ],
-                "summary": "Get all users",
+                "summary": "Readiness probe",
                 "responses": {
                     "200": {
-                        "description": "List of users",
+                        "description": "All dependencies are up",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Users"
-                            }
+                            "$ref": "#/definitions/models.HealthReport"
                         }
                     },
-                    "400": {
-                        "description": "Failed to retrieve users",
+                    "503": {
+                        "description": "A dependency is down or the service is shutting down",
                         "schema": {
-                            "$ref": "#/definitions/models.ResponseError"
+                            "$ref": "#/definitions/models.HealthReport"
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Adds a new user to the database.",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/reports/{name}": {
+            "get": {
+                "description": "Downloads a stored report through the signed link returned when it was generated.\nThe link is its own credential and stops working when it expires.",
                 "produces": [
+                    "text/csv",
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Reports"
                 ],
-                "summary": "Create a new user",
+                "summary": "Download a report",
                 "parameters": [
                     {
-                        "description": "User data",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.Users"
-                        }
+                        "type": "string",
+                        "description": "Report file name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiry of the link as a Unix time",
+                        "name": "expires",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature of the link",
+                        "name": "signature",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "User created successfully",
+                        "description": "Report",
                         "schema": {
-                            "$ref": "#/definitions/models.Response"
+                            "type": "string"
                         }
                     },
-                    "400": {
-                        "description": "Invalid JSON payload",
+                    "403": {
+                        "description": "Invalid or expired link",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "404": {
+                        "description": "Report not found",
                         "schema": {
                             "$ref": "#/definitions/models.ResponseError"
                         }
                     },
                     "500": {
-                        "description": "Failed to create user",
+                        "description": "Failed to read the report",
                         "schema": {
                             "$ref": "#/definitions/models.ResponseError"
                         }
@@ -373,12 +527,1179 @@ const docTemplate = `{
                 }
             }
         },
-        "/users/{id}": {
-            "delete": {
-                "description": "Removes a user from the database by ID.",
-                "consumes": [
-                    "application/json"
-                ],
+        "/segments": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Fetches a list of all segments stored in the database, optionally filtered by tag,\nowner and whether the current time falls into their active period.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Retrieve all segments",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only segments with this tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only segments with this owner",
+                        "name": "owner",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only segments inside (true) or outside (false) their active period",
+                        "name": "active",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of segments",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Segments"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve segments",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Adds a new segment to the database. When auto_percent is set, that share of\nexisting and future users is enrolled into the segment automatically.\nThe segment is owned by owner or, when it is omitted, by the caller.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Create a new segment",
+                "parameters": [
+                    {
+                        "description": "Segment data",
+                        "name": "segment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SegmentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Segment created successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid slug, auto_percent, tags or active period",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to create segment",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Archives the segment with the provided slug. An archived segment is hidden from\nthe segment list and its memberships cannot be changed, but they are kept until\nthe segment is restored or purged after the retention period.\nWith purge=true the segment, archived earlier or now, is also deleted with its memberships by a background job.\nOnly the owner of the segment or an admin may delete it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Delete a segment",
+                "parameters": [
+                    {
+                        "description": "Segment data",
+                        "name": "segment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SegmentRequest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Delete the segment and its memberships in the background",
+                        "name": "purge",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Segment deleted successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.Response"
+                        }
+                    },
+                    "202": {
+                        "description": "Segment archived, purge job accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.Job"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the purge job"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid slug",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller does not manage the segment",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "404": {
+                        "description": "Segment not found or already archived",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete segment",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            }
+        },
+        "/segments/{slug}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the description, owner, tags and lifecycle dates of the segment.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Get a segment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Segment slug",
+                        "name": "slug",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Segment",
+                        "schema": {
+                            "$ref": "#/definitions/models.Segments"
+                        }
+                    },
+                    "404": {
+                        "description": "Segment not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get segment",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Changes the description, owner, tags or active period of the segment. Omitted\nfields are kept, tags are replaced as a whole. Only the owner of the segment or\nan admin may change it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Update segment metadata",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Segment slug",
+                        "name": "slug",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "segment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SegmentPatch"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated segment",
+                        "schema": {
+                            "$ref": "#/definitions/models.Segments"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller does not manage the segment",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "404": {
+                        "description": "Segment not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update segment",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            }
+        },
+        "/segments/{slug}/acl": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the owner and the writers of the segment.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Get the ACL of a segment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Segment slug",
+                        "name": "slug",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Segment ACL",
+                        "schema": {
+                            "$ref": "#/definitions/models.SegmentACL"
+                        }
+                    },
+                    "404": {
+                        "description": "Segment not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get segment ACL",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Sets the owner and the writers of the segment. The owner manages the segment,\nwriters may only add and remove its members. Only the current owner or an admin\nmay change the ACL; a segment without owner and writers is open to everyone.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Replace the ACL of a segment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Segment slug",
+                        "name": "slug",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Owner and writers",
+                        "name": "acl",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SegmentACLRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated segment ACL",
+                        "schema": {
+                            "$ref": "#/definitions/models.SegmentACL"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller does not manage the segment",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "404": {
+                        "description": "Segment not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to set segment ACL",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            }
+        },
+        "/segments/{slug}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Restores a segment archived by DELETE /segments together with its memberships.\nOnly the owner of the segment or an admin may restore it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Restore an archived segment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Segment slug",
+                        "name": "slug",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Segment restored successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller does not manage the segment",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "404": {
+                        "description": "Archived segment not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to restore segment",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            }
+        },
+        "/segments/{slug}/users": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns members of a segment ordered by user ID using cursor pagination.\nPass next_cursor from the previous page as cursor to get the next one.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "UserSegments"
+                ],
+                "summary": "Get users of a segment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Segment slug",
+                        "name": "slug",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Return users with ID greater than cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 100 by default, at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include membership TTL",
+                        "name": "include_ttl",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return only the number of members",
+                        "name": "count_only",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Segment members",
+                        "schema": {
+                            "$ref": "#/definitions/models.SegmentUsers"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "404": {
+                        "description": "Segment not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve segment users",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            }
+        },
+        "/user_segments": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Fetches all user-to-segment mappings stored in the database.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "UserSegments"
+                ],
+                "summary": "Retrieve all user-segment relationships",
+                "responses": {
+                    "200": {
+                        "description": "List of user-segment relationships",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.UserSegment"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve user segments",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Adds or removes segments associated with a user and reports the outcome of every\nslug: added, already_member, removed, not_member, unknown_segment or archived.\nIn strict mode (default) an unknown or archived segment rejects the whole update\nwith 422; in best_effort mode the other slugs are still applied.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "UserSegments"
+                ],
+                "summary": "Update a user's segments",
+                "parameters": [
+                    {
+                        "description": "Segments to add or remove",
+                        "name": "userSegment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateSegmentsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User segments updated successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.UpdateSegmentsResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload or overlapping slugs",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller may not change a segment",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unknown or archived segments in strict mode",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.UpdateSegmentsResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update user segments",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            }
+        },
+        "/user_segments/bulk": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Adds and removes the same segments for many users in the background and returns the job.\nWith a JSON body the users are listed in user_ids. With an application/x-ndjson body\n({\"user_id\": N} per line) or a text/csv body (user ID in the first column, optional\nuser_id header) the other fields are passed as query parameters.\nPoll the background job at the Location header, or the bulk job by its ID; rows that\nfailed are listed by the error report.",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "UserSegments"
+                ],
+                "summary": "Update the segments of many users",
+                "parameters": [
+                    {
+                        "description": "Users and segments",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.BulkMembershipRequest"
+                        }
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Segments to add, for NDJSON and CSV bodies",
+                        "name": "add_segments",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Segments to delete, for NDJSON and CSV bodies",
+                        "name": "delete_segments",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "TTL of the added memberships, for NDJSON and CSV bodies",
+                        "name": "ttl",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Reason recorded in the history, for NDJSON and CSV bodies",
+                        "name": "reason",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Job accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.BulkJob"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the background job"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller may not change a segment",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported body type",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unknown or archived segments",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to start the job",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            }
+        },
+        "/user_segments/bulk/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the status and progress of a bulk membership job. Only its submitter or an admin may read it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "UserSegments"
+                ],
+                "summary": "Get a bulk membership job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job",
+                        "schema": {
+                            "$ref": "#/definitions/models.BulkJob"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid job ID",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "403": {
+                        "description": "Job submitted by another principal",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get the job",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            }
+        },
+        "/user_segments/bulk/{id}/errors": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Streams the rows that failed so far as CSV with the columns row, user_id and error.\nOnly the submitter of the job or an admin may read it.",
+                "produces": [
+                    "text/csv"
+                ],
+                "tags": [
+                    "UserSegments"
+                ],
+                "summary": "Get the error report of a bulk membership job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Error report",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid job ID",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "403": {
+                        "description": "Job submitted by another principal",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to read the error report",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            }
+        },
+        "/user_segments/history/{user_id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Generate a CSV file containing the user's segment history for a specific month.\nBy default the report is stored and a download link that expires is returned. With stream=true the CSV is\nstreamed as the response body instead. With async=true the report is stored by a\nbackground job; its result holds the URL.",
+                "produces": [
+                    "text/plain",
+                    "text/csv",
+                    "application/json"
+                ],
+                "tags": [
+                    "UserSegmentHistory"
+                ],
+                "summary": "Generate User History CSV",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Year-Month in YYYY-MM format",
+                        "name": "date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Stream the CSV as the response body",
+                        "name": "stream",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Generate the report in the background",
+                        "name": "async",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "URL to the generated CSV file, or the CSV with stream=true",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "202": {
+                        "description": "Report job accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.Job"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the job"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user_segments/{user_id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieves all segments associated with a user by user ID.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "UserSegments"
+                ],
+                "summary": "Get segments for a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of user segments",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.UserSegment"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid user ID",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve user segments",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            }
+        },
+        "/users": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieves all users from the database.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get all users",
+                "responses": {
+                    "200": {
+                        "description": "List of users",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Users"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Failed to retrieve users",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Adds a new user to the database.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Create a new user",
+                "parameters": [
+                    {
+                        "description": "User data",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Users"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User created successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid JSON payload",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to create user",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Removes a user from the database by ID.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
@@ -394,31 +1715,525 @@ const docTemplate = `{
                         "in": "path",
                         "required": true
                     }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "User deleted successfully",
-                        "schema": {
-                            "$ref": "#/definitions/models.Response"
-                        }
-                    },
-                    "400": {
-                        "description": "Invalid user ID",
-                        "schema": {
-                            "$ref": "#/definitions/models.ResponseError"
-                        }
-                    },
-                    "500": {
-                        "description": "Failed to delete user",
-                        "schema": {
-                            "$ref": "#/definitions/models.ResponseError"
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User deleted successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid user ID",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete user",
+                        "schema": {
+                            "$ref": "#/definitions/models.ResponseError"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "models.APIKey": {
+            "description": "API key without the secret.",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "description": "Creation time",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "API key ID",
+                    "type": "integer"
+                },
+                "last_used_at": {
+                    "description": "Last successful authentication, updated at most once a minute",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Unique name, used as the principal subject",
+                    "type": "string",
+                    "example": "billing"
+                },
+                "prefix": {
+                    "description": "First characters of the key, to recognize it",
+                    "type": "string",
+                    "example": "ugk_3f9a"
+                },
+                "scopes": {
+                    "description": "Granted scopes",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Scope"
+                    },
+                    "example": [
+                        "memberships:write"
+                    ]
+                }
+            }
+        },
+        "models.BulkJob": {
+            "description": "Bulk membership job and its progress.",
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "description": "Principal that submitted the job",
+                    "type": "string"
+                },
+                "add_segments": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delete_segments": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "error": {
+                    "description": "Why a failed job stopped",
+                    "type": "string"
+                },
+                "failed_rows": {
+                    "description": "Rows listed in the error report",
+                    "type": "integer",
+                    "example": 3
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "job_id": {
+                    "description": "Background job applying the rows",
+                    "type": "integer",
+                    "example": 43
+                },
+                "processed_rows": {
+                    "type": "integer",
+                    "example": 125000
+                },
+                "progress": {
+                    "description": "Percentage of processed rows",
+                    "type": "number",
+                    "example": 25
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "description": "Request that submitted the job",
+                    "type": "string"
+                },
+                "status": {
+                    "enum": [
+                        "pending",
+                        "running",
+                        "done",
+                        "failed"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.BulkJobStatus"
+                        }
+                    ],
+                    "example": "running"
+                },
+                "total_rows": {
+                    "type": "integer",
+                    "example": 500000
+                },
+                "ttl": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.BulkJobStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "running",
+                "done",
+                "failed"
+            ],
+            "x-enum-comments": {
+                "BulkJobDone": "Every row was processed",
+                "BulkJobFailed": "Stopped by an error, see Error",
+                "BulkJobPending": "Waiting for a worker",
+                "BulkJobRunning": "Rows are being applied"
+            },
+            "x-enum-varnames": [
+                "BulkJobPending",
+                "BulkJobRunning",
+                "BulkJobDone",
+                "BulkJobFailed"
+            ]
+        },
+        "models.BulkMembershipRequest": {
+            "description": "Request payload for a bulk membership update.",
+            "type": "object",
+            "properties": {
+                "add_segments": {
+                    "description": "Segments to add",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "VOICE_MESSAGES"
+                    ]
+                },
+                "delete_segments": {
+                    "description": "Segments to delete",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "CHAT_SUPPORT"
+                    ]
+                },
+                "reason": {
+                    "description": "Reason recorded in the history",
+                    "type": "string",
+                    "example": "spring campaign"
+                },
+                "ttl": {
+                    "description": "TTL of the added memberships, default NULL",
+                    "type": "string"
+                },
+                "user_ids": {
+                    "description": "Users to update",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1000,
+                        1001
+                    ]
+                }
+            }
+        },
+        "models.CreateAPIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "description": "Unique name of the key",
+                    "type": "string",
+                    "example": "billing"
+                },
+                "scopes": {
+                    "description": "Granted scopes",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Scope"
+                    },
+                    "example": [
+                        "segments:write",
+                        "reports:read"
+                    ]
+                }
+            }
+        },
+        "models.CreatedAPIKey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "description": "Creation time",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "API key ID",
+                    "type": "integer"
+                },
+                "key": {
+                    "description": "Secret to send in the X-API-Key header",
+                    "type": "string",
+                    "example": "ugk_3f9a..."
+                },
+                "last_used_at": {
+                    "description": "Last successful authentication, updated at most once a minute",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Unique name, used as the principal subject",
+                    "type": "string",
+                    "example": "billing"
+                },
+                "prefix": {
+                    "description": "First characters of the key, to recognize it",
+                    "type": "string",
+                    "example": "ugk_3f9a"
+                },
+                "scopes": {
+                    "description": "Granted scopes",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Scope"
+                    },
+                    "example": [
+                        "memberships:write"
+                    ]
+                }
+            }
+        },
+        "models.DLQMessage": {
+            "description": "Message stored in a dead-letter topic.",
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "Number of processing attempts",
+                    "type": "integer"
+                },
+                "error": {
+                    "description": "Last processing error",
+                    "type": "string"
+                },
+                "key": {
+                    "description": "Original message key",
+                    "type": "string"
+                },
+                "offset": {
+                    "description": "Offset in the dead-letter topic",
+                    "type": "integer"
+                },
+                "partition": {
+                    "description": "Partition in the dead-letter topic",
+                    "type": "integer"
+                },
+                "source_offset": {
+                    "description": "Offset the message was consumed from",
+                    "type": "integer"
+                },
+                "source_partition": {
+                    "description": "Partition the message was consumed from",
+                    "type": "integer"
+                },
+                "source_topic": {
+                    "description": "Topic the message was consumed from",
+                    "type": "string"
+                },
+                "timestamp": {
+                    "description": "Time the message was dead-lettered",
+                    "type": "string"
+                },
+                "topic": {
+                    "description": "Dead-letter topic",
+                    "type": "string"
+                },
+                "value": {
+                    "description": "Original message value",
+                    "type": "string"
+                }
+            }
+        },
+        "models.DLQReplayRequest": {
+            "type": "object",
+            "properties": {
+                "offset": {
+                    "description": "Offset in the dead-letter topic",
+                    "type": "integer",
+                    "example": 42
+                },
+                "partition": {
+                    "description": "Partition in the dead-letter topic",
+                    "type": "integer",
+                    "example": 0
+                }
+            }
+        },
+        "models.DependencyHealth": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Why the dependency is down",
+                    "type": "string"
+                },
+                "lag": {
+                    "description": "Uncommitted messages, consumer only",
+                    "type": "integer"
+                },
+                "latency_ms": {
+                    "description": "Duration of the check in milliseconds",
+                    "type": "number"
+                },
+                "status": {
+                    "description": "\"up\" or \"down\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.HealthReport": {
+            "type": "object",
+            "properties": {
+                "dependencies": {
+                    "description": "Keyed by dependency name",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.DependencyHealth"
+                    }
+                },
+                "error": {
+                    "description": "Set when the service is shutting down",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "\"up\" when every dependency is up",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Job": {
+            "description": "Background job and its progress.",
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "description": "Principal that submitted the job",
+                    "type": "string"
+                },
+                "attempts": {
+                    "description": "Attempts started so far",
+                    "type": "integer",
+                    "example": 1
+                },
+                "cancel_requested": {
+                    "description": "The running attempt is asked to stop",
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "description": "Error of the last failed attempt",
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "history_report"
+                },
+                "max_attempts": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "payload": {
+                    "description": "Input of the job, depends on the kind",
+                    "type": "object"
+                },
+                "progress": {
+                    "description": "Percentage reported by the running attempt",
+                    "type": "number",
+                    "example": 25
+                },
+                "request_id": {
+                    "description": "Request that submitted the job",
+                    "type": "string"
+                },
+                "result": {
+                    "description": "Output of a succeeded job, depends on the kind",
+                    "type": "object"
+                },
+                "run_at": {
+                    "description": "Earliest start of the next attempt",
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "enum": [
+                        "queued",
+                        "running",
+                        "succeeded",
+                        "failed",
+                        "cancelled"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.JobStatus"
                         }
-                    }
+                    ],
+                    "example": "running"
+                },
+                "updated_at": {
+                    "type": "string"
                 }
             }
-        }
-    },
-    "definitions": {
+        },
+        "models.JobStatus": {
+            "type": "string",
+            "enum": [
+                "queued",
+                "running",
+                "succeeded",
+                "failed",
+                "cancelled"
+            ],
+            "x-enum-comments": {
+                "JobCancelled": "Cancelled through the API",
+                "JobFailed": "Every attempt failed, see Error",
+                "JobQueued": "Waiting for a worker, or for the next attempt",
+                "JobRunning": "Claimed by a worker",
+                "JobSucceeded": "Finished, see Result"
+            },
+            "x-enum-varnames": [
+                "JobQueued",
+                "JobRunning",
+                "JobSucceeded",
+                "JobFailed",
+                "JobCancelled"
+            ]
+        },
+        "models.MembershipOutcome": {
+            "type": "string",
+            "enum": [
+                "added",
+                "already_member",
+                "removed",
+                "not_member",
+                "unknown_segment",
+                "archived"
+            ],
+            "x-enum-comments": {
+                "OutcomeAdded": "The user joined the segment",
+                "OutcomeAlreadyMember": "The user was a member, only the TTL was set",
+                "OutcomeArchived": "The segment is archived",
+                "OutcomeNotMember": "The user was not a member",
+                "OutcomeRemoved": "The user left the segment",
+                "OutcomeUnknownSegment": "The segment does not exist"
+            },
+            "x-enum-varnames": [
+                "OutcomeAdded",
+                "OutcomeAlreadyMember",
+                "OutcomeRemoved",
+                "OutcomeNotMember",
+                "OutcomeUnknownSegment",
+                "OutcomeArchived"
+            ]
+        },
         "models.Response": {
             "description": "Standard response structure.",
             "type": "object",
@@ -450,27 +2265,349 @@ const docTemplate = `{
                 }
             }
         },
+        "models.Scope": {
+            "type": "string",
+            "enum": [
+                "users:write",
+                "segments:write",
+                "memberships:write",
+                "reports:read",
+                "admin"
+            ],
+            "x-enum-varnames": [
+                "ScopeUsersWrite",
+                "ScopeSegmentsWrite",
+                "ScopeMembershipsWrite",
+                "ScopeReportsRead",
+                "ScopeAdmin"
+            ]
+        },
+        "models.SegmentACL": {
+            "description": "Access control list of a segment.",
+            "type": "object",
+            "properties": {
+                "owner": {
+                    "description": "Owner team or principal",
+                    "type": "string",
+                    "example": "growth"
+                },
+                "slug": {
+                    "description": "Segment slug",
+                    "type": "string",
+                    "example": "DISCOUNT_30"
+                },
+                "writers": {
+                    "description": "Principals or teams allowed to change members",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "billing"
+                    ]
+                }
+            }
+        },
+        "models.SegmentACLRequest": {
+            "type": "object",
+            "properties": {
+                "owner": {
+                    "description": "Owner team or principal, none when omitted",
+                    "type": "string",
+                    "example": "growth"
+                },
+                "writers": {
+                    "description": "Principals or teams allowed to change members",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "billing"
+                    ]
+                }
+            }
+        },
+        "models.SegmentExpiryAction": {
+            "type": "string",
+            "enum": [
+                "archive",
+                "delete"
+            ],
+            "x-enum-comments": {
+                "SegmentExpiryArchive": "Archive the segment",
+                "SegmentExpiryDelete": "Delete the segment"
+            },
+            "x-enum-varnames": [
+                "SegmentExpiryArchive",
+                "SegmentExpiryDelete"
+            ]
+        },
+        "models.SegmentMember": {
+            "description": "Member of a segment with an optional membership TTL.",
+            "type": "object",
+            "properties": {
+                "ttl": {
+                    "description": "Membership expiry, only when requested",
+                    "type": "string"
+                },
+                "user_id": {
+                    "description": "User's unique ID",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.SegmentPatch": {
+            "type": "object",
+            "properties": {
+                "active_from": {
+                    "description": "start of the active period",
+                    "type": "string",
+                    "format": "date-time",
+                    "example": "2024-01-01T00:00:00Z"
+                },
+                "active_until": {
+                    "description": "end of the active period, exclusive",
+                    "type": "string",
+                    "format": "date-time",
+                    "example": "2024-02-01T00:00:00Z"
+                },
+                "description": {
+                    "description": "what the segment is for, empty to clear",
+                    "type": "string",
+                    "example": "30% discount for new customers"
+                },
+                "expires_at": {
+                    "description": "when the segment expires",
+                    "type": "string",
+                    "format": "date-time",
+                    "example": "2024-03-01T00:00:00Z"
+                },
+                "expiry_action": {
+                    "description": "archive or delete on expiry",
+                    "enum": [
+                        "archive",
+                        "delete"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SegmentExpiryAction"
+                        }
+                    ],
+                    "example": "archive"
+                },
+                "owner": {
+                    "description": "owner team or principal",
+                    "type": "string",
+                    "example": "growth"
+                },
+                "remove_active_from": {
+                    "description": "clear the start of the active period",
+                    "type": "boolean"
+                },
+                "remove_active_until": {
+                    "description": "clear the end of the active period",
+                    "type": "boolean"
+                },
+                "remove_expiry": {
+                    "description": "cancel the expiry",
+                    "type": "boolean"
+                },
+                "tags": {
+                    "description": "replaces all tags",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "promo"
+                    ]
+                }
+            }
+        },
         "models.SegmentRequest": {
             "type": "object",
             "properties": {
+                "active_from": {
+                    "description": "start of the active period",
+                    "type": "string",
+                    "format": "date-time",
+                    "example": "2024-01-01T00:00:00Z"
+                },
+                "active_until": {
+                    "description": "end of the active period, exclusive",
+                    "type": "string",
+                    "format": "date-time",
+                    "example": "2024-02-01T00:00:00Z"
+                },
+                "auto_percent": {
+                    "description": "share of users enrolled automatically, [0.01, 100]",
+                    "type": "number",
+                    "example": 10
+                },
+                "description": {
+                    "description": "what the segment is for",
+                    "type": "string",
+                    "example": "30% discount for new customers"
+                },
+                "expires_at": {
+                    "description": "when the segment expires",
+                    "type": "string",
+                    "format": "date-time",
+                    "example": "2024-03-01T00:00:00Z"
+                },
+                "expiry_action": {
+                    "description": "archive (default) or delete on expiry",
+                    "enum": [
+                        "archive",
+                        "delete"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SegmentExpiryAction"
+                        }
+                    ],
+                    "example": "archive"
+                },
+                "owner": {
+                    "description": "owner team or principal, the creator by default",
+                    "type": "string",
+                    "example": "growth"
+                },
                 "slug": {
                     "description": "segment name",
                     "type": "string",
                     "example": "DISCOUNT_30"
+                },
+                "tags": {
+                    "description": "free-form tags",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "promo"
+                    ]
+                }
+            }
+        },
+        "models.SegmentUsers": {
+            "description": "Page of users belonging to a segment.",
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "Total number of members, only in count mode",
+                    "type": "integer"
+                },
+                "next_cursor": {
+                    "description": "Cursor for the next page, absent on the last page",
+                    "type": "integer"
+                },
+                "slug": {
+                    "description": "Segment slug",
+                    "type": "string"
+                },
+                "users": {
+                    "description": "Members on this page",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SegmentMember"
+                    }
                 }
             }
         },
         "models.Segments": {
             "type": "object",
             "properties": {
+                "active_from": {
+                    "type": "string"
+                },
+                "active_until": {
+                    "type": "string"
+                },
+                "auto_percent": {
+                    "type": "number"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "30% discount for new customers"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "expiry_action": {
+                    "enum": [
+                        "archive",
+                        "delete"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SegmentExpiryAction"
+                        }
+                    ],
+                    "example": "archive"
+                },
                 "id": {
                     "type": "integer"
                 },
+                "owner": {
+                    "type": "string"
+                },
                 "slug": {
                     "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "promo"
+                    ]
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SlugOutcome": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "add or delete",
+                    "type": "string",
+                    "example": "add"
+                },
+                "outcome": {
+                    "description": "What happened",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.MembershipOutcome"
+                        }
+                    ],
+                    "example": "added"
+                },
+                "slug": {
+                    "description": "Segment slug",
+                    "type": "string",
+                    "example": "DISCOUNT_30"
                 }
             }
         },
+        "models.UpdateMode": {
+            "type": "string",
+            "enum": [
+                "strict",
+                "best_effort"
+            ],
+            "x-enum-varnames": [
+                "ModeStrict",
+                "ModeBestEffort"
+            ]
+        },
         "models.UpdateSegmentsRequest": {
             "description": "Request payload for updating a user's associated segments.",
             "type": "object",
@@ -495,6 +2632,24 @@ const docTemplate = `{
                         "[\"CHAT_SUPPORT\"]"
                     ]
                 },
+                "mode": {
+                    "description": "Mode is strict (default) to reject the whole update when a segment is\nunknown or archived, or best_effort to apply the remaining slugs.",
+                    "enum": [
+                        "strict",
+                        "best_effort"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.UpdateMode"
+                        }
+                    ],
+                    "example": "strict"
+                },
+                "reason": {
+                    "description": "Reason recorded in the history",
+                    "type": "string",
+                    "example": "trial ended"
+                },
                 "ttl": {
                     "description": "TTL default NULL",
                     "type": "string"
@@ -506,6 +2661,37 @@ const docTemplate = `{
                 }
             }
         },
+        "models.UpdateSegmentsResult": {
+            "description": "Per-slug outcome of a membership update.",
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "description": "Whether the changes were saved",
+                    "type": "boolean"
+                },
+                "mode": {
+                    "description": "Mode the update ran in",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.UpdateMode"
+                        }
+                    ],
+                    "example": "strict"
+                },
+                "results": {
+                    "description": "One entry per requested slug",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SlugOutcome"
+                    }
+                },
+                "user_id": {
+                    "description": "User's unique ID",
+                    "type": "integer",
+                    "example": 123
+                }
+            }
+        },
         "models.UserSegment": {
             "description": "Model representing a relationship between a user and a segment.",
             "type": "object",
@@ -533,6 +2719,19 @@ const docTemplate = `{
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "\"Bearer \u003cJWT or API key\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
@@ -1,26 +1,134 @@
-package app
-
-import (
-	"log"
-
-	"github.com/labstack/echo/v4"
-)
-
-type App struct {
-	Router      *echo.Echo
-	DIContainer *DIContainer
-}
-
-func NewApp(router *echo.Echo, container *DIContainer) *App {
-	return &App{
-		Router:      router,
-		DIContainer: container,
-	}
-}
-
-func (a *App) Start(address string) {
-	log.Printf("Starting server at http://localhost%s/", address)
-	if err := a.Router.Start(address); err != nil {
-		log.Fatalf("Could not start server: %v", err)
-	}
-}
+package app
+
+import (
+	"API/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type App struct {
+	Router      *echo.Echo
+	DIContainer *DIContainer
+
+	background sync.WaitGroup
+}
+
+func NewApp(router *echo.Echo, container *DIContainer) *App {
+	return &App{
+		Router:      router,
+		DIContainer: container,
+	}
+}
+
+// Start serves HTTP requests until the server fails or is shut down. A server
+// stopped by Shutdown returns nil.
+func (a *App) Start(address string) error {
+	a.DIContainer.Logger.Info("Starting server", "address", address)
+	if err := a.Router.Start(address); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("could not start server: %w", err)
+	}
+	return nil
+}
+
+// StartConsumer consumes the TTL expiry and command topics until ctx is
+// cancelled. The message being processed at that moment is finished first.
+func (a *App) StartConsumer(ctx context.Context, commandTopic string) {
+	consumer := a.DIContainer.KafkaConsumer
+	service := a.DIContainer.UserSegmentService
+
+	consumer.Handle(models.TopicSegmentExpiry, service.ProcessTTLExpiryMessage)
+	consumer.Handle(commandTopic, service.ProcessCommandMessage)
+	a.goBackground(func() { consumer.Run(ctx) })
+}
+
+func (a *App) StartExpiryWorker(ctx context.Context) {
+	worker := a.DIContainer.ExpiryWorker
+	if worker == nil {
+		a.DIContainer.Logger.Info("TTL expiry worker is disabled")
+		return
+	}
+	a.goBackground(func() { worker.Run(ctx) })
+}
+
+func (a *App) StartSegmentPurger(ctx context.Context) {
+	purger := a.DIContainer.SegmentPurger
+	if purger == nil {
+		a.DIContainer.Logger.Info("Archived segment purger is disabled")
+		return
+	}
+	a.goBackground(func() { purger.Run(ctx) })
+}
+
+func (a *App) StartReportCleaner(ctx context.Context) {
+	cleaner := a.DIContainer.ReportCleaner
+	if cleaner == nil {
+		a.DIContainer.Logger.Info("Report cleaner is disabled")
+		return
+	}
+	a.goBackground(func() { cleaner.Run(ctx) })
+}
+
+// StartJobWorkers runs background jobs until ctx is cancelled. Running jobs
+// are interrupted and queued again on shutdown.
+func (a *App) StartJobWorkers(ctx context.Context) {
+	queue := a.DIContainer.JobQueue
+
+	queue.Register(models.JobBulkMembership, a.DIContainer.BulkService.ProcessBulkJob)
+	queue.OnFailure(models.JobBulkMembership, a.DIContainer.BulkService.FailBulkJob)
+	queue.Register(models.JobHistoryReport, a.DIContainer.UserSegmentHistoryService.ProcessHistoryReport)
+	queue.OnRead(models.JobHistoryReport, a.DIContainer.UserSegmentHistoryService.PresentHistoryReport)
+	queue.Register(models.JobSegmentPurge, a.DIContainer.SegmentService.ProcessSegmentPurge)
+	a.goBackground(func() { queue.Run(ctx) })
+}
+
+func (a *App) StartOutboxRelay(ctx context.Context) {
+	relay := a.DIContainer.OutboxRelay
+	a.goBackground(func() { relay.Run(ctx) })
+}
+
+func (a *App) goBackground(fn func()) {
+	a.background.Add(1)
+	go func() {
+		defer a.background.Done()
+		fn()
+	}()
+}
+
+// Shutdown fails the readiness probe, drains in-flight HTTP requests for at
+// most httpTimeout, waits for
+// the background workers, whose context must already be cancelled, and then
+// closes the Kafka clients and the database pool. Waiting stops when ctx
+// expires; the connections are closed in any case.
+func (a *App) Shutdown(ctx context.Context, httpTimeout time.Duration) error {
+	a.DIContainer.HealthService.SetShuttingDown()
+
+	var errs []error
+
+	httpCtx, cancel := context.WithTimeout(ctx, httpTimeout)
+	defer cancel()
+	if err := a.Router.Shutdown(httpCtx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to drain HTTP requests: %w", err))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		a.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("background workers did not stop: %w", ctx.Err()))
+	}
+
+	if err := a.DIContainer.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -1,143 +1,330 @@
-package app
-
-import (
-	"API/internal/config"
-	"API/internal/database"
-	"API/internal/handlers"
-	"API/internal/kafka"
-	"API/internal/repository"
-	"API/internal/services"
-	"log"
-)
-
-type DIContainer struct {
-	DB *database.Database
-
-	UserService               *services.UserService
-	UserHandler               *handlers.UserHandler
-	SegmentService            *services.SegmentService
-	SegmentHandler            *handlers.SegmentHandler
-	UserSegmentService        *services.UserSegmentService
-	UserSegmentHandler        *handlers.UserSegmentHandler
-	UserSegmentHistoryService *services.UserSegmentHistoryService
-	UserSegmentHistoryHandler *handlers.UserSegmentHistoryHandler
-
-	KafkaProducer *kafka.Producer
-	KafkaConsumer *kafka.Consumer
-}
-
-func InitDI(cfg config.AppConfig) *DIContainer {
-	db := initDatabase(cfg)
-
-	producer, consumer := initKafka(cfg)
-
-	userRepo, segmentRepo, userSegmentRepo, userSegmentHistoryRepo := initRepositories(db)
-
-	userService, segmentService, userSegmentService, userSegmentHistoryService := initServices(
-		userRepo,
-		segmentRepo,
-		userSegmentRepo,
-		userSegmentHistoryRepo,
-		producer,
-	)
-
-	userHandler, segmentHandler, userSegmentHandler, userSegmentHistoryHandler := initHandlers(
-		userService,
-		segmentService,
-		userSegmentService,
-		userSegmentHistoryService,
-	)
-
-	return &DIContainer{
-		DB:                        db,
-		UserService:               userService,
-		UserHandler:               userHandler,
-		SegmentService:            segmentService,
-		SegmentHandler:            segmentHandler,
-		UserSegmentService:        userSegmentService,
-		UserSegmentHandler:        userSegmentHandler,
-		UserSegmentHistoryService: userSegmentHistoryService,
-		UserSegmentHistoryHandler: userSegmentHistoryHandler,
-		KafkaProducer:             producer,
-		KafkaConsumer:             consumer,
-	}
-}
-
-func initDatabase(cfg config.AppConfig) *database.Database {
-	db, err := database.NewDBConnection(cfg)
-	if err != nil {
-		log.Fatalf("Could not connect to database: %v", err)
-	}
-	return db
-}
-
-func initKafka(cfg config.AppConfig) (*kafka.Producer, *kafka.Consumer) {
-	producer, err := kafka.NewProducer(cfg.Kafka.Brokers)
-	if err != nil {
-		log.Fatal("Could not initialize Kafka producer: ", err)
-	}
-
-	consumer, err := kafka.NewConsumer(cfg.Kafka.Brokers)
-	if err != nil {
-		log.Fatal("Could not initialize Kafka consumer: ", err)
-	}
-
-	return producer, consumer
-}
-
-func StartTTLConsumer(consumer *kafka.Consumer, service *services.UserSegmentService) {
-	go consumer.ConsumeMessages("segment_expiry", service.ProcessTTLExpiryMessage)
-}
-
-func initRepositories(db *database.Database) (
-	repository.UserRepository,
-	repository.SegmentRepository,
-	repository.UserSegmentRepository,
-	repository.UserSegmentHistoryRepository,
-) {
-	userRepo := repository.NewUserRepository(db.DB)
-	segmentRepo := repository.NewSegmentRepository(db.DB)
-	userSegmentHistoryRepo := repository.NewUserSegmentHistoryRepository(db.DB)
-	userSegmentRepo := repository.NewUserSegmentRepository(db.DB, userRepo, segmentRepo, userSegmentHistoryRepo)
-
-	return userRepo, segmentRepo, userSegmentRepo, userSegmentHistoryRepo
-}
-
-func initServices(
-	userRepo repository.UserRepository,
-	segmentRepo repository.SegmentRepository,
-	userSegmentRepo repository.UserSegmentRepository,
-	userSegmentHistoryRepo repository.UserSegmentHistoryRepository,
-	producer *kafka.Producer,
-) (
-	*services.UserService,
-	*services.SegmentService,
-	*services.UserSegmentService,
-	*services.UserSegmentHistoryService,
-) {
-	userService := services.NewUserService(userRepo)
-	segmentService := services.NewSegmentService(segmentRepo)
-	userSegmentService := services.NewUserSegmentService(userSegmentRepo, userSegmentHistoryRepo, producer)
-	userSegmentHistoryService := services.NewUserSegmentHistoryService(userSegmentHistoryRepo)
-
-	return userService, segmentService, userSegmentService, userSegmentHistoryService
-}
-
-func initHandlers(
-	userService *services.UserService,
-	segmentService *services.SegmentService,
-	userSegmentService *services.UserSegmentService,
-	userSegmentHistoryService *services.UserSegmentHistoryService,
-) (
-	*handlers.UserHandler,
-	*handlers.SegmentHandler,
-	*handlers.UserSegmentHandler,
-	*handlers.UserSegmentHistoryHandler,
-) {
-	userHandler := handlers.NewUserHandler(userService)
-	segmentHandler := handlers.NewSegmentHandler(segmentService)
-	userSegmentHandler := handlers.NewUserSegmentHandler(userSegmentService)
-	userSegmentHistoryHandler := handlers.NewUserSegmentHistoryHandler(userSegmentHistoryService)
-
-	return userHandler, segmentHandler, userSegmentHandler, userSegmentHistoryHandler
-}
+package app
+
+import (
+	"API/internal/auth"
+	"API/internal/config"
+	"API/internal/database"
+	"API/internal/handlers"
+	"API/internal/kafka"
+	"API/internal/metrics"
+	"API/internal/repository"
+	"API/internal/services"
+	"API/internal/storage"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+type DIContainer struct {
+	DB     *database.Database
+	Logger *slog.Logger
+
+	UserService               *services.UserService
+	UserHandler               *handlers.UserHandler
+	SegmentService            *services.SegmentService
+	SegmentHandler            *handlers.SegmentHandler
+	UserSegmentService        *services.UserSegmentService
+	UserSegmentHandler        *handlers.UserSegmentHandler
+	UserSegmentHistoryService *services.UserSegmentHistoryService
+	UserSegmentHistoryHandler *handlers.UserSegmentHistoryHandler
+	ReportHandler             *handlers.ReportHandler
+	BulkService               *services.BulkMembershipService
+	BulkHandler               *handlers.BulkHandler
+	JobQueue                  *services.JobQueue
+	JobHandler                *handlers.JobHandler
+	DLQService                *services.DLQService
+	DLQHandler                *handlers.DLQHandler
+	HealthService             *services.HealthService
+	HealthHandler             *handlers.HealthHandler
+	AuthService               *services.AuthService
+	APIKeyHandler             *handlers.APIKeyHandler
+	AuthEnabled               bool
+	ExpiryWorker              *services.ExpiryWorker
+	SegmentPurger             *services.SegmentPurger
+	ReportCleaner             *services.ReportCleaner
+	OutboxRelay               *services.OutboxRelay
+
+	KafkaProducer *kafka.Producer
+	KafkaConsumer *kafka.Consumer
+	KafkaDLQ      *kafka.DLQ
+}
+
+func InitDI(cfg config.AppConfig, logger *slog.Logger) *DIContainer {
+	db := initDatabase(cfg, logger)
+
+	producer, consumer, dlq := initKafka(cfg, logger)
+
+	userRepo, segmentRepo, userSegmentRepo, userSegmentHistoryRepo, outboxRepo := initRepositories(db, logger)
+
+	jobRepo := repository.NewJobRepository(db.DB)
+	jobQueue := services.NewJobQueue(
+		jobRepo,
+		workerID(),
+		cfg.Jobs.Workers,
+		cfg.Jobs.Interval,
+		cfg.Jobs.Lease,
+		cfg.Jobs.Backoff,
+		cfg.Jobs.MaxAttempts,
+		cfg.Jobs.Retention,
+		logger,
+	)
+	jobHandler := handlers.NewJobHandler(jobQueue)
+
+	reports, reportHandler := initReports(cfg, logger)
+
+	userService, segmentService, userSegmentService, userSegmentHistoryService := initServices(
+		cfg,
+		logger,
+		jobQueue,
+		reports,
+		userRepo,
+		segmentRepo,
+		userSegmentRepo,
+		userSegmentHistoryRepo,
+		outboxRepo,
+	)
+
+	userHandler, segmentHandler, userSegmentHandler, userSegmentHistoryHandler := initHandlers(
+		userService,
+		segmentService,
+		userSegmentService,
+		userSegmentHistoryService,
+	)
+
+	bulkService := services.NewBulkMembershipService(
+		repository.NewBulkJobRepository(db.DB, userSegmentHistoryRepo, outboxRepo, jobRepo),
+		segmentRepo,
+		jobQueue,
+		cfg.Bulk.ChunkSize,
+		cfg.Bulk.MaxRows,
+		logger,
+	)
+	bulkHandler := handlers.NewBulkHandler(bulkService)
+
+	dlqService := services.NewDLQService(dlq)
+	dlqHandler := handlers.NewDLQHandler(dlqService)
+
+	authService := initAuth(cfg, db, logger)
+	apiKeyHandler := handlers.NewAPIKeyHandler(authService)
+
+	registerMetrics(db, logger)
+
+	healthService := services.NewHealthService(db, producer, consumer, cfg.Health.Timeout, cfg.Health.MaxConsumerLag)
+	healthHandler := handlers.NewHealthHandler(healthService)
+
+	var expiryWorker *services.ExpiryWorker
+	if cfg.TTLSweeper.Enabled {
+		expiryWorker = services.NewExpiryWorker(userSegmentRepo, segmentRepo, cfg.TTLSweeper.Interval, cfg.TTLSweeper.BatchSize, logger)
+	}
+
+	var segmentPurger *services.SegmentPurger
+	if cfg.SegmentPurge.Enabled {
+		segmentPurger = services.NewSegmentPurger(segmentRepo, cfg.SegmentPurge.Interval, cfg.SegmentPurge.Retention, cfg.SegmentPurge.BatchSize, logger)
+	}
+
+	var reportCleaner *services.ReportCleaner
+	if cfg.Reports.Retention > 0 {
+		reportCleaner = services.NewReportCleaner(reports, cfg.Reports.Interval, cfg.Reports.Retention, logger)
+	}
+
+	outboxRelay := services.NewOutboxRelay(
+		outboxRepo,
+		producer,
+		cfg.Outbox.Interval,
+		cfg.Outbox.MaxBackoff,
+		cfg.Outbox.Retention,
+		cfg.Outbox.BatchSize,
+		logger,
+	)
+
+	return &DIContainer{
+		DB:                        db,
+		Logger:                    logger,
+		UserService:               userService,
+		UserHandler:               userHandler,
+		SegmentService:            segmentService,
+		SegmentHandler:            segmentHandler,
+		UserSegmentService:        userSegmentService,
+		UserSegmentHandler:        userSegmentHandler,
+		UserSegmentHistoryService: userSegmentHistoryService,
+		UserSegmentHistoryHandler: userSegmentHistoryHandler,
+		ReportHandler:             reportHandler,
+		BulkService:               bulkService,
+		BulkHandler:               bulkHandler,
+		JobQueue:                  jobQueue,
+		JobHandler:                jobHandler,
+		DLQService:                dlqService,
+		DLQHandler:                dlqHandler,
+		HealthService:             healthService,
+		HealthHandler:             healthHandler,
+		AuthService:               authService,
+		APIKeyHandler:             apiKeyHandler,
+		AuthEnabled:               cfg.Auth.Enabled,
+		ExpiryWorker:              expiryWorker,
+		SegmentPurger:             segmentPurger,
+		ReportCleaner:             reportCleaner,
+		OutboxRelay:               outboxRelay,
+		KafkaProducer:             producer,
+		KafkaConsumer:             consumer,
+		KafkaDLQ:                  dlq,
+	}
+}
+
+// fatal logs err and exits, for dependencies the service cannot start without.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// workerID names this replica in the leases of the jobs it runs.
+func workerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+func initDatabase(cfg config.AppConfig, logger *slog.Logger) *database.Database {
+	db, err := database.NewDBConnection(cfg)
+	if err != nil {
+		fatal(logger, "Could not connect to database", err)
+	}
+	return db
+}
+
+// initReports returns the report storage and, for the local storage, the
+// handler that serves its signed links.
+func initReports(cfg config.AppConfig, logger *slog.Logger) (storage.ReportStorage, *handlers.ReportHandler) {
+	reports, err := storage.NewReportStorage(cfg.Reports)
+	if err != nil {
+		fatal(logger, "Could not initialize report storage", err)
+	}
+	local, ok := reports.(*storage.LocalReportStorage)
+	if !ok {
+		return reports, nil
+	}
+	if cfg.Reports.SigningKey == "" {
+		logger.Warn("Report signing key is not set, report links only work on this replica until it restarts")
+	}
+	return reports, handlers.NewReportHandler(local)
+}
+
+func initAuth(cfg config.AppConfig, db *database.Database, logger *slog.Logger) *services.AuthService {
+	verifier, err := auth.NewJWTVerifier(cfg.Auth.JWT)
+	if err != nil {
+		fatal(logger, "Could not initialize JWT verification", err)
+	}
+	if !cfg.Auth.Enabled {
+		logger.Warn("Authentication is disabled, every endpoint is open")
+	}
+	return services.NewAuthService(repository.NewAPIKeyRepository(db.DB), verifier, cfg.Auth.BootstrapKey)
+}
+
+// registerMetrics adds the collectors that read the database on every scrape.
+func registerMetrics(db *database.Database, logger *slog.Logger) {
+	metrics.Registry.MustRegister(
+		collectors.NewDBStatsCollector(db.DB, "postgres"),
+		metrics.NewStatsCollector(repository.NewStatsRepository(db.DB), logger),
+	)
+}
+
+func initKafka(cfg config.AppConfig, logger *slog.Logger) (*kafka.Producer, *kafka.Consumer, *kafka.DLQ) {
+	producer, err := kafka.NewProducer(cfg.Kafka.Brokers, logger)
+	if err != nil {
+		fatal(logger, "Could not initialize Kafka producer", err)
+	}
+
+	retry := kafka.RetryPolicy{
+		MaxAttempts:    cfg.Kafka.Retry.MaxAttempts,
+		InitialBackoff: cfg.Kafka.Retry.InitialBackoff,
+		MaxBackoff:     cfg.Kafka.Retry.MaxBackoff,
+	}
+
+	consumer, err := kafka.NewConsumer(cfg.Kafka.Brokers, cfg.Kafka.GroupID, retry, producer, logger)
+	if err != nil {
+		fatal(logger, "Could not initialize Kafka consumer", err)
+	}
+
+	dlq, err := kafka.NewDLQ(cfg.Kafka.Brokers, producer, logger)
+	if err != nil {
+		fatal(logger, "Could not initialize Kafka dead-letter reader", err)
+	}
+
+	return producer, consumer, dlq
+}
+
+// Close releases the Kafka clients and the database pool. The consumer is
+// closed first so its offsets are committed, and the producer last among the
+// Kafka clients so pending messages are flushed.
+func (c *DIContainer) Close() error {
+	c.KafkaConsumer.Close()
+	c.KafkaDLQ.Close()
+	c.KafkaProducer.Close()
+
+	if err := c.DB.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	return nil
+}
+
+func initRepositories(db *database.Database, logger *slog.Logger) (
+	repository.UserRepository,
+	repository.SegmentRepository,
+	repository.UserSegmentRepository,
+	repository.UserSegmentHistoryRepository,
+	repository.OutboxRepository,
+) {
+	userRepo := repository.NewUserRepository(db.DB, logger)
+	segmentRepo := repository.NewSegmentRepository(db.DB, logger)
+	userSegmentHistoryRepo := repository.NewUserSegmentHistoryRepository(db.DB)
+	outboxRepo := repository.NewOutboxRepository(db.DB)
+	userSegmentRepo := repository.NewUserSegmentRepository(db.DB, userRepo, segmentRepo, userSegmentHistoryRepo, outboxRepo)
+
+	return userRepo, segmentRepo, userSegmentRepo, userSegmentHistoryRepo, outboxRepo
+}
+
+func initServices(
+	cfg config.AppConfig,
+	logger *slog.Logger,
+	jobQueue services.IJobQueue,
+	reports storage.ReportStorage,
+	userRepo repository.UserRepository,
+	segmentRepo repository.SegmentRepository,
+	userSegmentRepo repository.UserSegmentRepository,
+	userSegmentHistoryRepo repository.UserSegmentHistoryRepository,
+	outboxRepo repository.OutboxRepository,
+) (
+	*services.UserService,
+	*services.SegmentService,
+	*services.UserSegmentService,
+	*services.UserSegmentHistoryService,
+) {
+	userService := services.NewUserService(userRepo, userSegmentRepo)
+	segmentService := services.NewSegmentService(segmentRepo, jobQueue, logger)
+	userSegmentService := services.NewUserSegmentService(userSegmentRepo, segmentRepo, outboxRepo, cfg.Kafka.ReplyTopic, logger)
+	userSegmentHistoryService := services.NewUserSegmentHistoryService(userSegmentHistoryRepo, reports, jobQueue)
+
+	return userService, segmentService, userSegmentService, userSegmentHistoryService
+}
+
+func initHandlers(
+	userService *services.UserService,
+	segmentService *services.SegmentService,
+	userSegmentService *services.UserSegmentService,
+	userSegmentHistoryService *services.UserSegmentHistoryService,
+) (
+	*handlers.UserHandler,
+	*handlers.SegmentHandler,
+	*handlers.UserSegmentHandler,
+	*handlers.UserSegmentHistoryHandler,
+) {
+	userHandler := handlers.NewUserHandler(userService)
+	segmentHandler := handlers.NewSegmentHandler(segmentService)
+	userSegmentHandler := handlers.NewUserSegmentHandler(userSegmentService)
+	userSegmentHistoryHandler := handlers.NewUserSegmentHistoryHandler(userSegmentHistoryService)
+
+	return userHandler, segmentHandler, userSegmentHandler, userSegmentHistoryHandler
+}
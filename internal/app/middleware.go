@@ -1,20 +1,161 @@
-package app
-
-import (
-	"os"
-
-	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
-)
-
-func RegisterMiddleware(e *echo.Echo) {
-	e.Use(middleware.Recover())
-
-	MWLogCfg := middleware.LoggerWithConfig(middleware.LoggerConfig{
-		Format: "time=${time_rfc3339}, method=${method}, uri=${uri}, status=${status}\n",
-		Output: os.Stdout,
-	})
-
-	e.Use(MWLogCfg)
-
-}
+package app
+
+import (
+	"API/internal/auth"
+	"API/internal/logging"
+	"API/internal/metrics"
+	"API/internal/models"
+	"API/internal/services"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+func RegisterMiddleware(e *echo.Echo, serviceName string, logger *slog.Logger) {
+	e.Use(middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		RequestIDHandler: func(c echo.Context, requestID string) {
+			ctx := logging.WithRequestID(c.Request().Context(), requestID)
+			c.SetRequest(c.Request().WithContext(ctx))
+		},
+	}))
+	e.Use(middleware.Recover())
+	e.Use(otelecho.Middleware(serviceName, otelecho.WithSkipper(isProbe)))
+	e.Use(requestLogger(logger))
+	e.Use(metricsMiddleware)
+}
+
+// requestLogger logs every request except probes with the request ID, so the
+// line can be matched with the lines logged while serving it.
+func requestLogger(logger *slog.Logger) echo.MiddlewareFunc {
+	return middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		Skipper:     isProbe,
+		LogMethod:   true,
+		LogURI:      true,
+		LogStatus:   true,
+		LogLatency:  true,
+		LogRemoteIP: true,
+		LogError:    true,
+		HandleError: true,
+		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
+			level := slog.LevelInfo
+			if v.Status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			attrs := []slog.Attr{
+				slog.String("method", v.Method),
+				slog.String("uri", v.URI),
+				slog.Int("status", v.Status),
+				slog.Duration("latency", v.Latency),
+				slog.String("remote_ip", v.RemoteIP),
+			}
+			if principal, ok := auth.PrincipalFrom(c.Request().Context()); ok {
+				attrs = append(attrs, slog.String("subject", principal.Subject))
+			}
+			if v.Error != nil {
+				attrs = append(attrs, slog.String("error", v.Error.Error()))
+			}
+			logger.LogAttrs(c.Request().Context(), level, "Request served", attrs...)
+			return nil
+		},
+	})
+}
+
+// authenticate resolves the principal of the request from the X-API-Key
+// header or the Authorization bearer token and stores it in the request
+// context. Requests without valid credentials get 401.
+func authenticate(service services.IAuthService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			var (
+				principal models.Principal
+				err       error
+			)
+			if key := c.Request().Header.Get(apiKeyHeader); key != "" {
+				principal, err = service.AuthenticateAPIKey(ctx, key)
+			} else if token, ok := bearerToken(c.Request()); ok {
+				principal, err = service.AuthenticateToken(ctx, token)
+			} else {
+				err = fmt.Errorf("%w: no credentials", models.ErrUnauthenticated)
+			}
+
+			if errors.Is(err, models.ErrUnauthenticated) {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return c.JSON(http.StatusUnauthorized, models.ResponseErr("authentication required", err))
+			}
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to authenticate", err))
+			}
+
+			c.SetRequest(c.Request().WithContext(auth.WithPrincipal(ctx, principal)))
+			return next(c)
+		}
+	}
+}
+
+// requireScope rejects requests whose principal lacks scope with 403. It must
+// follow authenticate.
+func requireScope(scope models.Scope) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, _ := auth.PrincipalFrom(c.Request().Context())
+			if !principal.HasScope(scope) {
+				return c.JSON(http.StatusForbidden, models.ResponseErr(fmt.Sprintf("scope %s is required", scope)))
+			}
+			return next(c)
+		}
+	}
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get(echo.HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// isProbe reports whether the request comes from a health check or a metrics
+// scrape, which are not traced.
+func isProbe(c echo.Context) bool {
+	switch c.Path() {
+	case "/healthz", "/readyz", "/metrics":
+		return true
+	}
+	return false
+}
+
+// metricsMiddleware counts requests and observes their latency by route
+// template, so /users/1 and /users/2 share the /users/:id series.
+func metricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+
+		err := next(c)
+		if err != nil {
+			// Let the error handler write the response to know its status.
+			c.Error(err)
+		}
+
+		route := c.Path()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Response().Status)
+
+		metrics.HTTPRequests.WithLabelValues(c.Request().Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request().Method, route, status).Observe(time.Since(start).Seconds())
+		return nil
+	}
+}
@@ -1,40 +1,106 @@
-package app
-
-import (
-	"github.com/labstack/echo/v4"
-)
-
-func RegisterRoutes(router *echo.Echo, container *DIContainer) {
-	usersRoutes(router, container)
-	segmentsRoutes(router, container)
-	userSegmentsRoutes(router, container)
-	RegisterStaticFiles(router)
-
-}
-
-func usersRoutes(router *echo.Echo, container *DIContainer) {
-	users := router.Group("users")
-	users.GET("", container.UserHandler.GetAllUsers)
-	users.POST("", container.UserHandler.CreateUser)
-	users.DELETE("/:id", container.UserHandler.DeleteUser)
-}
-
-func segmentsRoutes(router *echo.Echo, container *DIContainer) {
-	segments := router.Group("/segments")
-	segments.GET("", container.SegmentHandler.GetAllSegments)
-	segments.POST("", container.SegmentHandler.CreateSegment)
-	segments.DELETE("", container.SegmentHandler.DeleteSegment)
-}
-
-func userSegmentsRoutes(router *echo.Echo, container *DIContainer) {
-	userSegments := router.Group("/user_segments")
-	userSegments.GET("/:user_id", container.UserSegmentHandler.GetUserSegments)
-	userSegments.GET("", container.UserSegmentHandler.GetAllUserSegments)
-	userSegments.PATCH("", container.UserSegmentHandler.UpdateUserSegments)
-	userSegments.GET("/history/:user_id", container.UserSegmentHistoryHandler.GenerateHistoryCSV)
-
-}
-
-func RegisterStaticFiles(router *echo.Echo) {
-	router.Static("/csv_reports", "./csv_reports")
-}
+package app
+
+import (
+	"API/internal/metrics"
+	"API/internal/models"
+	"API/internal/storage"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func RegisterRoutes(router *echo.Echo, container *DIContainer) {
+	usersRoutes(router, container)
+	segmentsRoutes(router, container)
+	userSegmentsRoutes(router, container)
+	jobsRoutes(router, container)
+	adminRoutes(router, container)
+	healthRoutes(router, container)
+	reportsRoutes(router, container)
+
+}
+
+func usersRoutes(router *echo.Echo, container *DIContainer) {
+	users := router.Group("users", authenticated(container))
+	users.GET("", container.UserHandler.GetAllUsers)
+	users.POST("", container.UserHandler.CreateUser, scoped(container, models.ScopeUsersWrite))
+	users.DELETE("/:id", container.UserHandler.DeleteUser, scoped(container, models.ScopeUsersWrite))
+}
+
+func segmentsRoutes(router *echo.Echo, container *DIContainer) {
+	segments := router.Group("/segments", authenticated(container))
+	segments.GET("", container.SegmentHandler.GetAllSegments)
+	segments.POST("", container.SegmentHandler.CreateSegment, scoped(container, models.ScopeSegmentsWrite))
+	segments.DELETE("", container.SegmentHandler.DeleteSegment, scoped(container, models.ScopeSegmentsWrite))
+	segments.GET("/:slug", container.SegmentHandler.GetSegment)
+	segments.PATCH("/:slug", container.SegmentHandler.UpdateSegment, scoped(container, models.ScopeSegmentsWrite))
+	segments.POST("/:slug/restore", container.SegmentHandler.RestoreSegment, scoped(container, models.ScopeSegmentsWrite))
+	segments.GET("/:slug/users", container.UserSegmentHandler.GetSegmentUsers)
+	segments.GET("/:slug/acl", container.SegmentHandler.GetSegmentACL)
+	segments.PUT("/:slug/acl", container.SegmentHandler.SetSegmentACL, scoped(container, models.ScopeSegmentsWrite))
+}
+
+func userSegmentsRoutes(router *echo.Echo, container *DIContainer) {
+	userSegments := router.Group("/user_segments", authenticated(container))
+	userSegments.GET("/:user_id", container.UserSegmentHandler.GetUserSegments)
+	userSegments.GET("", container.UserSegmentHandler.GetAllUserSegments)
+	userSegments.PATCH("", container.UserSegmentHandler.UpdateUserSegments, scoped(container, models.ScopeMembershipsWrite))
+	userSegments.GET("/history/:user_id", container.UserSegmentHistoryHandler.GenerateHistoryCSV, scoped(container, models.ScopeReportsRead))
+	userSegments.POST("/bulk", container.BulkHandler.SubmitBulkJob, scoped(container, models.ScopeMembershipsWrite))
+	userSegments.GET("/bulk/:id", container.BulkHandler.GetBulkJob)
+	userSegments.GET("/bulk/:id/errors", container.BulkHandler.GetBulkJobErrors)
+
+}
+
+func jobsRoutes(router *echo.Echo, container *DIContainer) {
+	jobs := router.Group("/jobs", authenticated(container))
+	jobs.GET("/:id", container.JobHandler.GetJob)
+	jobs.DELETE("/:id", container.JobHandler.CancelJob)
+}
+
+func adminRoutes(router *echo.Echo, container *DIContainer) {
+	admin := router.Group("/admin", authenticated(container), scoped(container, models.ScopeAdmin))
+	admin.GET("/dlq/:topic", container.DLQHandler.ListMessages)
+	admin.POST("/dlq/:topic/replay", container.DLQHandler.Replay)
+	admin.GET("/api_keys", container.APIKeyHandler.ListAPIKeys)
+	admin.POST("/api_keys", container.APIKeyHandler.CreateAPIKey)
+	admin.DELETE("/api_keys/:id", container.APIKeyHandler.DeleteAPIKey)
+}
+
+func healthRoutes(router *echo.Echo, container *DIContainer) {
+	router.GET("/healthz", container.HealthHandler.Liveness)
+	router.GET("/readyz", container.HealthHandler.Readiness)
+	router.GET("/metrics", echo.WrapHandler(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})))
+}
+
+// reportsRoutes serves reports of the local report storage. Their signed
+// links are the credential, so they can be handed to a browser or another
+// service; reports of the S3 storage are downloaded from S3.
+func reportsRoutes(router *echo.Echo, container *DIContainer) {
+	if container.ReportHandler == nil {
+		return
+	}
+	router.GET(storage.LocalURLPrefix+"/:name", container.ReportHandler.DownloadReport)
+}
+
+// authenticated returns the middleware that requires valid credentials, or
+// one that lets every request through when authentication is disabled.
+func authenticated(container *DIContainer) echo.MiddlewareFunc {
+	if !container.AuthEnabled {
+		return passThrough
+	}
+	return authenticate(container.AuthService)
+}
+
+// scoped returns the middleware that requires scope, or one that lets every
+// request through when authentication is disabled.
+func scoped(container *DIContainer, scope models.Scope) echo.MiddlewareFunc {
+	if !container.AuthEnabled {
+		return passThrough
+	}
+	return requireScope(scope)
+}
+
+func passThrough(next echo.HandlerFunc) echo.HandlerFunc {
+	return next
+}
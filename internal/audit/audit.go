@@ -0,0 +1,46 @@
+// Package audit carries the source and reason of a membership change in the
+// context, so every history row written for the change records them together
+// with the acting principal.
+package audit
+
+import (
+	"API/internal/auth"
+	"API/internal/models"
+	"context"
+)
+
+type sourceKey struct{}
+
+type reasonKey struct{}
+
+// WithSource returns ctx whose changes come from source.
+func WithSource(ctx context.Context, source models.HistorySource) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// Source returns the source of changes made under ctx. Changes not tagged
+// otherwise come from the HTTP API.
+func Source(ctx context.Context) models.HistorySource {
+	if source, ok := ctx.Value(sourceKey{}).(models.HistorySource); ok {
+		return source
+	}
+	return models.SourceAPI
+}
+
+// WithReason returns ctx whose changes are explained by reason.
+func WithReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, reasonKey{}, reason)
+}
+
+// Reason returns the reason of changes made under ctx or an empty string.
+func Reason(ctx context.Context) string {
+	reason, _ := ctx.Value(reasonKey{}).(string)
+	return reason
+}
+
+// Actor returns the subject of the principal of ctx, empty for changes
+// without an authenticated caller.
+func Actor(ctx context.Context) string {
+	principal, _ := auth.PrincipalFrom(ctx)
+	return principal.Subject
+}
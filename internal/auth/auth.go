@@ -0,0 +1,56 @@
+// Package auth verifies API keys and JWT bearer tokens and carries the
+// authenticated principal in the request context.
+package auth
+
+import (
+	"API/internal/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix starts every generated API key, so a bearer token can be told
+// apart from a JWT.
+const APIKeyPrefix = "ugk_"
+
+// apiKeyDisplayLength is the length of the stored key prefix shown in listings.
+const apiKeyDisplayLength = len(APIKeyPrefix) + 6
+
+type principalKey struct{}
+
+// WithPrincipal returns ctx carrying the authenticated principal.
+func WithPrincipal(ctx context.Context, principal models.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom returns the principal of ctx and whether the request was
+// authenticated.
+func PrincipalFrom(ctx context.Context) (models.Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(models.Principal)
+	return principal, ok
+}
+
+// GenerateAPIKey returns a new random API key and its display prefix.
+func GenerateAPIKey() (key, prefix string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, key[:apiKeyDisplayLength], nil
+}
+
+// HashAPIKey returns the stored form of key. API keys carry 256 random bits,
+// so a plain SHA-256 cannot be brute-forced and allows lookup by hash.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey reports whether a bearer token is an API key rather than a JWT.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"API/internal/config"
+	"API/internal/models"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, err := GenerateAPIKey()
+
+	assert.NoError(t, err)
+	assert.True(t, IsAPIKey(key))
+	assert.Equal(t, key[:len(prefix)], prefix)
+	assert.NotEqual(t, key, HashAPIKey(key))
+	assert.Equal(t, HashAPIKey(key), HashAPIKey(key))
+}
+
+func TestJWTVerifier_HMAC(t *testing.T) {
+	verifier, err := NewJWTVerifier(config.JWTConfig{HMACSecret: "secret", Issuer: "issuer"})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	sign := func(claims jwt.MapClaims, secret string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatalf("Setup failed: %v", err)
+		}
+		return token
+	}
+	expires := time.Now().Add(time.Hour).Unix()
+
+	t.Run("should return subject and known scopes", func(t *testing.T) {
+		principal, err := verifier.Verify(sign(jwt.MapClaims{
+			"sub":    "billing",
+			"iss":    "issuer",
+			"exp":    expires,
+			"scope":  "segments:write unknown",
+			"scopes": []string{"reports:read"},
+		}, "secret"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.Principal{
+			Subject: "billing",
+			Method:  models.AuthMethodJWT,
+			Scopes:  []models.Scope{models.ScopeSegmentsWrite, models.ScopeReportsRead},
+		}, principal)
+	})
+
+	t.Run("should reject wrong signature", func(t *testing.T) {
+		_, err := verifier.Verify(sign(jwt.MapClaims{"sub": "billing", "iss": "issuer", "exp": expires}, "other"))
+		assert.Error(t, err)
+	})
+
+	t.Run("should reject expired token", func(t *testing.T) {
+		_, err := verifier.Verify(sign(jwt.MapClaims{"sub": "billing", "iss": "issuer", "exp": time.Now().Add(-time.Hour).Unix()}, "secret"))
+		assert.Error(t, err)
+	})
+
+	t.Run("should reject token without expiry", func(t *testing.T) {
+		_, err := verifier.Verify(sign(jwt.MapClaims{"sub": "billing", "iss": "issuer"}, "secret"))
+		assert.Error(t, err)
+	})
+
+	t.Run("should reject other issuer", func(t *testing.T) {
+		_, err := verifier.Verify(sign(jwt.MapClaims{"sub": "billing", "iss": "other", "exp": expires}, "secret"))
+		assert.Error(t, err)
+	})
+}
+
+func TestJWTVerifier_JWKS(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	encode := func(value *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(value.Bytes())
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	jwksDoc := fmt.Sprintf(`{"keys": [{"kty": "RSA", "kid": "main", "use": "sig", "n": %q, "e": %q}]}`,
+		encode(privateKey.N), encode(big.NewInt(int64(privateKey.E))))
+	if err := os.WriteFile(path, []byte(jwksDoc), 0o600); err != nil {
+		t.Fatalf("Failed to write JWKS: %v", err)
+	}
+
+	verifier, err := NewJWTVerifier(config.JWTConfig{JWKSFile: path})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	sign := func(kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"sub":   "reports",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "reports:read",
+		})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(privateKey)
+		if err != nil {
+			t.Fatalf("Setup failed: %v", err)
+		}
+		return signed
+	}
+
+	t.Run("should verify token signed by a JWKS key", func(t *testing.T) {
+		principal, err := verifier.Verify(sign("main"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "reports", principal.Subject)
+		assert.Equal(t, []models.Scope{models.ScopeReportsRead}, principal.Scopes)
+	})
+
+	t.Run("should reject unknown key ID", func(t *testing.T) {
+		_, err := verifier.Verify(sign("rotated"))
+		assert.Error(t, err)
+	})
+
+	t.Run("should reject HMAC token", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "reports",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatalf("Setup failed: %v", err)
+		}
+
+		_, err = verifier.Verify(token)
+		assert.Error(t, err)
+	})
+}
+
+func TestJWTVerifier_NotConfigured(t *testing.T) {
+	verifier, err := NewJWTVerifier(config.JWTConfig{})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "billing",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(""))
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	_, err = verifier.Verify(token)
+	assert.Error(t, err)
+}
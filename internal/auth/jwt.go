@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"API/internal/config"
+	"API/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	hmacMethods       = []string{"HS256", "HS384", "HS512"}
+	publicKeyMethods  = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+	errJWTUnsupported = errors.New("JWT authentication is not configured")
+)
+
+// claims are the JWT claims the service reads. Scopes are taken from the
+// space separated "scope" claim (RFC 8693) and the "scopes" array, segment
+// ownership is matched against the "teams" array.
+type claims struct {
+	jwt.RegisteredClaims
+	Scope  string   `json:"scope"`
+	Scopes []string `json:"scopes"`
+	Teams  []string `json:"teams"`
+}
+
+// JWTVerifier validates bearer tokens signed with the configured HMAC secret
+// or with a key of the configured JWKS file.
+type JWTVerifier struct {
+	keyFunc jwt.Keyfunc
+	parser  *jwt.Parser
+}
+
+// NewJWTVerifier builds the verifier described by cfg. With neither a secret
+// nor a JWKS file every token is rejected.
+func NewJWTVerifier(cfg config.JWTConfig) (*JWTVerifier, error) {
+	var (
+		keyFunc jwt.Keyfunc
+		methods []string
+	)
+	switch {
+	case cfg.HMACSecret != "" && cfg.JWKSFile != "":
+		return nil, errors.New("only one of hmac_secret and jwks_file can be set")
+	case cfg.HMACSecret != "":
+		secret := []byte(cfg.HMACSecret)
+		keyFunc = func(*jwt.Token) (any, error) { return secret, nil }
+		methods = hmacMethods
+	case cfg.JWKSFile != "":
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		keyFunc = keys.keyFunc
+		methods = publicKeyMethods
+	default:
+		keyFunc = func(*jwt.Token) (any, error) { return nil, errJWTUnsupported }
+		methods = hmacMethods
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &JWTVerifier{keyFunc: keyFunc, parser: jwt.NewParser(opts...)}, nil
+}
+
+// Verify validates token and returns its subject and scopes. Unknown scopes
+// are ignored.
+func (v *JWTVerifier) Verify(token string) (models.Principal, error) {
+	var c claims
+	if _, err := v.parser.ParseWithClaims(token, &c, v.keyFunc); err != nil {
+		return models.Principal{}, fmt.Errorf("invalid token: %w", err)
+	}
+	if c.Subject == "" {
+		return models.Principal{}, errors.New("invalid token: no subject")
+	}
+
+	granted := append(strings.Fields(c.Scope), c.Scopes...)
+	scopes := make([]models.Scope, 0, len(granted))
+	for _, scope := range granted {
+		if models.ValidateScopes([]models.Scope{models.Scope(scope)}) == nil {
+			scopes = append(scopes, models.Scope(scope))
+		}
+	}
+
+	return models.Principal{
+		Subject: c.Subject,
+		Method:  models.AuthMethodJWT,
+		Scopes:  scopes,
+		Teams:   c.Teams,
+	}, nil
+}
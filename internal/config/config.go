@@ -1,45 +1,261 @@
-package config
-
-import (
-	"time"
-
-	"github.com/ilyakaznacheev/cleanenv"
-)
-
-type DBConfig struct {
-	Host     string `yaml:"host" env:"DB_HOST" env-default:"localhost"`
-	Port     string `yaml:"port" env:"DB_PORT" env-default:"5432"`
-	User     string `yaml:"user" env:"DB_USER" env-default:"postgres"`
-	Password string `yaml:"password" env:"DB_PASSWORD" env-default:"postgres"`
-	DBName   string `yaml:"dbname" env:"DB_NAME" env-default:"postgres"`
-}
-
-type KafkaConfig struct {
-	Brokers []string `yaml:"brokers" env:"KAFKA_BROKERS" env-separator:"," env-default:"localhost:9092"`
-	Topic   string   `yaml:"topic" env:"KAFKA_TOPIC" env-default:"user-segments"`
-}
-
-type HTTPServer struct {
-	Address     string        `yaml:"address" env-default:":8080"`
-	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
-	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
-}
-
-type AppConfig struct {
-	DB     DBConfig    `yaml:"database"`
-	Kafka  KafkaConfig `yaml:"kafka"`
-	Server HTTPServer  `yaml:"http_server"`
-}
-
-func LoadDBConfig(configPath string) (*AppConfig, error) {
-	var cfg AppConfig
-	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
-		return nil, err
-	}
-
-	if err := cleanenv.ReadEnv(&cfg); err != nil {
-		return nil, err
-	}
-
-	return &cfg, nil
-}
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+type DBConfig struct {
+	Host     string `yaml:"host" env:"DB_HOST" env-default:"localhost"`
+	Port     string `yaml:"port" env:"DB_PORT" env-default:"5432"`
+	User     string `yaml:"user" env:"DB_USER" env-default:"postgres"`
+	Password string `yaml:"password" env:"DB_PASSWORD" env-default:"postgres"`
+	DBName   string `yaml:"dbname" env:"DB_NAME" env-default:"postgres"`
+}
+
+type KafkaRetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts" env:"KAFKA_RETRY_MAX_ATTEMPTS" env-default:"5"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env:"KAFKA_RETRY_INITIAL_BACKOFF" env-default:"200ms"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env:"KAFKA_RETRY_MAX_BACKOFF" env-default:"10s"`
+}
+
+type KafkaConfig struct {
+	Brokers      []string         `yaml:"brokers" env:"KAFKA_BROKERS" env-separator:"," env-default:"localhost:9092"`
+	Topic        string           `yaml:"topic" env:"KAFKA_TOPIC" env-default:"user-segments"`
+	GroupID      string           `yaml:"group_id" env:"KAFKA_GROUP_ID" env-default:"user-groups-api"`
+	CommandTopic string           `yaml:"command_topic" env:"KAFKA_COMMAND_TOPIC" env-default:"user-segment-commands"`
+	ReplyTopic   string           `yaml:"reply_topic" env:"KAFKA_REPLY_TOPIC" env-default:"user-segment-replies"`
+	Retry        KafkaRetryConfig `yaml:"retry"`
+}
+
+type HTTPServer struct {
+	Address     string        `yaml:"address" env-default:":8080"`
+	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
+	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
+}
+
+type TTLSweeperConfig struct {
+	Enabled   bool          `yaml:"enabled" env:"TTL_SWEEPER_ENABLED" env-default:"true"`
+	Interval  time.Duration `yaml:"interval" env:"TTL_SWEEPER_INTERVAL" env-default:"30s"`
+	BatchSize int           `yaml:"batch_size" env:"TTL_SWEEPER_BATCH_SIZE" env-default:"500"`
+}
+
+// SegmentPurgeConfig sets how long archived segments are kept before they are
+// deleted together with their memberships.
+type SegmentPurgeConfig struct {
+	Enabled   bool          `yaml:"enabled" env:"SEGMENT_PURGE_ENABLED" env-default:"true"`
+	Interval  time.Duration `yaml:"interval" env:"SEGMENT_PURGE_INTERVAL" env-default:"1h"`
+	Retention time.Duration `yaml:"retention" env:"SEGMENT_PURGE_RETENTION" env-default:"720h"`
+	BatchSize int           `yaml:"batch_size" env:"SEGMENT_PURGE_BATCH_SIZE" env-default:"10"`
+}
+
+// BulkConfig sets how bulk membership jobs are applied: ChunkSize rows per
+// transaction and at most MaxRows rows per job.
+type BulkConfig struct {
+	ChunkSize int64 `yaml:"chunk_size" env:"BULK_CHUNK_SIZE" env-default:"1000"`
+	MaxRows   int64 `yaml:"max_rows" env:"BULK_MAX_ROWS" env-default:"1000000"`
+}
+
+// MinJobLease is the shortest lease of jobs, which are heartbeated every
+// third of their lease.
+const MinJobLease = time.Second
+
+// JobsConfig sets how background jobs are run: Workers jobs at a time per
+// replica, looking for due jobs every Interval besides being woken by new
+// ones. A running job is leased for Lease, at least MinJobLease, and claimed
+// by another replica when the lease is not extended. A failed attempt is
+// retried after Backoff, doubled on every attempt up to a day, up to
+// MaxAttempts attempts. Finished jobs are deleted after Retention, bulk jobs
+// with the rows they stored included.
+type JobsConfig struct {
+	Workers     int           `yaml:"workers" env:"JOBS_WORKERS" env-default:"4"`
+	Interval    time.Duration `yaml:"interval" env:"JOBS_INTERVAL" env-default:"5s"`
+	Lease       time.Duration `yaml:"lease" env:"JOBS_LEASE" env-default:"1m"`
+	MaxAttempts int           `yaml:"max_attempts" env:"JOBS_MAX_ATTEMPTS" env-default:"3"`
+	Backoff     time.Duration `yaml:"backoff" env:"JOBS_BACKOFF" env-default:"10s"`
+	Retention   time.Duration `yaml:"retention" env:"JOBS_RETENTION" env-default:"168h"`
+}
+
+// ReportsConfig selects where history reports are stored: "local" keeps them
+// in Dir and serves them under /reports, "s3" uploads them to an
+// S3-compatible service. Download links expire after URLExpiry; local links
+// are signed with SigningKey, a random key per process when empty. Reports
+// older than Retention are deleted every Interval; zero keeps them forever.
+type ReportsConfig struct {
+	Storage    string        `yaml:"storage" env:"REPORTS_STORAGE" env-default:"local"`
+	Dir        string        `yaml:"dir" env:"REPORTS_DIR" env-default:"csv_reports"`
+	SigningKey string        `yaml:"signing_key" env:"REPORTS_SIGNING_KEY"`
+	URLExpiry  time.Duration `yaml:"url_expiry" env:"REPORTS_URL_EXPIRY" env-default:"1h"`
+	Retention  time.Duration `yaml:"retention" env:"REPORTS_RETENTION" env-default:"24h"`
+	Interval   time.Duration `yaml:"interval" env:"REPORTS_INTERVAL" env-default:"1h"`
+	S3         S3Config      `yaml:"s3"`
+}
+
+// MaxS3URLExpiry is the longest expiry of presigned links S3 accepts.
+const MaxS3URLExpiry = 7 * 24 * time.Hour
+
+// S3Config locates the bucket of an S3-compatible service. PathStyle puts
+// the bucket in the path instead of the host name, as MinIO and most local
+// stand-ins expect. Reports are stored under Prefix.
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint" env:"REPORTS_S3_ENDPOINT" env-default:"https://s3.amazonaws.com"`
+	Region    string `yaml:"region" env:"REPORTS_S3_REGION" env-default:"us-east-1"`
+	Bucket    string `yaml:"bucket" env:"REPORTS_S3_BUCKET"`
+	Prefix    string `yaml:"prefix" env:"REPORTS_S3_PREFIX" env-default:"reports/"`
+	AccessKey string `yaml:"access_key" env:"REPORTS_S3_ACCESS_KEY"`
+	SecretKey string `yaml:"secret_key" env:"REPORTS_S3_SECRET_KEY"`
+	PathStyle bool   `yaml:"path_style" env:"REPORTS_S3_PATH_STYLE" env-default:"false"`
+}
+
+type OutboxConfig struct {
+	Interval   time.Duration `yaml:"interval" env:"OUTBOX_INTERVAL" env-default:"1s"`
+	BatchSize  int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE" env-default:"100"`
+	MaxBackoff time.Duration `yaml:"max_backoff" env:"OUTBOX_MAX_BACKOFF" env-default:"1m"`
+	Retention  time.Duration `yaml:"retention" env:"OUTBOX_RETENTION" env-default:"24h"`
+}
+
+// HealthConfig bounds every readiness check by Timeout. A consumer lag above
+// MaxConsumerLag fails readiness; zero only reports the lag.
+type HealthConfig struct {
+	Timeout        time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT" env-default:"2s"`
+	MaxConsumerLag int64         `yaml:"max_consumer_lag" env:"HEALTH_MAX_CONSUMER_LAG" env-default:"0"`
+}
+
+// TracingConfig selects where spans are exported: "none" only propagates
+// trace context, "stdout" prints spans and "otlp" sends them over OTLP/HTTP
+// to Endpoint.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT" env-default:"localhost:4318"`
+	Insecure    bool    `yaml:"insecure" env:"TRACING_INSECURE" env-default:"true"`
+	ServiceName string  `yaml:"service_name" env:"TRACING_SERVICE_NAME" env-default:"user-groups-api"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+}
+
+// LogConfig sets the minimum level (debug, info, warn, error) and the format
+// (json or text) of the service logs.
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" env-default:"info"`
+	Format string `yaml:"format" env:"LOG_FORMAT" env-default:"json"`
+}
+
+// AuthConfig enables authentication of every endpoint except health checks,
+// metrics and Swagger. BootstrapKey is an API key with every scope, meant for
+// creating the first keys; leave it empty afterwards.
+type AuthConfig struct {
+	Enabled      bool      `yaml:"enabled" env:"AUTH_ENABLED" env-default:"true"`
+	BootstrapKey string    `yaml:"bootstrap_key" env:"AUTH_BOOTSTRAP_KEY"`
+	JWT          JWTConfig `yaml:"jwt"`
+}
+
+// JWTConfig validates bearer tokens against HMACSecret (HS256/384/512) or the
+// public keys of JWKSFile (RS*, PS*, ES*). JWTs are rejected when neither is
+// set. Issuer and Audience are checked when set.
+type JWTConfig struct {
+	HMACSecret string        `yaml:"hmac_secret" env:"AUTH_JWT_HMAC_SECRET"`
+	JWKSFile   string        `yaml:"jwks_file" env:"AUTH_JWT_JWKS_FILE"`
+	Issuer     string        `yaml:"issuer" env:"AUTH_JWT_ISSUER"`
+	Audience   string        `yaml:"audience" env:"AUTH_JWT_AUDIENCE"`
+	Leeway     time.Duration `yaml:"leeway" env:"AUTH_JWT_LEEWAY" env-default:"30s"`
+}
+
+// ShutdownConfig bounds the whole graceful shutdown: draining HTTP requests,
+// stopping consumers and workers and closing Kafka and database connections.
+type ShutdownConfig struct {
+	Timeout time.Duration `yaml:"timeout" env:"SHUTDOWN_TIMEOUT" env-default:"30s"`
+}
+
+type AppConfig struct {
+	DB           DBConfig           `yaml:"database"`
+	Kafka        KafkaConfig        `yaml:"kafka"`
+	Server       HTTPServer         `yaml:"http_server"`
+	TTLSweeper   TTLSweeperConfig   `yaml:"ttl_sweeper"`
+	SegmentPurge SegmentPurgeConfig `yaml:"segment_purge"`
+	Bulk         BulkConfig         `yaml:"bulk"`
+	Jobs         JobsConfig         `yaml:"jobs"`
+	Reports      ReportsConfig      `yaml:"reports"`
+	Outbox       OutboxConfig       `yaml:"outbox"`
+	Health       HealthConfig       `yaml:"health"`
+	Tracing      TracingConfig      `yaml:"tracing"`
+	Log          LogConfig          `yaml:"log"`
+	Auth         AuthConfig         `yaml:"auth"`
+	Shutdown     ShutdownConfig     `yaml:"shutdown"`
+}
+
+func LoadDBConfig(configPath string) (*AppConfig, error) {
+	var cfg AppConfig
+	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// Validate rejects settings the background workers cannot run with: a
+// non-positive interval panics in a ticker and a non-positive batch size
+// never completes a batch.
+func (c *AppConfig) Validate() error {
+	var errs []error
+	if c.TTLSweeper.Enabled {
+		errs = append(errs,
+			positive("ttl_sweeper.interval", c.TTLSweeper.Interval),
+			positive("ttl_sweeper.batch_size", c.TTLSweeper.BatchSize),
+		)
+	}
+	errs = append(errs,
+		positive("bulk.chunk_size", c.Bulk.ChunkSize),
+		positive("bulk.max_rows", c.Bulk.MaxRows),
+		positive("jobs.workers", c.Jobs.Workers),
+		positive("jobs.interval", c.Jobs.Interval),
+		positive("jobs.max_attempts", c.Jobs.MaxAttempts),
+		positive("jobs.backoff", c.Jobs.Backoff),
+		positive("jobs.retention", c.Jobs.Retention),
+	)
+	if c.Jobs.Lease < MinJobLease {
+		errs = append(errs, fmt.Errorf("jobs.lease must be at least %v, got %v", MinJobLease, c.Jobs.Lease))
+	}
+	errs = append(errs, positive("reports.url_expiry", c.Reports.URLExpiry))
+	if c.Reports.Retention > 0 {
+		errs = append(errs, positive("reports.interval", c.Reports.Interval))
+		// A link would outlive the report it points to.
+		if c.Reports.URLExpiry > c.Reports.Retention {
+			errs = append(errs, fmt.Errorf("reports.url_expiry must not exceed reports.retention %v, got %v",
+				c.Reports.Retention, c.Reports.URLExpiry))
+		}
+	}
+	if c.Reports.Storage == "s3" {
+		if c.Reports.URLExpiry > MaxS3URLExpiry {
+			errs = append(errs, fmt.Errorf("reports.url_expiry must not exceed %v with s3 storage, got %v",
+				MaxS3URLExpiry, c.Reports.URLExpiry))
+		}
+		// Old reports are deleted by prefix, which must not cover the bucket.
+		if c.Reports.S3.Prefix == "" {
+			errs = append(errs, errors.New("reports.s3.prefix must not be empty"))
+		}
+	}
+	if c.SegmentPurge.Enabled {
+		errs = append(errs,
+			positive("segment_purge.interval", c.SegmentPurge.Interval),
+			positive("segment_purge.batch_size", c.SegmentPurge.BatchSize),
+		)
+	}
+	return errors.Join(errs...)
+}
+
+func positive[T int | int64 | time.Duration](name string, value T) error {
+	if value <= 0 {
+		return fmt.Errorf("%s must be positive, got %v", name, value)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+// defaults returns the configuration with every default applied.
+func defaults(t *testing.T) AppConfig {
+	t.Helper()
+	var cfg AppConfig
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		t.Fatalf("Failed to apply defaults: %v", err)
+	}
+	return cfg
+}
+
+func TestAppConfigValidate(t *testing.T) {
+	if cfg := defaults(t); cfg.Validate() != nil {
+		t.Fatalf("Expected the defaults to be valid, got %v", cfg.Validate())
+	}
+
+	tests := []struct {
+		name   string
+		change func(*AppConfig)
+		field  string
+	}{
+		{"sweeper interval", func(c *AppConfig) { c.TTLSweeper.Interval = 0 }, "ttl_sweeper.interval"},
+		{"sweeper batch size", func(c *AppConfig) { c.TTLSweeper.BatchSize = -1 }, "ttl_sweeper.batch_size"},
+		{"purge interval", func(c *AppConfig) { c.SegmentPurge.Interval = 0 }, "segment_purge.interval"},
+		{"purge batch size", func(c *AppConfig) { c.SegmentPurge.BatchSize = 0 }, "segment_purge.batch_size"},
+		{"bulk chunk size", func(c *AppConfig) { c.Bulk.ChunkSize = 0 }, "bulk.chunk_size"},
+		{"bulk max rows", func(c *AppConfig) { c.Bulk.MaxRows = -1 }, "bulk.max_rows"},
+		{"job workers", func(c *AppConfig) { c.Jobs.Workers = 0 }, "jobs.workers"},
+		{"job interval", func(c *AppConfig) { c.Jobs.Interval = 0 }, "jobs.interval"},
+		{"job lease", func(c *AppConfig) { c.Jobs.Lease = 2 * time.Nanosecond }, "jobs.lease"},
+		{"job max attempts", func(c *AppConfig) { c.Jobs.MaxAttempts = 0 }, "jobs.max_attempts"},
+		{"job backoff", func(c *AppConfig) { c.Jobs.Backoff = -time.Second }, "jobs.backoff"},
+		{"job retention", func(c *AppConfig) { c.Jobs.Retention = 0 }, "jobs.retention"},
+		{"report link expiry", func(c *AppConfig) { c.Reports.URLExpiry = 0 }, "reports.url_expiry"},
+		{"report cleanup interval", func(c *AppConfig) { c.Reports.Interval = -time.Hour }, "reports.interval"},
+		{"report link outliving the report", func(c *AppConfig) { c.Reports.URLExpiry = 48 * time.Hour }, "reports.url_expiry"},
+		{"s3 link expiry", func(c *AppConfig) {
+			c.Reports.Storage, c.Reports.Retention, c.Reports.URLExpiry = "s3", 0, 8*24*time.Hour
+		}, "reports.url_expiry"},
+		{"s3 prefix", func(c *AppConfig) { c.Reports.Storage, c.Reports.S3.Prefix = "s3", "" }, "reports.s3.prefix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaults(t)
+			tt.change(&cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.field) {
+				t.Errorf("Expected an error naming %s, got %v", tt.field, err)
+			}
+		})
+	}
+
+	t.Run("reports kept forever", func(t *testing.T) {
+		cfg := defaults(t)
+		cfg.Reports.Retention = 0
+		cfg.Reports.Interval = 0
+		cfg.Reports.URLExpiry = 48 * time.Hour
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Expected the interval of a disabled cleanup not to be checked, got %v", err)
+		}
+	})
+
+	t.Run("disabled sweeper", func(t *testing.T) {
+		cfg := defaults(t)
+		cfg.TTLSweeper.Enabled = false
+		cfg.TTLSweeper.BatchSize = 0
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Expected a disabled sweeper not to be checked, got %v", err)
+		}
+	})
+}
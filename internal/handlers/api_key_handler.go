@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"API/internal/models"
+	"API/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type APIKeyHandler struct {
+	service *services.AuthService
+}
+
+func NewAPIKeyHandler(service *services.AuthService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+// CreateAPIKey creates an API key.
+// @Summary Create an API key
+// @Description Generates an API key with the given scopes. The key is returned only once,
+// @Description the service stores its hash.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param key body models.CreateAPIKeyRequest true "Name and scopes of the key"
+// @Success 201 {object} models.CreatedAPIKey "Created API key"
+// @Failure 400 {object} models.ResponseError "Invalid request payload"
+// @Failure 409 {object} models.ResponseError "API key name is taken"
+// @Failure 500 {object} models.ResponseError "Failed to create API key"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/api_keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c echo.Context) error {
+	var req models.CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid request body"))
+	}
+	if err := req.Normalize(); err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid request body", err))
+	}
+
+	key, err := h.service.CreateAPIKey(c.Request().Context(), req)
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyExists) {
+			return c.JSON(http.StatusConflict, models.ResponseErr("API key name is taken", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to create API key", err))
+	}
+
+	return c.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeys lists the API keys.
+// @Summary List API keys
+// @Description Returns the stored API keys without their secrets.
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} models.APIKey "API keys"
+// @Failure 500 {object} models.ResponseError "Failed to list API keys"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/api_keys [get]
+func (h *APIKeyHandler) ListAPIKeys(c echo.Context) error {
+	keys, err := h.service.ListAPIKeys(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to list API keys", err))
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+// DeleteAPIKey revokes an API key.
+// @Summary Delete an API key
+// @Description Revokes the API key, requests with it are rejected from then on.
+// @Tags Admin
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} models.Response "API key deleted"
+// @Failure 400 {object} models.ResponseError "Invalid API key ID"
+// @Failure 404 {object} models.ResponseError "API key not found"
+// @Failure 500 {object} models.ResponseError "Failed to delete API key"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/api_keys/{id} [delete]
+func (h *APIKeyHandler) DeleteAPIKey(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid API key ID"))
+	}
+
+	if err := h.service.DeleteAPIKey(c.Request().Context(), id); err != nil {
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			return c.JSON(http.StatusNotFound, models.ResponseErr("API key not found", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to delete API key", err))
+	}
+
+	return c.JSON(http.StatusOK, models.Response{
+		Message: "API key deleted",
+		Data:    id,
+	})
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"API/internal/models"
+	"API/internal/services"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type BulkHandler struct {
+	service *services.BulkMembershipService
+}
+
+func NewBulkHandler(service *services.BulkMembershipService) *BulkHandler {
+	return &BulkHandler{service: service}
+}
+
+// SubmitBulkJob starts a bulk membership update.
+// @Summary Update the segments of many users
+// @Description Adds and removes the same segments for many users in the background and returns the job.
+// @Description With a JSON body the users are listed in user_ids. With an application/x-ndjson body
+// @Description ({"user_id": N} per line) or a text/csv body (user ID in the first column, optional
+// @Description user_id header) the other fields are passed as query parameters.
+// @Description Poll the background job at the Location header, or the bulk job by its ID; rows that
+// @Description failed are listed by the error report.
+// @Tags UserSegments
+// @Accept json
+// @Accept application/x-ndjson
+// @Accept text/csv
+// @Produce json
+// @Param request body models.BulkMembershipRequest true "Users and segments"
+// @Param add_segments query []string false "Segments to add, for NDJSON and CSV bodies" collectionFormat(multi)
+// @Param delete_segments query []string false "Segments to delete, for NDJSON and CSV bodies" collectionFormat(multi)
+// @Param ttl query string false "TTL of the added memberships, for NDJSON and CSV bodies"
+// @Param reason query string false "Reason recorded in the history, for NDJSON and CSV bodies"
+// @Success 202 {object} models.BulkJob "Job accepted"
+// @Header 202 {string} Location "URL of the background job"
+// @Failure 400 {object} models.ResponseError "Invalid request payload"
+// @Failure 403 {object} models.ResponseError "Caller may not change a segment"
+// @Failure 415 {object} models.ResponseError "Unsupported body type"
+// @Failure 422 {object} models.ResponseError "Unknown or archived segments"
+// @Failure 500 {object} models.ResponseError "Failed to start the job"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user_segments/bulk [post]
+func (h *BulkHandler) SubmitBulkJob(c echo.Context) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+
+	var (
+		req  models.BulkMembershipRequest
+		rows models.BulkRowReader
+		err  error
+	)
+	if mediaType == "" || mediaType == echo.MIMEApplicationJSON {
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid request body"))
+		}
+		rows = models.NewUserIDRows(req.UserIDs)
+	} else {
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+			return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid query parameters"))
+		}
+		if rows, err = models.NewBulkRowReader(mediaType, c.Request().Body); err != nil {
+			return c.JSON(http.StatusUnsupportedMediaType, models.ResponseErr("unsupported body type", err))
+		}
+	}
+
+	job, err := h.service.SubmitBulkJob(c.Request().Context(), req, rows)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidMembership):
+			return c.JSON(http.StatusBadRequest, models.ResponseErr(err.Error()))
+		case errors.Is(err, models.ErrMembershipRejected):
+			return c.JSON(http.StatusUnprocessableEntity, models.ResponseErr(err.Error()))
+		case errors.Is(err, models.ErrForbidden):
+			return c.JSON(http.StatusForbidden, models.ResponseErr("not allowed to update user segments", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to start bulk job", err))
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/jobs/%d", job.JobID))
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// GetBulkJob returns a bulk membership job.
+// @Summary Get a bulk membership job
+// @Description Returns the status and progress of a bulk membership job. Only its submitter or an admin may read it.
+// @Tags UserSegments
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} models.BulkJob "Job"
+// @Failure 400 {object} models.ResponseError "Invalid job ID"
+// @Failure 403 {object} models.ResponseError "Job submitted by another principal"
+// @Failure 404 {object} models.ResponseError "Job not found"
+// @Failure 500 {object} models.ResponseError "Failed to get the job"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user_segments/bulk/{id} [get]
+func (h *BulkHandler) GetBulkJob(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid job ID"))
+	}
+
+	job, err := h.service.GetBulkJob(c.Request().Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrBulkJobNotFound):
+			return c.JSON(http.StatusNotFound, models.ResponseErr("bulk job not found", err))
+		case errors.Is(err, models.ErrForbidden):
+			return c.JSON(http.StatusForbidden, models.ResponseErr("not allowed to read bulk job", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to get bulk job", err))
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// GetBulkJobErrors returns the error report of a bulk membership job.
+// @Summary Get the error report of a bulk membership job
+// @Description Streams the rows that failed so far as CSV with the columns row, user_id and error.
+// @Description Only the submitter of the job or an admin may read it.
+// @Tags UserSegments
+// @Produce text/csv
+// @Param id path int true "Job ID"
+// @Success 200 {string} string "Error report"
+// @Failure 400 {object} models.ResponseError "Invalid job ID"
+// @Failure 403 {object} models.ResponseError "Job submitted by another principal"
+// @Failure 404 {object} models.ResponseError "Job not found"
+// @Failure 500 {object} models.ResponseError "Failed to read the error report"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user_segments/bulk/{id}/errors [get]
+func (h *BulkHandler) GetBulkJobErrors(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid job ID"))
+	}
+
+	var writer *csv.Writer
+	err = h.service.BulkJobErrors(c.Request().Context(), id, func(row models.BulkRow) error {
+		if writer == nil {
+			writer = startBulkErrorReport(c, id)
+		}
+		userID := ""
+		if row.UserID != 0 {
+			userID = strconv.FormatInt(row.UserID, 10)
+		}
+		return writer.Write([]string{strconv.FormatInt(row.Row, 10), userID, row.Error})
+	})
+	if writer != nil {
+		// Headers are sent, so a failure can only cut the report short.
+		writer.Flush()
+		return errors.Join(err, writer.Error())
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrBulkJobNotFound):
+			return c.JSON(http.StatusNotFound, models.ResponseErr("bulk job not found", err))
+		case errors.Is(err, models.ErrForbidden):
+			return c.JSON(http.StatusForbidden, models.ResponseErr("not allowed to read bulk job", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to read bulk job errors", err))
+	}
+
+	startBulkErrorReport(c, id).Flush()
+	return nil
+}
+
+// startBulkErrorReport sends the headers of the error report of job id and
+// returns the writer of its rows, with the column names written.
+func startBulkErrorReport(c echo.Context, id int64) *csv.Writer {
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, "text/csv")
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="bulk_job_%d_errors.csv"`, id))
+	c.Response().WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(c.Response())
+	writer.Write([]string{"row", "user_id", "error"})
+	return writer
+}
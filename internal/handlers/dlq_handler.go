@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"API/internal/models"
+	"API/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultDLQLimit = 50
+	maxDLQLimit     = 500
+)
+
+type DLQHandler struct {
+	service *services.DLQService
+}
+
+func NewDLQHandler(service *services.DLQService) *DLQHandler {
+	return &DLQHandler{service: service}
+}
+
+// ListMessages lists dead-letter messages of a topic.
+// @Summary List dead-letter messages
+// @Description Returns the latest messages of every partition of the <topic>.dlq topic
+// @Description together with the processing error and the source position.
+// @Tags Admin
+// @Produce json
+// @Param topic path string true "Source topic"
+// @Param limit query int false "Messages per partition, 50 by default, at most 500"
+// @Success 200 {array} models.DLQMessage "Dead-letter messages"
+// @Failure 400 {object} models.ResponseError "Invalid limit"
+// @Failure 500 {object} models.ResponseError "Failed to read dead-letter topic"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/dlq/{topic} [get]
+func (h *DLQHandler) ListMessages(c echo.Context) error {
+	limit := defaultDLQLimit
+	if param := c.QueryParam("limit"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed < 1 || parsed > maxDLQLimit {
+			return c.JSON(http.StatusBadRequest, models.ResponseErr("limit must be between 1 and 500"))
+		}
+		limit = parsed
+	}
+
+	messages, err := h.service.ListMessages(c.Request().Context(), c.Param("topic"), limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to list dead-letter messages", err))
+	}
+
+	return c.JSON(http.StatusOK, messages)
+}
+
+// Replay publishes a dead-letter message back to its source topic.
+// @Summary Replay a dead-letter message
+// @Description Publishes the message at the given partition and offset of <topic>.dlq back to the topic.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param topic path string true "Source topic"
+// @Param message body models.DLQReplayRequest true "Dead-letter message position"
+// @Success 200 {object} models.Response "Message replayed"
+// @Failure 400 {object} models.ResponseError "Invalid request payload"
+// @Failure 404 {object} models.ResponseError "Message not found"
+// @Failure 500 {object} models.ResponseError "Failed to replay message"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/dlq/{topic}/replay [post]
+func (h *DLQHandler) Replay(c echo.Context) error {
+	var req models.DLQReplayRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid request body"))
+	}
+
+	if err := h.service.Replay(c.Request().Context(), c.Param("topic"), req.Partition, req.Offset); err != nil {
+		if errors.Is(err, models.ErrDLQMessageNotFound) {
+			return c.JSON(http.StatusNotFound, models.ResponseErr("dead-letter message not found", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to replay message", err))
+	}
+
+	return c.JSON(http.StatusOK, models.Response{
+		Message: "Message replayed",
+		Data:    req,
+	})
+}
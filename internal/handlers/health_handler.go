@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"API/internal/models"
+	"API/internal/services"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type HealthHandler struct {
+	service *services.HealthService
+}
+
+func NewHealthHandler(service *services.HealthService) *HealthHandler {
+	return &HealthHandler{service: service}
+}
+
+// Liveness reports that the process is running.
+// @Summary Liveness probe
+// @Description Returns 200 while the process is able to serve HTTP requests. Dependencies are not checked.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} models.HealthReport "Process is alive"
+// @Router /healthz [get]
+func (h *HealthHandler) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, models.HealthReport{Status: models.HealthStatusUp})
+}
+
+// Readiness reports whether the service can handle traffic.
+// @Summary Readiness probe
+// @Description Checks Postgres, Kafka broker reachability from the producer and the consumer
+// @Description and the consumer lag. Fails while the service is shutting down.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} models.HealthReport "All dependencies are up"
+// @Failure 503 {object} models.HealthReport "A dependency is down or the service is shutting down"
+// @Router /readyz [get]
+func (h *HealthHandler) Readiness(c echo.Context) error {
+	report := h.service.Readiness(c.Request().Context())
+	if report.Status != models.HealthStatusUp {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
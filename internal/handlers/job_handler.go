@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"API/internal/models"
+	"API/internal/services"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type JobHandler struct {
+	service *services.JobQueue
+}
+
+func NewJobHandler(service *services.JobQueue) *JobHandler {
+	return &JobHandler{service: service}
+}
+
+// acceptJob answers a request whose work continues in the background job
+// with 202 Accepted, the job and its URL in the Location header.
+func acceptJob(c echo.Context, job models.Job) error {
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/jobs/%d", job.ID))
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// GetJob returns a background job.
+// @Summary Get a background job
+// @Description Returns the status, progress and, once it succeeded, the result of a background job.
+// @Description Only the principal that submitted the job or an admin may see it.
+// @Tags Jobs
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} models.Job "Job"
+// @Failure 400 {object} models.ResponseError "Invalid job ID"
+// @Failure 403 {object} models.ResponseError "Job of another principal"
+// @Failure 404 {object} models.ResponseError "Job not found"
+// @Failure 500 {object} models.ResponseError "Failed to get the job"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /jobs/{id} [get]
+func (h *JobHandler) GetJob(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid job ID"))
+	}
+
+	job, err := h.service.GetJob(c.Request().Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrJobNotFound):
+			return c.JSON(http.StatusNotFound, models.ResponseErr("job not found", err))
+		case errors.Is(err, models.ErrForbidden):
+			return c.JSON(http.StatusForbidden, models.ResponseErr("not allowed to see job", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to get job", err))
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// CancelJob cancels a background job.
+// @Summary Cancel a background job
+// @Description Cancels a queued job at once. A running job is asked to stop and is cancelled at
+// @Description its next heartbeat; work it has done until then is kept. Only the principal that
+// @Description submitted the job or an admin may cancel it.
+// @Tags Jobs
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} models.Job "Cancelled job, or running job asked to stop"
+// @Failure 400 {object} models.ResponseError "Invalid job ID"
+// @Failure 403 {object} models.ResponseError "Job of another principal"
+// @Failure 404 {object} models.ResponseError "Job not found"
+// @Failure 409 {object} models.ResponseError "Job already finished"
+// @Failure 500 {object} models.ResponseError "Failed to cancel the job"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /jobs/{id} [delete]
+func (h *JobHandler) CancelJob(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid job ID"))
+	}
+
+	job, err := h.service.CancelJob(c.Request().Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrJobNotFound):
+			return c.JSON(http.StatusNotFound, models.ResponseErr("job not found", err))
+		case errors.Is(err, models.ErrForbidden):
+			return c.JSON(http.StatusForbidden, models.ResponseErr("not allowed to cancel job", err))
+		case errors.Is(err, models.ErrJobFinished):
+			return c.JSON(http.StatusConflict, models.ResponseErr("job already finished", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to cancel job", err))
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
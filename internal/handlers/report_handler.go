@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"API/internal/models"
+	"API/internal/storage"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ReportHandler struct {
+	storage *storage.LocalReportStorage
+}
+
+func NewReportHandler(storage *storage.LocalReportStorage) *ReportHandler {
+	return &ReportHandler{storage: storage}
+}
+
+// DownloadReport serves a report kept by the local report storage.
+// @Summary Download a report
+// @Description Downloads a stored report through the signed link returned when it was generated.
+// @Description The link is its own credential and stops working when it expires.
+// @Tags Reports
+// @Produce text/csv
+// @Produce json
+// @Param name path string true "Report file name"
+// @Param expires query int true "Expiry of the link as a Unix time"
+// @Param signature query string true "Signature of the link"
+// @Success 200 {string} string "Report"
+// @Failure 403 {object} models.ResponseError "Invalid or expired link"
+// @Failure 404 {object} models.ResponseError "Report not found"
+// @Failure 500 {object} models.ResponseError "Failed to read the report"
+// @Router /reports/{name} [get]
+func (h *ReportHandler) DownloadReport(c echo.Context) error {
+	name := c.Param("name")
+
+	file, err := h.storage.Open(name, c.QueryParams())
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidReportLink):
+			return c.JSON(http.StatusForbidden, models.ResponseErr(err.Error()))
+		case errors.Is(err, models.ErrReportNotFound):
+			return c.JSON(http.StatusNotFound, models.ResponseErr(err.Error()))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to read report", err))
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to read report", err))
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, "text/csv")
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, name))
+	http.ServeContent(c.Response(), c.Request(), name, info.ModTime(), file)
+	return nil
+}
@@ -1,86 +1,295 @@
-package handlers
-
-import (
-	"API/internal/models"
-	"API/internal/services"
-	"net/http"
-
-	"github.com/labstack/echo/v4"
-)
-
-type SegmentHandler struct {
-	segmentService *services.SegmentService
-}
-
-func NewSegmentHandler(segmentService *services.SegmentService) *SegmentHandler {
-	return &SegmentHandler{segmentService: segmentService}
-}
-
-// GetAllSegments retrieves all segments from the database.
-// @Summary Retrieve all segments
-// @Description Fetches a list of all segments stored in the database.
-// @Tags Segments
-// @Produce json
-// @Success 200 {array} models.Segments "List of segments"
-// @Failure 500 {object} models.ResponseError "Failed to retrieve segments"
-// @Router /segments [get]
-func (h *SegmentHandler) GetAllSegments(c echo.Context) error {
-	segments, err := h.segmentService.GetAllSegments()
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ResponseErr("could not retrieve segments"))
-	}
-
-	return c.JSON(http.StatusOK, segments)
-}
-
-// CreateSegment creates a new segment
-// @Summary Create a new segment
-// @Description Adds a new segment to the database.
-// @Tags Segments
-// @Accept json
-// @Produce json
-// @Param segment body models.SegmentRequest true "Segment data"
-// @Success 200 {object} models.Response "Segment created successfully"
-// @Failure 400 {object} models.ResponseError "Invalid slug"
-// @Failure 500 {object} models.ResponseError "Failed to create segment"
-// @Router /segments [post]
-func (h *SegmentHandler) CreateSegment(c echo.Context) error {
-	var segment models.SegmentRequest
-	if err := c.Bind(&segment); err != nil {
-		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid slug"))
-	}
-
-	if err := h.segmentService.CreateSegment(segment.Slug); err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ResponseErr(err.Error()))
-	}
-
-	return c.JSON(http.StatusOK, models.Response{
-		Message: "Segment created successfully",
-	})
-}
-
-// DeleteSegment removes a segment by its slug.
-// @Summary Delete a segment
-// @Description Deletes a segment from the system using the provided slug.
-// @Tags Segments
-// @Accept json
-// @Produce json
-// @Param segment body models.SegmentRequest true "Segment data"
-// @Success 200 {object} models.Response "Segment deleted successfully"
-// @Failure 400 {object} models.ResponseError "Invalid slug"
-// @Failure 500 {object} models.ResponseError "Failed to delete segment"
-// @Router /segments [delete]
-func (h *SegmentHandler) DeleteSegment(c echo.Context) error {
-	var segment models.SegmentRequest
-	if err := c.Bind(&segment); err != nil {
-		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid slug"))
-	}
-
-	if err := h.segmentService.DeleteSegment(segment.Slug); err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ResponseErr(err.Error()))
-	}
-
-	return c.JSON(http.StatusOK, models.Response{
-		Message: "Segment deleted successfully",
-	})
-}
+package handlers
+
+import (
+	"API/internal/models"
+	"API/internal/services"
+	"cmp"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SegmentHandler struct {
+	segmentService *services.SegmentService
+}
+
+func NewSegmentHandler(segmentService *services.SegmentService) *SegmentHandler {
+	return &SegmentHandler{segmentService: segmentService}
+}
+
+// GetAllSegments retrieves all segments from the database.
+// @Summary Retrieve all segments
+// @Description Fetches a list of all segments stored in the database, optionally filtered by tag,
+// @Description owner and whether the current time falls into their active period.
+// @Tags Segments
+// @Produce json
+// @Param tag query string false "Only segments with this tag"
+// @Param owner query string false "Only segments with this owner"
+// @Param active query bool false "Only segments inside (true) or outside (false) their active period"
+// @Success 200 {array} models.Segments "List of segments"
+// @Failure 400 {object} models.ResponseError "Invalid query parameters"
+// @Failure 500 {object} models.ResponseError "Failed to retrieve segments"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segments [get]
+func (h *SegmentHandler) GetAllSegments(c echo.Context) error {
+	var filter models.SegmentFilter
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &filter); err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid query parameters"))
+	}
+
+	segments, err := h.segmentService.GetAllSegments(c.Request().Context(), filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("could not retrieve segments"))
+	}
+
+	return c.JSON(http.StatusOK, segments)
+}
+
+// GetSegment returns a segment with its metadata.
+// @Summary Get a segment
+// @Description Returns the description, owner, tags and lifecycle dates of the segment.
+// @Tags Segments
+// @Produce json
+// @Param slug path string true "Segment slug"
+// @Success 200 {object} models.Segments "Segment"
+// @Failure 404 {object} models.ResponseError "Segment not found"
+// @Failure 500 {object} models.ResponseError "Failed to get segment"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segments/{slug} [get]
+func (h *SegmentHandler) GetSegment(c echo.Context) error {
+	segment, err := h.segmentService.GetSegment(c.Request().Context(), models.Slug(c.Param("slug")))
+	if err != nil {
+		if errors.Is(err, models.ErrSegmentNotFound) {
+			return c.JSON(http.StatusNotFound, models.ResponseErr("segment not found", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to get segment", err))
+	}
+
+	return c.JSON(http.StatusOK, segment)
+}
+
+// UpdateSegment changes the metadata of a segment.
+// @Summary Update segment metadata
+// @Description Changes the description, owner, tags or active period of the segment. Omitted
+// @Description fields are kept, tags are replaced as a whole. Only the owner of the segment or
+// @Description an admin may change it.
+// @Tags Segments
+// @Accept json
+// @Produce json
+// @Param slug path string true "Segment slug"
+// @Param segment body models.SegmentPatch true "Fields to change"
+// @Success 200 {object} models.Segments "Updated segment"
+// @Failure 400 {object} models.ResponseError "Invalid request payload"
+// @Failure 403 {object} models.ResponseError "Caller does not manage the segment"
+// @Failure 404 {object} models.ResponseError "Segment not found"
+// @Failure 500 {object} models.ResponseError "Failed to update segment"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segments/{slug} [patch]
+func (h *SegmentHandler) UpdateSegment(c echo.Context) error {
+	var patch models.SegmentPatch
+	if err := c.Bind(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid request body"))
+	}
+
+	segment, err := h.segmentService.UpdateSegment(c.Request().Context(), models.Slug(c.Param("slug")), patch)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidSegment):
+			return c.JSON(http.StatusBadRequest, models.ResponseErr(err.Error()))
+		case errors.Is(err, models.ErrSegmentNotFound):
+			return c.JSON(http.StatusNotFound, models.ResponseErr("segment not found", err))
+		case errors.Is(err, models.ErrForbidden):
+			return c.JSON(http.StatusForbidden, models.ResponseErr("not allowed to update segment", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to update segment", err))
+	}
+
+	return c.JSON(http.StatusOK, segment)
+}
+
+// CreateSegment creates a new segment
+// @Summary Create a new segment
+// @Description Adds a new segment to the database. When auto_percent is set, that share of
+// @Description existing and future users is enrolled into the segment automatically.
+// @Description The segment is owned by owner or, when it is omitted, by the caller.
+// @Tags Segments
+// @Accept json
+// @Produce json
+// @Param segment body models.SegmentRequest true "Segment data"
+// @Success 200 {object} models.Response "Segment created successfully"
+// @Failure 400 {object} models.ResponseError "Invalid slug, auto_percent, tags or active period"
+// @Failure 500 {object} models.ResponseError "Failed to create segment"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segments [post]
+func (h *SegmentHandler) CreateSegment(c echo.Context) error {
+	var segment models.SegmentRequest
+	if err := c.Bind(&segment); err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid slug"))
+	}
+
+	if err := h.segmentService.CreateSegment(c.Request().Context(), segment); err != nil {
+		if errors.Is(err, models.ErrInvalidSegment) {
+			return c.JSON(http.StatusBadRequest, models.ResponseErr(err.Error()))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr(err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, models.Response{
+		Message: "Segment created successfully",
+	})
+}
+
+// DeleteSegment archives a segment by its slug.
+// @Summary Delete a segment
+// @Description Archives the segment with the provided slug. An archived segment is hidden from
+// @Description the segment list and its memberships cannot be changed, but they are kept until
+// @Description the segment is restored or purged after the retention period.
+// @Description With purge=true the segment, archived earlier or now, is also deleted with its memberships by a background job.
+// @Description Only the owner of the segment or an admin may delete it.
+// @Tags Segments
+// @Accept json
+// @Produce json
+// @Param segment body models.SegmentRequest true "Segment data"
+// @Param purge query bool false "Delete the segment and its memberships in the background"
+// @Success 200 {object} models.Response "Segment deleted successfully"
+// @Success 202 {object} models.Job "Segment archived, purge job accepted"
+// @Header 202 {string} Location "URL of the purge job"
+// @Failure 400 {object} models.ResponseError "Invalid slug"
+// @Failure 403 {object} models.ResponseError "Caller does not manage the segment"
+// @Failure 404 {object} models.ResponseError "Segment not found or already archived"
+// @Failure 500 {object} models.ResponseError "Failed to delete segment"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segments [delete]
+func (h *SegmentHandler) DeleteSegment(c echo.Context) error {
+	var segment models.SegmentRequest
+	if err := c.Bind(&segment); err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid slug"))
+	}
+
+	purge, err := strconv.ParseBool(cmp.Or(c.QueryParam("purge"), "false"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid purge parameter"))
+	}
+
+	var job models.Job
+	if purge {
+		job, err = h.segmentService.PurgeSegment(c.Request().Context(), segment.Slug)
+	} else {
+		err = h.segmentService.DeleteSegment(c.Request().Context(), segment.Slug)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrSegmentNotFound):
+			return c.JSON(http.StatusNotFound, models.ResponseErr("segment not found", err))
+		case errors.Is(err, models.ErrForbidden):
+			return c.JSON(http.StatusForbidden, models.ResponseErr("not allowed to delete segment", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr(err.Error()))
+	}
+
+	if purge {
+		return acceptJob(c, job)
+	}
+	return c.JSON(http.StatusOK, models.Response{
+		Message: "Segment deleted successfully",
+	})
+}
+
+// RestoreSegment brings back an archived segment.
+// @Summary Restore an archived segment
+// @Description Restores a segment archived by DELETE /segments together with its memberships.
+// @Description Only the owner of the segment or an admin may restore it.
+// @Tags Segments
+// @Produce json
+// @Param slug path string true "Segment slug"
+// @Success 200 {object} models.Response "Segment restored successfully"
+// @Failure 403 {object} models.ResponseError "Caller does not manage the segment"
+// @Failure 404 {object} models.ResponseError "Archived segment not found"
+// @Failure 500 {object} models.ResponseError "Failed to restore segment"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segments/{slug}/restore [post]
+func (h *SegmentHandler) RestoreSegment(c echo.Context) error {
+	if err := h.segmentService.RestoreSegment(c.Request().Context(), models.Slug(c.Param("slug"))); err != nil {
+		switch {
+		case errors.Is(err, models.ErrSegmentNotFound):
+			return c.JSON(http.StatusNotFound, models.ResponseErr("archived segment not found", err))
+		case errors.Is(err, models.ErrForbidden):
+			return c.JSON(http.StatusForbidden, models.ResponseErr("not allowed to restore segment", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to restore segment", err))
+	}
+
+	return c.JSON(http.StatusOK, models.Response{
+		Message: "Segment restored successfully",
+	})
+}
+
+// GetSegmentACL returns who may change a segment.
+// @Summary Get the ACL of a segment
+// @Description Returns the owner and the writers of the segment.
+// @Tags Segments
+// @Produce json
+// @Param slug path string true "Segment slug"
+// @Success 200 {object} models.SegmentACL "Segment ACL"
+// @Failure 404 {object} models.ResponseError "Segment not found"
+// @Failure 500 {object} models.ResponseError "Failed to get segment ACL"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segments/{slug}/acl [get]
+func (h *SegmentHandler) GetSegmentACL(c echo.Context) error {
+	acl, err := h.segmentService.GetSegmentACL(c.Request().Context(), models.Slug(c.Param("slug")))
+	if err != nil {
+		if errors.Is(err, models.ErrSegmentNotFound) {
+			return c.JSON(http.StatusNotFound, models.ResponseErr("segment not found", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to get segment ACL", err))
+	}
+
+	return c.JSON(http.StatusOK, acl)
+}
+
+// SetSegmentACL replaces who may change a segment.
+// @Summary Replace the ACL of a segment
+// @Description Sets the owner and the writers of the segment. The owner manages the segment,
+// @Description writers may only add and remove its members. Only the current owner or an admin
+// @Description may change the ACL; a segment without owner and writers is open to everyone.
+// @Tags Segments
+// @Accept json
+// @Produce json
+// @Param slug path string true "Segment slug"
+// @Param acl body models.SegmentACLRequest true "Owner and writers"
+// @Success 200 {object} models.SegmentACL "Updated segment ACL"
+// @Failure 400 {object} models.ResponseError "Invalid request payload"
+// @Failure 403 {object} models.ResponseError "Caller does not manage the segment"
+// @Failure 404 {object} models.ResponseError "Segment not found"
+// @Failure 500 {object} models.ResponseError "Failed to set segment ACL"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segments/{slug}/acl [put]
+func (h *SegmentHandler) SetSegmentACL(c echo.Context) error {
+	var req models.SegmentACLRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid request body"))
+	}
+
+	acl, err := h.segmentService.SetSegmentACL(c.Request().Context(), models.Slug(c.Param("slug")), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrSegmentNotFound):
+			return c.JSON(http.StatusNotFound, models.ResponseErr("segment not found", err))
+		case errors.Is(err, models.ErrForbidden):
+			return c.JSON(http.StatusForbidden, models.ResponseErr("not allowed to change segment ACL", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to set segment ACL", err))
+	}
+
+	return c.JSON(http.StatusOK, acl)
+}
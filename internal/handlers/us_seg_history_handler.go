@@ -1,49 +1,130 @@
-package handlers
-
-import (
-	"API/internal/services"
-	"fmt"
-	"net/http"
-	"strconv"
-
-	"github.com/labstack/echo/v4"
-)
-
-type UserSegmentHistoryHandler struct {
-	Service *services.UserSegmentHistoryService
-}
-
-func NewUserSegmentHistoryHandler(service *services.UserSegmentHistoryService) *UserSegmentHistoryHandler {
-	return &UserSegmentHistoryHandler{Service: service}
-}
-
-// GenerateHistoryCSV handles the request to generate a user history CSV file.
-// @Summary Generate User History CSV
-// @Description Generate a CSV file containing the user's segment history for a specific month.
-// @Tags UserSegmentHistory
-// @Param user_id path int true "User ID"
-// @Param date query string true "Year-Month in YYYY-MM format"
-// @Produce text/plain
-// @Success 200 {string} string "URL to the generated CSV file"
-// @Failure 400 {object} string "Bad Request"
-// @Failure 500 {object} string "Internal Server Error"
-// @Router /user_segments/history/{user_id} [get]
-func (h *UserSegmentHistoryHandler) GenerateHistoryCSV(c echo.Context) error {
-	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, "invalid user ID")
-	}
-
-	date := c.QueryParam("date")
-	if date == "" {
-		return c.JSON(http.StatusBadRequest, "date parameter is required")
-	}
-
-	fileName, err := h.Service.GenerateUserHistoryCSV(userID, date)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
-	}
-
-	url := fmt.Sprintf("http://%s/csv_reports/%s", c.Request().Host, fileName)
-	return c.String(http.StatusOK, url)
-}
+package handlers
+
+import (
+	"API/internal/models"
+	"API/internal/services"
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+type UserSegmentHistoryHandler struct {
+	Service *services.UserSegmentHistoryService
+}
+
+func NewUserSegmentHistoryHandler(service *services.UserSegmentHistoryService) *UserSegmentHistoryHandler {
+	return &UserSegmentHistoryHandler{Service: service}
+}
+
+// GenerateHistoryCSV handles the request to generate a user history CSV file.
+// @Summary Generate User History CSV
+// @Description Generate a CSV file containing the user's segment history for a specific month.
+// @Description By default the report is stored and a download link that expires is returned. With stream=true the CSV is
+// @Description streamed as the response body instead. With async=true the report is stored by a
+// @Description background job; its result holds the URL.
+// @Tags UserSegmentHistory
+// @Param user_id path int true "User ID"
+// @Param date query string true "Year-Month in YYYY-MM format"
+// @Param stream query bool false "Stream the CSV as the response body"
+// @Param async query bool false "Generate the report in the background"
+// @Produce text/plain
+// @Produce text/csv
+// @Produce json
+// @Success 200 {string} string "URL to the generated CSV file, or the CSV with stream=true"
+// @Success 202 {object} models.Job "Report job accepted"
+// @Header 202 {string} Location "URL of the job"
+// @Failure 400 {object} string "Bad Request"
+// @Failure 500 {object} string "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user_segments/history/{user_id} [get]
+func (h *UserSegmentHistoryHandler) GenerateHistoryCSV(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "invalid user ID")
+	}
+
+	date := c.QueryParam("date")
+	if date == "" {
+		return c.JSON(http.StatusBadRequest, "date parameter is required")
+	}
+
+	stream, err := strconv.ParseBool(cmp.Or(c.QueryParam("stream"), "false"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "invalid stream parameter")
+	}
+	async, err := strconv.ParseBool(cmp.Or(c.QueryParam("async"), "false"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "invalid async parameter")
+	}
+
+	switch {
+	case stream && async:
+		return c.JSON(http.StatusBadRequest, "stream and async cannot be combined")
+	case stream:
+		return h.streamHistoryCSV(c, userID, date)
+	case async:
+		job, err := h.Service.EnqueueUserHistoryCSV(c.Request().Context(), userID, date)
+		if err != nil {
+			return historyReportError(c, err)
+		}
+		return acceptJob(c, job)
+	}
+
+	report, err := h.Service.GenerateUserHistoryCSV(c.Request().Context(), userID, date)
+	if err != nil {
+		return historyReportError(c, err)
+	}
+
+	url := report.URL
+	if strings.HasPrefix(url, "/") {
+		url = fmt.Sprintf("%s://%s%s", c.Scheme(), c.Request().Host, url)
+	}
+	return c.String(http.StatusOK, url)
+}
+
+// streamHistoryCSV sends the report as an attachment. The headers are sent
+// with the first bytes of the report, so a request that fails before still
+// gets an error response.
+func (h *UserSegmentHistoryHandler) streamHistoryCSV(c echo.Context, userID int64, date string) error {
+	body := &attachment{c: c, name: models.HistoryReportName(userID, date)}
+	if err := h.Service.WriteUserHistoryCSV(c.Request().Context(), userID, date, body); err != nil {
+		if body.started {
+			// Headers are sent, so a failure can only cut the report short.
+			return err
+		}
+		return historyReportError(c, err)
+	}
+	return nil
+}
+
+func historyReportError(c echo.Context, err error) error {
+	if errors.Is(err, models.ErrInvalidReportDate) {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusInternalServerError, err.Error())
+}
+
+// attachment is a CSV response body sent as a file named name. The headers
+// are sent on the first write.
+type attachment struct {
+	c       echo.Context
+	name    string
+	started bool
+}
+
+func (a *attachment) Write(p []byte) (int, error) {
+	if !a.started {
+		header := a.c.Response().Header()
+		header.Set(echo.HeaderContentType, "text/csv")
+		header.Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, a.name))
+		a.c.Response().WriteHeader(http.StatusOK)
+		a.started = true
+	}
+	return a.c.Response().Write(p)
+}
@@ -1,100 +1,187 @@
-package handlers
-
-import (
-	"API/internal/models"
-	"API/internal/services"
-	"net/http"
-	"strconv"
-	"time"
-
-	"github.com/labstack/echo/v4"
-)
-
-type UserSegmentHandler struct {
-	service *services.UserSegmentService
-}
-
-func NewUserSegmentHandler(service *services.UserSegmentService) *UserSegmentHandler {
-	return &UserSegmentHandler{service: service}
-}
-
-// GetUserSegments retrieves segments for a specific user.
-// @Summary Get segments for a user
-// @Description Retrieves all segments associated with a user by user ID.
-// @Tags UserSegments
-// @Produce json
-// @Param user_id path int true "User ID"
-// @Success 200 {array} models.UserSegment "List of user segments"
-// @Failure 400 {object} models.ResponseError "Invalid user ID"
-// @Failure 500 {object} models.ResponseError "Failed to retrieve user segments"
-// @Router /user_segments/{user_id} [get]
-func (h *UserSegmentHandler) GetUserSegments(c echo.Context) error {
-	userIDParam := c.Param("user_id")
-
-	// Преобразуем его в int64
-	userID, err := strconv.Atoi(userIDParam)
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, models.ResponseErr("Invalid user ID"))
-	}
-
-	segments, err := h.service.GetUserSegments(int64(userID))
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to get user segments", err))
-	}
-
-	return c.JSON(http.StatusOK, segments)
-}
-
-// GetAllUserSegments retrieves all user-segment relationships.
-// @Summary Retrieve all user-segment relationships
-// @Description Fetches all user-to-segment mappings stored in the database.
-// @Tags UserSegments
-// @Produce json
-// @Success 200 {array} models.UserSegment "List of user-segment relationships"
-// @Failure 500 {object} models.ResponseError "Failed to retrieve user segments"
-// @Router /user_segments [get]
-func (h *UserSegmentHandler) GetAllUserSegments(c echo.Context) error {
-	segments, err := h.service.GetAllUserSegments()
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to get all user segments", err))
-	}
-
-	return c.JSON(http.StatusOK, segments)
-}
-
-// UpdateUserSegments modifies a user's segments.
-// @Summary Update a user's segments
-// @Description Adds or removes segments associated with a user.
-// @Tags UserSegments
-// @Accept json
-// @Produce json
-// @Param userSegment body models.UpdateSegmentsRequest true "Segments to add or remove"
-// @Success 200 {object} models.Response "User segments updated successfully"
-// @Failure 400 {object} models.ResponseError "Invalid request payload"
-// @Failure 500 {object} models.ResponseError "Failed to update user segments"
-// @Router /user_segments [patch]
-func (h *UserSegmentHandler) UpdateUserSegments(c echo.Context) error {
-	var req models.UpdateSegmentsRequest
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid request body"))
-	}
-
-	var ttl *time.Time
-
-	if req.TTL != nil {
-		parsedTTL, err := time.Parse(time.RFC3339, *req.TTL)
-		if err != nil {
-			return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid TTL format"))
-		}
-
-		ttl = &parsedTTL
-	}
-
-	if err := h.service.UpdateUserSegments(req.UserID, req.AddSegments, req.DeleteSegments, ttl); err != nil {
-		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to update user segments", err))
-	}
-
-	return c.JSON(http.StatusOK, models.Response{
-		Message: "User segments updated successfully",
-	})
-}
+package handlers
+
+import (
+	"API/internal/audit"
+	"API/internal/models"
+	"API/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultSegmentUsersLimit = 100
+	maxSegmentUsersLimit     = 1000
+)
+
+type UserSegmentHandler struct {
+	service *services.UserSegmentService
+}
+
+func NewUserSegmentHandler(service *services.UserSegmentService) *UserSegmentHandler {
+	return &UserSegmentHandler{service: service}
+}
+
+// GetUserSegments retrieves segments for a specific user.
+// @Summary Get segments for a user
+// @Description Retrieves all segments associated with a user by user ID.
+// @Tags UserSegments
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Success 200 {array} models.UserSegment "List of user segments"
+// @Failure 400 {object} models.ResponseError "Invalid user ID"
+// @Failure 500 {object} models.ResponseError "Failed to retrieve user segments"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user_segments/{user_id} [get]
+func (h *UserSegmentHandler) GetUserSegments(c echo.Context) error {
+	userIDParam := c.Param("user_id")
+
+	// Преобразуем его в int64
+	userID, err := strconv.Atoi(userIDParam)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("Invalid user ID"))
+	}
+
+	segments, err := h.service.GetUserSegments(c.Request().Context(), int64(userID))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to get user segments", err))
+	}
+
+	return c.JSON(http.StatusOK, segments)
+}
+
+// GetAllUserSegments retrieves all user-segment relationships.
+// @Summary Retrieve all user-segment relationships
+// @Description Fetches all user-to-segment mappings stored in the database.
+// @Tags UserSegments
+// @Produce json
+// @Success 200 {array} models.UserSegment "List of user-segment relationships"
+// @Failure 500 {object} models.ResponseError "Failed to retrieve user segments"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user_segments [get]
+func (h *UserSegmentHandler) GetAllUserSegments(c echo.Context) error {
+	segments, err := h.service.GetAllUserSegments(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to get all user segments", err))
+	}
+
+	return c.JSON(http.StatusOK, segments)
+}
+
+// UpdateUserSegments modifies a user's segments.
+// @Summary Update a user's segments
+// @Description Adds or removes segments associated with a user and reports the outcome of every
+// @Description slug: added, already_member, removed, not_member, unknown_segment or archived.
+// @Description In strict mode (default) an unknown or archived segment rejects the whole update
+// @Description with 422; in best_effort mode the other slugs are still applied.
+// @Tags UserSegments
+// @Accept json
+// @Produce json
+// @Param userSegment body models.UpdateSegmentsRequest true "Segments to add or remove"
+// @Success 200 {object} models.Response{data=models.UpdateSegmentsResult} "User segments updated successfully"
+// @Failure 400 {object} models.ResponseError "Invalid request payload or overlapping slugs"
+// @Failure 403 {object} models.ResponseError "Caller may not change a segment"
+// @Failure 404 {object} models.ResponseError "User not found"
+// @Failure 422 {object} models.Response{data=models.UpdateSegmentsResult} "Unknown or archived segments in strict mode"
+// @Failure 500 {object} models.ResponseError "Failed to update user segments"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user_segments [patch]
+func (h *UserSegmentHandler) UpdateUserSegments(c echo.Context) error {
+	var req models.UpdateSegmentsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid request body"))
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr(err.Error()))
+	}
+
+	ttl, err := req.ParseTTL()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid TTL format"))
+	}
+
+	ctx := audit.WithReason(c.Request().Context(), req.Reason)
+	result, err := h.service.UpdateUserSegments(ctx, req.UserID, req.AddSegments, req.DeleteSegments, ttl, req.Mode)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrMembershipRejected):
+			return c.JSON(http.StatusUnprocessableEntity, models.Response{
+				Message: "User segments were not updated: unknown or archived segments",
+				Data:    result,
+			})
+		case errors.Is(err, models.ErrUserNotFound):
+			return c.JSON(http.StatusNotFound, models.ResponseErr("user not found", err))
+		case errors.Is(err, models.ErrForbidden):
+			return c.JSON(http.StatusForbidden, models.ResponseErr("not allowed to update user segments", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to update user segments", err))
+	}
+
+	return c.JSON(http.StatusOK, models.Response{
+		Message: "User segments updated successfully",
+		Data:    result,
+	})
+}
+
+// GetSegmentUsers lists users belonging to a segment.
+// @Summary Get users of a segment
+// @Description Returns members of a segment ordered by user ID using cursor pagination.
+// @Description Pass next_cursor from the previous page as cursor to get the next one.
+// @Tags UserSegments
+// @Produce json
+// @Param slug path string true "Segment slug"
+// @Param cursor query int false "Return users with ID greater than cursor"
+// @Param limit query int false "Page size, 100 by default, at most 1000"
+// @Param include_ttl query bool false "Include membership TTL"
+// @Param count_only query bool false "Return only the number of members"
+// @Success 200 {object} models.SegmentUsers "Segment members"
+// @Failure 400 {object} models.ResponseError "Invalid query parameters"
+// @Failure 404 {object} models.ResponseError "Segment not found"
+// @Failure 500 {object} models.ResponseError "Failed to retrieve segment users"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /segments/{slug}/users [get]
+func (h *UserSegmentHandler) GetSegmentUsers(c echo.Context) error {
+	slug := models.Slug(c.Param("slug"))
+
+	var params struct {
+		Cursor     int64 `query:"cursor"`
+		Limit      int   `query:"limit"`
+		IncludeTTL bool  `query:"include_ttl"`
+		CountOnly  bool  `query:"count_only"`
+	}
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &params); err != nil {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("invalid query parameters"))
+	}
+
+	if params.Limit == 0 {
+		params.Limit = defaultSegmentUsersLimit
+	}
+	if params.Limit < 0 || params.Limit > maxSegmentUsersLimit {
+		return c.JSON(http.StatusBadRequest, models.ResponseErr("limit must be between 1 and 1000"))
+	}
+
+	var (
+		result models.SegmentUsers
+		err    error
+	)
+	if params.CountOnly {
+		result, err = h.service.CountSegmentUsers(c.Request().Context(), slug)
+	} else {
+		result, err = h.service.GetSegmentUsers(c.Request().Context(), slug, params.Cursor, params.Limit, params.IncludeTTL)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrSegmentNotFound) {
+			return c.JSON(http.StatusNotFound, models.ResponseErr("segment not found", err))
+		}
+		return c.JSON(http.StatusInternalServerError, models.ResponseErr("failed to get segment users", err))
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
@@ -1,45 +1,233 @@
-package kafka
-
-import (
-	"log"
-
-	"github.com/IBM/sarama"
-)
-
-type Consumer struct {
-	consumer sarama.Consumer
-}
-
-func NewConsumer(brokers []string) (*Consumer, error) {
-	config := GetKafkaConfig()
-	consumer, err := sarama.NewConsumer(brokers, config)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Consumer{consumer: consumer}, nil
-}
-
-func (c *Consumer) ConsumeMessages(topic string, processFunc func(message *sarama.ConsumerMessage) error) {
-	partitionConsumer, err := c.consumer.ConsumePartition(topic, 0, sarama.OffsetNewest)
-	if err != nil {
-		log.Fatalf("Failed to start consumer for topic %s: %v", topic, err)
-	}
-	defer partitionConsumer.Close()
-
-	log.Printf("Started consumer for topic: %s", topic)
-
-	for msg := range partitionConsumer.Messages() {
-		log.Printf("Message received from topic %s: %s", topic, string(msg.Value))
-
-		if err := processFunc(msg); err != nil {
-			log.Printf("Failed to process message: %v", err)
-		}
-	}
-}
-
-func (c *Consumer) Close() {
-	if err := c.consumer.Close(); err != nil {
-		log.Printf("Failed to close consumer: %v", err)
-	}
-}
+package kafka
+
+import (
+	"API/internal/logging"
+	"API/internal/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/IBM/sarama"
+)
+
+// ProcessFunc handles a single consumed message. ctx carries the trace
+// context of the message and is not cancelled when the consumer stops, so a
+// message being processed on shutdown is finished first.
+type ProcessFunc func(ctx context.Context, message *sarama.ConsumerMessage) error
+
+// Consumer is a consumer group member. Handlers are registered per topic with
+// Handle and all registered topics are consumed by Run, so partitions are
+// balanced between replicas sharing the group ID and offsets are committed
+// to Kafka. A message whose handler keeps failing after the retry policy is
+// exhausted is published to the <topic>.dlq topic.
+type Consumer struct {
+	groupID  string
+	group    sarama.ConsumerGroup
+	admin    sarama.ClusterAdmin
+	client   sarama.Client
+	handlers map[string]ProcessFunc
+	retry    RetryPolicy
+	producer *Producer
+	logger   *slog.Logger
+}
+
+func NewConsumer(brokers []string, groupID string, retry RetryPolicy, producer *Producer, logger *slog.Logger) (*Consumer, error) {
+	config := GetKafkaConfig()
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := sarama.NewConsumerGroupFromClient(groupID, client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	// The admin owns the client and closes it.
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		group.Close()
+		client.Close()
+		return nil, err
+	}
+
+	return &Consumer{
+		groupID:  groupID,
+		group:    group,
+		admin:    admin,
+		client:   client,
+		handlers: make(map[string]ProcessFunc),
+		retry:    retry,
+		producer: producer,
+		logger:   logger,
+	}, nil
+}
+
+// Handle registers processFunc for messages of topic. It must be called before Run.
+func (c *Consumer) Handle(topic string, processFunc ProcessFunc) {
+	c.handlers[topic] = processFunc
+}
+
+// Run consumes all registered topics until ctx is cancelled or the consumer is
+// closed. A rebalance ends Consume, so it is called again in a loop.
+func (c *Consumer) Run(ctx context.Context) {
+	topics := make([]string, 0, len(c.handlers))
+	for topic := range c.handlers {
+		topics = append(topics, topic)
+	}
+
+	go func() {
+		for err := range c.group.Errors() {
+			c.logger.Error("Consumer group error", "error", err)
+		}
+	}()
+
+	c.logger.Info("Started consumer", "topics", topics)
+
+	handler := &groupHandler{consumer: c}
+	for {
+		if err := c.group.Consume(ctx, topics, handler); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return
+			}
+			c.logger.Error("Consumer group failed", "error", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// Ping checks that the brokers are reachable from the consumer client.
+func (c *Consumer) Ping(ctx context.Context) error {
+	return pingBrokers(ctx, c.client)
+}
+
+// Lag returns the number of messages of the registered topics that the group
+// has not committed yet. Partitions without a committed offset count from the
+// oldest retained message.
+func (c *Consumer) Lag(ctx context.Context) (int64, error) {
+	return withContext(ctx, func() (int64, error) {
+		topicPartitions := make(map[string][]int32, len(c.handlers))
+		for topic := range c.handlers {
+			partitions, err := c.client.Partitions(topic)
+			if err != nil {
+				return 0, fmt.Errorf("failed to get partitions of %s: %w", topic, err)
+			}
+			topicPartitions[topic] = partitions
+		}
+
+		committed, err := c.admin.ListConsumerGroupOffsets(c.groupID, topicPartitions)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get committed offsets: %w", err)
+		}
+		if committed.Err != sarama.ErrNoError {
+			return 0, fmt.Errorf("failed to get committed offsets: %w", committed.Err)
+		}
+
+		var lag int64
+		for topic, partitions := range topicPartitions {
+			for _, partition := range partitions {
+				newest, err := c.client.GetOffset(topic, partition, sarama.OffsetNewest)
+				if err != nil {
+					return 0, err
+				}
+
+				offset := int64(-1)
+				if block := committed.GetBlock(topic, partition); block != nil {
+					offset = block.Offset
+				}
+				if offset < 0 {
+					if offset, err = c.client.GetOffset(topic, partition, sarama.OffsetOldest); err != nil {
+						return 0, err
+					}
+				}
+				lag += max(newest-offset, 0)
+			}
+		}
+		return lag, nil
+	})
+}
+
+func (c *Consumer) Close() {
+	if err := c.group.Close(); err != nil {
+		c.logger.Error("Failed to close consumer", "error", err)
+	}
+	if err := c.admin.Close(); err != nil {
+		c.logger.Error("Failed to close consumer client", "error", err)
+	}
+}
+
+type groupHandler struct {
+	consumer *Consumer
+}
+
+func (h *groupHandler) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *groupHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// process handles msg with retries and marks it as consumed, after moving it
+// to the dead-letter topic if all attempts failed. An error ends the claim.
+// An attempt runs to completion when the session ends; the session is only
+// checked between attempts, so no further attempt starts.
+func (h *groupHandler) process(session sarama.ConsumerGroupSession, processFunc ProcessFunc, msg *sarama.ConsumerMessage) error {
+	ctx, span := startConsumerSpan(session.Context(), msg)
+	ctx = context.WithoutCancel(ctx)
+	if requestID := headerMap(msg)[logging.RequestIDKey]; requestID != "" {
+		ctx = logging.WithRequestID(ctx, requestID)
+	}
+	h.consumer.logger.DebugContext(ctx, "Message received",
+		"topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "value", string(msg.Value))
+
+	attempts, err := h.consumer.retry.Do(session.Context(), func() error {
+		return processFunc(ctx, msg)
+	})
+	defer func() { endSpan(span, err) }()
+
+	if err != nil {
+		if session.Context().Err() != nil {
+			// The session is ending, the message will be consumed again.
+			return nil
+		}
+		h.consumer.logger.ErrorContext(ctx, "Failed to process message, moving to dead-letter topic",
+			"topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset,
+			"attempts", attempts, "dlq", msg.Topic+DLQSuffix, "error", err)
+		metrics.KafkaConsumeErrors.WithLabelValues(msg.Topic).Inc()
+
+		// Without the dead-letter copy the offset must not be committed,
+		// ending the claim makes the group consume the message again.
+		if err := h.consumer.producer.Send(ctx, deadLetter(msg, err, attempts)); err != nil {
+			return fmt.Errorf("failed to publish message to dead-letter topic: %w", err)
+		}
+	}
+	session.MarkMessage(msg, "")
+	return nil
+}
+
+func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	processFunc := h.consumer.handlers[claim.Topic()]
+
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok || session.Context().Err() != nil {
+				// Stop between messages; an unmarked message is consumed again.
+				return nil
+			}
+			metrics.KafkaMessagesConsumed.WithLabelValues(msg.Topic).Inc()
+
+			if err := h.process(session, processFunc, msg); err != nil {
+				return err
+			}
+
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
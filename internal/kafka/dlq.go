@@ -0,0 +1,218 @@
+package kafka
+
+import (
+	"API/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// DLQSuffix is appended to a topic name to get its dead-letter topic.
+const DLQSuffix = ".dlq"
+
+const (
+	HeaderError           = "dlq_error"
+	HeaderAttempts        = "dlq_attempts"
+	HeaderSourceTopic     = "dlq_source_topic"
+	HeaderSourcePartition = "dlq_source_partition"
+	HeaderSourceOffset    = "dlq_source_offset"
+)
+
+const dlqReadTimeout = 5 * time.Second
+
+// deadLetter builds the dead-letter copy of msg. The original key, value and
+// headers are kept and the failure is described in additional headers.
+func deadLetter(msg *sarama.ConsumerMessage, err error, attempts int) *sarama.ProducerMessage {
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+5)
+	for _, h := range msg.Headers {
+		headers = append(headers, *h)
+	}
+	headers = append(headers,
+		sarama.RecordHeader{Key: []byte(HeaderError), Value: []byte(err.Error())},
+		sarama.RecordHeader{Key: []byte(HeaderAttempts), Value: []byte(strconv.Itoa(attempts))},
+		sarama.RecordHeader{Key: []byte(HeaderSourceTopic), Value: []byte(msg.Topic)},
+		sarama.RecordHeader{Key: []byte(HeaderSourcePartition), Value: []byte(strconv.FormatInt(int64(msg.Partition), 10))},
+		sarama.RecordHeader{Key: []byte(HeaderSourceOffset), Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+
+	return &sarama.ProducerMessage{
+		Topic:   msg.Topic + DLQSuffix,
+		Key:     sarama.ByteEncoder(msg.Key),
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	}
+}
+
+// DLQ reads dead-letter topics and replays their messages.
+type DLQ struct {
+	client   sarama.Client
+	consumer sarama.Consumer
+	producer *Producer
+	logger   *slog.Logger
+
+	// mu serializes reads, a partition can be consumed only once at a time.
+	mu sync.Mutex
+}
+
+func NewDLQ(brokers []string, producer *Producer, logger *slog.Logger) (*DLQ, error) {
+	client, err := sarama.NewClient(brokers, GetKafkaConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &DLQ{
+		client:   client,
+		consumer: consumer,
+		producer: producer,
+		logger:   logger,
+	}, nil
+}
+
+// List returns up to limit of the latest messages of every partition of the
+// dead-letter topic of topic.
+func (d *DLQ) List(topic string, limit int) ([]models.DLQMessage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dlqTopic := topic + DLQSuffix
+	partitions, err := d.client.Partitions(dlqTopic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get partitions of %s: %w", dlqTopic, err)
+	}
+
+	messages := make([]models.DLQMessage, 0)
+	for _, partition := range partitions {
+		oldest, err := d.client.GetOffset(dlqTopic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return nil, err
+		}
+		newest, err := d.client.GetOffset(dlqTopic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, err
+		}
+
+		msgs, err := d.read(dlqTopic, partition, max(oldest, newest-int64(limit)), newest)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			messages = append(messages, toDLQMessage(msg))
+		}
+	}
+	return messages, nil
+}
+
+// Replay publishes the dead-letter message at partition and offset back to
+// the topic it was consumed from.
+func (d *DLQ) Replay(ctx context.Context, topic string, partition int32, offset int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	msgs, err := d.read(topic+DLQSuffix, partition, offset, offset+1)
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 || msgs[0].Offset != offset {
+		return models.ErrDLQMessageNotFound
+	}
+	msg := msgs[0]
+
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers))
+	for _, h := range msg.Headers {
+		if !strings.HasPrefix(string(h.Key), "dlq_") {
+			headers = append(headers, *h)
+		}
+	}
+
+	return d.producer.Send(ctx, &sarama.ProducerMessage{
+		Topic:   topic,
+		Key:     sarama.ByteEncoder(msg.Key),
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	})
+}
+
+// read returns the messages of the partition with offsets in [from, to).
+func (d *DLQ) read(topic string, partition int32, from, to int64) ([]*sarama.ConsumerMessage, error) {
+	if from >= to {
+		return nil, nil
+	}
+
+	pc, err := d.consumer.ConsumePartition(topic, partition, from)
+	if err != nil {
+		if errors.Is(err, sarama.ErrOffsetOutOfRange) {
+			return nil, models.ErrDLQMessageNotFound
+		}
+		return nil, fmt.Errorf("failed to read %s/%d: %w", topic, partition, err)
+	}
+	defer pc.Close()
+
+	var msgs []*sarama.ConsumerMessage
+	timeout := time.After(dlqReadTimeout)
+	for {
+		select {
+		case msg := <-pc.Messages():
+			if msg.Offset >= to {
+				return msgs, nil
+			}
+			msgs = append(msgs, msg)
+			if msg.Offset == to-1 {
+				return msgs, nil
+			}
+		case err := <-pc.Errors():
+			return nil, err
+		case <-timeout:
+			return msgs, nil
+		}
+	}
+}
+
+func (d *DLQ) Close() {
+	if err := d.consumer.Close(); err != nil {
+		d.logger.Error("Failed to close DLQ consumer", "error", err)
+	}
+	if err := d.client.Close(); err != nil {
+		d.logger.Error("Failed to close DLQ client", "error", err)
+	}
+}
+
+func toDLQMessage(msg *sarama.ConsumerMessage) models.DLQMessage {
+	m := models.DLQMessage{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       string(msg.Key),
+		Value:     string(msg.Value),
+		Timestamp: msg.Timestamp,
+	}
+	for _, h := range msg.Headers {
+		value := string(h.Value)
+		switch string(h.Key) {
+		case HeaderError:
+			m.Error = value
+		case HeaderAttempts:
+			m.Attempts, _ = strconv.Atoi(value)
+		case HeaderSourceTopic:
+			m.SourceTopic = value
+		case HeaderSourcePartition:
+			partition, _ := strconv.ParseInt(value, 10, 32)
+			m.SourcePartition = int32(partition)
+		case HeaderSourceOffset:
+			m.SourceOffset, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	return m
+}
@@ -0,0 +1,39 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadLetter(t *testing.T) {
+	msg := &sarama.ConsumerMessage{
+		Topic:     "segment_expiry",
+		Partition: 2,
+		Offset:    42,
+		Key:       []byte("1000"),
+		Value:     []byte(`{"user_id":1000}`),
+	}
+
+	dl := deadLetter(msg, errors.New("invalid expire_at format"), 5)
+
+	assert.Equal(t, "segment_expiry.dlq", dl.Topic)
+
+	value, err := dl.Value.Encode()
+	assert.NoError(t, err)
+
+	consumed := &sarama.ConsumerMessage{Topic: dl.Topic, Key: msg.Key, Value: value}
+	for i := range dl.Headers {
+		consumed.Headers = append(consumed.Headers, &dl.Headers[i])
+	}
+
+	parsed := toDLQMessage(consumed)
+	assert.Equal(t, "invalid expire_at format", parsed.Error)
+	assert.Equal(t, 5, parsed.Attempts)
+	assert.Equal(t, "segment_expiry", parsed.SourceTopic)
+	assert.Equal(t, int32(2), parsed.SourcePartition)
+	assert.Equal(t, int64(42), parsed.SourceOffset)
+	assert.Equal(t, `{"user_id":1000}`, parsed.Value)
+}
@@ -0,0 +1,42 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/IBM/sarama"
+)
+
+// pingBrokers refreshes the cluster metadata through client, which fails when
+// no broker is reachable.
+func pingBrokers(ctx context.Context, client sarama.Client) error {
+	_, err := withContext(ctx, func() (struct{}, error) {
+		if err := client.RefreshMetadata(); err != nil {
+			return struct{}{}, fmt.Errorf("brokers unreachable: %w", err)
+		}
+		return struct{}{}, nil
+	})
+	return err
+}
+
+// withContext runs fn, which cannot be cancelled itself, and stops waiting for
+// it when ctx is done.
+func withContext[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,36 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithContext(t *testing.T) {
+	t.Run("should return the result of fn", func(t *testing.T) {
+		value, err := withContext(context.Background(), func() (int64, error) {
+			return 42, nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(42), value)
+	})
+
+	t.Run("should stop waiting when context expires", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		release := make(chan struct{})
+		defer close(release)
+
+		value, err := withContext(ctx, func() (int64, error) {
+			<-release
+			return 42, nil
+		})
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Zero(t, value)
+	})
+}
@@ -1,51 +1,86 @@
-package kafka
-
-import (
-	"encoding/json"
-	"log"
-
-	"github.com/IBM/sarama"
-)
-
-type Producer struct {
-	producer sarama.SyncProducer
-}
-
-func NewProducer(brokers []string) (*Producer, error) {
-	config := GetKafkaConfig()
-
-	producer, err := sarama.NewSyncProducer(brokers, config)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Producer{producer: producer}, nil
-}
-
-func (p *Producer) SendMessage(topic, key string, data interface{}) error {
-
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return err
-	}
-
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Key:   (sarama.StringEncoder)(key),
-		Value: (sarama.ByteEncoder)(jsonData),
-	}
-
-	partition, offset, err := p.producer.SendMessage(msg)
-	if err != nil {
-		return err
-	}
-
-	log.Printf("Message sent to topic %s, partition %d, offset %d", topic, partition, offset)
-	return nil
-}
-
-func (p *Producer) Close() {
-	if err := p.producer.Close(); err != nil {
-		log.Printf("Failed to close producer: %v", err)
-	}
-}
+package kafka
+
+import (
+	"API/internal/logging"
+	"API/internal/metrics"
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/IBM/sarama"
+)
+
+type Producer struct {
+	client   sarama.Client
+	producer sarama.SyncProducer
+	logger   *slog.Logger
+}
+
+func NewProducer(brokers []string, logger *slog.Logger) (*Producer, error) {
+	config := GetKafkaConfig()
+
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &Producer{client: client, producer: producer, logger: logger}, nil
+}
+
+func (p *Producer) SendMessage(ctx context.Context, topic, key string, data interface{}) error {
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Key:   (sarama.StringEncoder)(key),
+		Value: (sarama.ByteEncoder)(jsonData),
+	}
+
+	return p.Send(ctx, msg)
+}
+
+// Send publishes a prepared message, e.g. one that carries headers. The trace
+// context of the publish span and the request ID of ctx are added to the
+// headers.
+func (p *Producer) Send(ctx context.Context, msg *sarama.ProducerMessage) (err error) {
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		producerHeaders{msg}.SetDefault(logging.RequestIDKey, requestID)
+	}
+
+	_, span := startProducerSpan(ctx, msg)
+	defer func() { endSpan(span, err) }()
+
+	partition, offset, err := p.producer.SendMessage(msg)
+	if err != nil {
+		metrics.KafkaProduceErrors.WithLabelValues(msg.Topic).Inc()
+		return err
+	}
+	metrics.KafkaMessagesProduced.WithLabelValues(msg.Topic).Inc()
+
+	p.logger.DebugContext(ctx, "Message sent", "topic", msg.Topic, "partition", partition, "offset", offset)
+	return nil
+}
+
+// Ping checks that the brokers are reachable from the producer client.
+func (p *Producer) Ping(ctx context.Context) error {
+	return pingBrokers(ctx, p.client)
+}
+
+func (p *Producer) Close() {
+	if err := p.producer.Close(); err != nil {
+		p.logger.Error("Failed to close producer", "error", err)
+	}
+	if err := p.client.Close(); err != nil {
+		p.logger.Error("Failed to close producer client", "error", err)
+	}
+}
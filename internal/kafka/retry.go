@@ -0,0 +1,48 @@
+package kafka
+
+import (
+	"context"
+	"time"
+)
+
+// RetryPolicy controls how often a failed message is processed again before
+// it is moved to the dead-letter topic.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Backoff returns the delay before the given retry, doubling from
+// InitialBackoff up to MaxBackoff.
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < retry && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxBackoff)
+}
+
+// Do calls fn until it succeeds, MaxAttempts is reached or ctx is cancelled,
+// and returns the number of attempts made with the last error. ctx is only
+// checked between attempts; fn gets its own context.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) (int, error) {
+	attempts := max(p.MaxAttempts, 1)
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(); err == nil {
+			return attempt, nil
+		}
+		if attempt == attempts {
+			return attempt, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(p.Backoff(attempt)):
+		}
+	}
+	return attempts, err
+}
@@ -0,0 +1,57 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	assert.Equal(t, 100*time.Millisecond, policy.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.Backoff(2))
+	assert.Equal(t, 800*time.Millisecond, policy.Backoff(4))
+	assert.Equal(t, time.Second, policy.Backoff(10))
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	t.Run("should stop after success", func(t *testing.T) {
+		calls := 0
+		attempts, err := policy.Do(context.Background(), func() error {
+			calls++
+			if calls < 2 {
+				return errors.New("temporary error")
+			}
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+	})
+
+	t.Run("should give up after max attempts", func(t *testing.T) {
+		attempts, err := policy.Do(context.Background(), func() error {
+			return errors.New("permanent error")
+		})
+
+		assert.EqualError(t, err, "permanent error")
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("should finish the attempt but not retry once ctx is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		attempts, err := policy.Do(ctx, func() error {
+			cancel()
+			return errors.New("temporary error")
+		})
+
+		assert.EqualError(t, err, "temporary error")
+		assert.Equal(t, 1, attempts)
+	})
+}
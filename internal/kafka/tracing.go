@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"API/internal/tracing"
+	"context"
+	"strconv"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// producerHeaders adapts the headers of a message being produced to the
+// propagation.TextMapCarrier interface. Set replaces an existing header, so a
+// republished message carries the context of the new span only.
+type producerHeaders struct {
+	msg *sarama.ProducerMessage
+}
+
+func (c producerHeaders) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c producerHeaders) Set(key, value string) {
+	for i, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+// SetDefault sets the header unless the message already has it.
+func (c producerHeaders) SetDefault(key, value string) {
+	if c.Get(key) == "" {
+		c.Set(key, value)
+	}
+}
+
+func (c producerHeaders) Keys() []string {
+	keys := make([]string, len(c.msg.Headers))
+	for i, h := range c.msg.Headers {
+		keys[i] = string(h.Key)
+	}
+	return keys
+}
+
+// headerMap returns the headers of a consumed message as a map.
+func headerMap(msg *sarama.ConsumerMessage) map[string]string {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	return headers
+}
+
+// startProducerSpan starts the span of publishing msg and injects its trace
+// context into the message headers.
+func startProducerSpan(ctx context.Context, msg *sarama.ProducerMessage) (context.Context, trace.Span) {
+	ctx, span := tracing.Tracer().Start(ctx, msg.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingOperationTypePublish,
+		),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, producerHeaders{msg})
+	return ctx, span
+}
+
+// startConsumerSpan starts the span of processing msg as a child of the trace
+// context found in its headers.
+func startConsumerSpan(ctx context.Context, msg *sarama.ConsumerMessage) (context.Context, trace.Span) {
+	ctx = tracing.Extract(ctx, headerMap(msg))
+	return tracing.Tracer().Start(ctx, msg.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingOperationTypeDeliver,
+			semconv.MessagingDestinationPartitionID(strconv.FormatInt(int64(msg.Partition), 10)),
+			attribute.Int64("messaging.kafka.offset", msg.Offset),
+		),
+	)
+}
+
+// endSpan records err on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
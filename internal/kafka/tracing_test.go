@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceContextPropagation(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01, 0x02, 0x03},
+		SpanID:     trace.SpanID{0x04, 0x05},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), parent)
+
+	msg := &sarama.ProducerMessage{
+		Topic: "user-segments",
+		Headers: []sarama.RecordHeader{
+			{Key: []byte("traceparent"), Value: []byte("00-0000000000000000000000000000000a-000000000000000b-01")},
+			{Key: []byte("request_id"), Value: []byte("req-1")},
+		},
+	}
+
+	_, span := startProducerSpan(ctx, msg)
+	span.End()
+
+	assert.Len(t, msg.Headers, 2, "existing traceparent must be replaced")
+
+	consumed := &sarama.ConsumerMessage{Topic: msg.Topic}
+	for i := range msg.Headers {
+		consumed.Headers = append(consumed.Headers, &msg.Headers[i])
+	}
+
+	consumerCtx, span := startConsumerSpan(context.Background(), consumed)
+	span.End()
+
+	assert.Equal(t, parent.TraceID(), trace.SpanContextFromContext(consumerCtx).TraceID())
+}
@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"API/internal/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("should reject unknown format", func(t *testing.T) {
+		_, err := New(config.LogConfig{Level: "info", Format: "xml"})
+		assert.Error(t, err)
+	})
+
+	t.Run("should reject unknown level", func(t *testing.T) {
+		_, err := New(config.LogConfig{Level: "loud", Format: FormatJSON})
+		assert.Error(t, err)
+	})
+}
+
+func TestContextHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(contextHandler{slog.NewJSONHandler(&buf, nil)})
+
+	t.Run("should add the request ID of the context", func(t *testing.T) {
+		buf.Reset()
+		ctx := WithRequestID(context.Background(), "req-1")
+
+		logger.InfoContext(ctx, "served")
+
+		var record map[string]any
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, "req-1", record[RequestIDKey])
+	})
+
+	t.Run("should log without request ID", func(t *testing.T) {
+		buf.Reset()
+
+		logger.Info("started")
+
+		var record map[string]any
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.NotContains(t, record, RequestIDKey)
+	})
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"API/internal/models"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const statsTimeout = 5 * time.Second
+
+var (
+	usersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "users"),
+		"Total number of users.", nil, nil)
+	segmentsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "segments"),
+		"Total number of segments.", nil, nil)
+	membershipsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "segment_memberships"),
+		"Number of users in a segment.", []string{"segment"}, nil)
+)
+
+// StatsSource returns the current entity counts.
+type StatsSource interface {
+	GetStats(ctx context.Context) (models.Stats, error)
+}
+
+// StatsCollector queries the entity counts on every scrape, so the gauges are
+// never stale. A failed query leaves the gauges out of the scrape.
+type StatsCollector struct {
+	source StatsSource
+	logger *slog.Logger
+}
+
+func NewStatsCollector(source StatsSource, logger *slog.Logger) *StatsCollector {
+	return &StatsCollector{source: source, logger: logger}
+}
+
+func (c *StatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- usersDesc
+	ch <- segmentsDesc
+	ch <- membershipsDesc
+}
+
+func (c *StatsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+
+	stats, err := c.source.GetStats(ctx)
+	if err != nil {
+		c.logger.Error("Failed to collect entity metrics", "error", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(usersDesc, prometheus.GaugeValue, float64(stats.Users))
+	ch <- prometheus.MustNewConstMetric(segmentsDesc, prometheus.GaugeValue, float64(stats.Segments))
+	for slug, count := range stats.Memberships {
+		ch <- prometheus.MustNewConstMetric(membershipsDesc, prometheus.GaugeValue, float64(count), string(slug))
+	}
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"API/internal/logging"
+	"API/internal/models"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type statsFunc func(ctx context.Context) (models.Stats, error)
+
+func (f statsFunc) GetStats(ctx context.Context) (models.Stats, error) {
+	return f(ctx)
+}
+
+func TestStatsCollector(t *testing.T) {
+	t.Run("should export entity counts", func(t *testing.T) {
+		collector := NewStatsCollector(statsFunc(func(context.Context) (models.Stats, error) {
+			return models.Stats{
+				Users:       3,
+				Segments:    1,
+				Memberships: map[models.Slug]int64{"VIDEO": 2},
+			}, nil
+		}), logging.Nop())
+
+		expected := `
+# HELP user_groups_segment_memberships Number of users in a segment.
+# TYPE user_groups_segment_memberships gauge
+user_groups_segment_memberships{segment="VIDEO"} 2
+# HELP user_groups_segments Total number of segments.
+# TYPE user_groups_segments gauge
+user_groups_segments 1
+# HELP user_groups_users Total number of users.
+# TYPE user_groups_users gauge
+user_groups_users 3
+`
+		assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+	})
+
+	t.Run("should skip gauges when the query fails", func(t *testing.T) {
+		collector := NewStatsCollector(statsFunc(func(context.Context) (models.Stats, error) {
+			return models.Stats{}, errors.New("database error")
+		}), logging.Nop())
+
+		assert.Equal(t, 0, testutil.CollectAndCount(collector))
+	})
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Scope grants access to a group of endpoints. Reading users, segments and
+// memberships only requires authentication.
+type Scope string
+
+const (
+	ScopeUsersWrite       Scope = "users:write"
+	ScopeSegmentsWrite    Scope = "segments:write"
+	ScopeMembershipsWrite Scope = "memberships:write"
+	ScopeReportsRead      Scope = "reports:read"
+	ScopeAdmin            Scope = "admin"
+)
+
+// Scopes lists every scope a credential can be granted.
+var Scopes = []Scope{
+	ScopeUsersWrite,
+	ScopeSegmentsWrite,
+	ScopeMembershipsWrite,
+	ScopeReportsRead,
+	ScopeAdmin,
+}
+
+// ValidateScopes returns an error naming the first unknown scope.
+func ValidateScopes(scopes []Scope) error {
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+const (
+	AuthMethodAPIKey = "api_key"
+	AuthMethodJWT    = "jwt"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string   // API key name or JWT subject
+	Method  string   // AuthMethodAPIKey or AuthMethodJWT
+	Scopes  []Scope  // Granted scopes
+	Teams   []string // Teams of a JWT subject
+}
+
+func (p Principal) HasScope(scope Scope) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// Is reports whether name is the subject or one of the teams of p.
+func (p Principal) Is(name string) bool {
+	return p.Subject == name || slices.Contains(p.Teams, name)
+}
+
+// APIKey describes a stored API key. The key itself is only known to the
+// client, the database keeps its hash.
+// @description API key without the secret.
+type APIKey struct {
+	ID         int64      `json:"id"`                                 // API key ID
+	Name       string     `json:"name" example:"billing"`             // Unique name, used as the principal subject
+	Prefix     string     `json:"prefix" example:"ugk_3f9a"`          // First characters of the key, to recognize it
+	Scopes     []Scope    `json:"scopes" example:"memberships:write"` // Granted scopes
+	CreatedAt  time.Time  `json:"created_at"`                         // Creation time
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`             // Last successful authentication, updated at most once a minute
+}
+
+// CreateAPIKeyRequest names a new API key and its scopes.
+type CreateAPIKeyRequest struct {
+	Name   string  `json:"name" example:"billing"`                       // Unique name of the key
+	Scopes []Scope `json:"scopes" example:"segments:write,reports:read"` // Granted scopes
+}
+
+// Normalize trims the name and checks that the request names known scopes.
+func (r *CreateAPIKeyRequest) Normalize() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(r.Scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	return ValidateScopes(r.Scopes)
+}
+
+// CreatedAPIKey is returned once when a key is created; the key cannot be
+// read again.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key" example:"ugk_3f9a..."` // Secret to send in the X-API-Key header
+}
@@ -0,0 +1,209 @@
+package models
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BulkJobStatus is the state of a bulk membership job.
+type BulkJobStatus string
+
+const (
+	BulkJobPending BulkJobStatus = "pending" // Waiting for a worker
+	BulkJobRunning BulkJobStatus = "running" // Rows are being applied
+	BulkJobDone    BulkJobStatus = "done"    // Every row was processed
+	BulkJobFailed  BulkJobStatus = "failed"  // Stopped by an error, see Error
+)
+
+// Finished reports whether the job will not change anymore.
+func (s BulkJobStatus) Finished() bool {
+	return s == BulkJobDone || s == BulkJobFailed
+}
+
+// BulkMembershipRequest adds and removes the same segments for many users.
+// The user IDs come from UserIDs in a JSON body, or from an NDJSON or CSV
+// body, in which case the other fields are query parameters.
+// @description Request payload for a bulk membership update.
+type BulkMembershipRequest struct {
+	UserIDs        []int64 `json:"user_ids" example:"1000,1001"`                                   // Users to update
+	AddSegments    []Slug  `json:"add_segments" query:"add_segments" example:"VOICE_MESSAGES"`     // Segments to add
+	DeleteSegments []Slug  `json:"delete_segments" query:"delete_segments" example:"CHAT_SUPPORT"` // Segments to delete
+	TTL            *string `json:"ttl" query:"ttl"`                                                // TTL of the added memberships, default NULL
+	Reason         string  `json:"reason,omitempty" query:"reason" example:"spring campaign"`      // Reason recorded in the history
+}
+
+// Validate requires at least one segment and rejects slugs that are both
+// added and deleted.
+func (r BulkMembershipRequest) Validate() error {
+	if len(r.AddSegments) == 0 && len(r.DeleteSegments) == 0 {
+		return fmt.Errorf("%w: no segments to add or delete", ErrInvalidMembership)
+	}
+	for _, slug := range r.AddSegments {
+		if slices.Contains(r.DeleteSegments, slug) {
+			return fmt.Errorf("%w: segment %s is both added and deleted", ErrInvalidMembership, slug)
+		}
+	}
+	return nil
+}
+
+// ParseTTL parses the optional RFC 3339 TTL.
+func (r BulkMembershipRequest) ParseTTL() (*time.Time, error) {
+	return UpdateSegmentsRequest{TTL: r.TTL}.ParseTTL()
+}
+
+// BulkJob is a bulk membership update applied in the background. Rows are
+// numbered from 1 in input order; ProcessedRows of them have been applied.
+// @description Bulk membership job and its progress.
+type BulkJob struct {
+	ID             int64         `json:"id" example:"42"`
+	JobID          int64         `json:"job_id,omitempty" example:"43"` // Background job applying the rows
+	Status         BulkJobStatus `json:"status" example:"running" enums:"pending,running,done,failed"`
+	AddSegments    []Slug        `json:"add_segments"`
+	DeleteSegments []Slug        `json:"delete_segments"`
+	TTL            *time.Time    `json:"ttl,omitempty"`
+	Reason         string        `json:"reason,omitempty"`
+	Actor          string        `json:"actor,omitempty"`      // Principal that submitted the job
+	RequestID      string        `json:"request_id,omitempty"` // Request that submitted the job
+	TotalRows      int64         `json:"total_rows" example:"500000"`
+	ProcessedRows  int64         `json:"processed_rows" example:"125000"`
+	FailedRows     int64         `json:"failed_rows" example:"3"` // Rows listed in the error report
+	Progress       float64       `json:"progress" example:"25"`   // Percentage of processed rows
+	Error          string        `json:"error,omitempty"`         // Why a failed job stopped
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	FinishedAt     *time.Time    `json:"finished_at,omitempty"`
+}
+
+// Percent returns done as a percentage of total rounded to two decimals;
+// nothing to do counts as complete.
+func Percent(done, total int64) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(done*10000/total) / 100
+}
+
+// BulkRow is one input row of a bulk job. A row that could not be parsed
+// has no user ID and an Error; processing may add an Error later.
+type BulkRow struct {
+	Row    int64  `json:"row"`
+	UserID int64  `json:"user_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkRowReader yields the rows of a bulk request one at a time, so large
+// bodies are never held in memory. Next returns io.EOF after the last row.
+// Malformed rows are returned with an Error; other errors abort the request.
+type BulkRowReader interface {
+	Next() (BulkRow, error)
+}
+
+// NewBulkRowReader returns the reader for a body of the given media type:
+// application/x-ndjson with one {"user_id": N} object per line or text/csv
+// with the user ID in the first column and an optional user_id header.
+func NewBulkRowReader(mediaType string, body io.Reader) (BulkRowReader, error) {
+	switch mediaType {
+	case "application/x-ndjson":
+		return &ndjsonRows{scanner: bufio.NewScanner(body)}, nil
+	case "text/csv":
+		reader := csv.NewReader(body)
+		reader.FieldsPerRecord = -1
+		reader.ReuseRecord = true
+		return &csvRows{reader: reader}, nil
+	}
+	return nil, fmt.Errorf("unsupported media type %q", mediaType)
+}
+
+// NewUserIDRows returns a reader over user IDs that are already in memory.
+func NewUserIDRows(userIDs []int64) BulkRowReader {
+	return &userIDRows{userIDs: userIDs}
+}
+
+type userIDRows struct {
+	userIDs []int64
+	next    int
+}
+
+func (r *userIDRows) Next() (BulkRow, error) {
+	if r.next == len(r.userIDs) {
+		return BulkRow{}, io.EOF
+	}
+	r.next++
+	return bulkRow(int64(r.next), r.userIDs[r.next-1]), nil
+}
+
+type ndjsonRows struct {
+	scanner *bufio.Scanner
+	row     int64
+}
+
+func (r *ndjsonRows) Next() (BulkRow, error) {
+	for r.scanner.Scan() {
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+		r.row++
+
+		var item struct {
+			UserID *int64 `json:"user_id"`
+		}
+		if err := json.Unmarshal([]byte(line), &item); err != nil {
+			return BulkRow{Row: r.row, Error: "invalid JSON"}, nil
+		}
+		if item.UserID == nil {
+			return BulkRow{Row: r.row, Error: "user_id is required"}, nil
+		}
+		return bulkRow(r.row, *item.UserID), nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return BulkRow{}, err
+	}
+	return BulkRow{}, io.EOF
+}
+
+type csvRows struct {
+	reader *csv.Reader
+	row    int64
+}
+
+func (r *csvRows) Next() (BulkRow, error) {
+	for {
+		record, err := r.reader.Read()
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			r.row++
+			return BulkRow{Row: r.row, Error: "invalid CSV"}, nil
+		}
+		if err != nil {
+			return BulkRow{}, err
+		}
+
+		field := strings.TrimSpace(record[0])
+		if r.row == 0 && strings.EqualFold(field, "user_id") {
+			continue
+		}
+		r.row++
+
+		userID, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return BulkRow{Row: r.row, Error: "invalid user_id"}, nil
+		}
+		return bulkRow(r.row, userID), nil
+	}
+}
+
+func bulkRow(row, userID int64) BulkRow {
+	if userID <= 0 {
+		return BulkRow{Row: row, Error: "invalid user_id"}
+	}
+	return BulkRow{Row: row, UserID: userID}
+}
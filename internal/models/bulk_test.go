@@ -0,0 +1,115 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readBulkRows(t *testing.T, rows BulkRowReader) []BulkRow {
+	t.Helper()
+	var result []BulkRow
+	for {
+		row, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			return result
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		result = append(result, row)
+	}
+}
+
+func TestBulkRowReader(t *testing.T) {
+	tests := []struct {
+		name      string
+		mediaType string
+		body      string
+		want      []BulkRow
+	}{
+		{
+			name:      "ndjson",
+			mediaType: "application/x-ndjson",
+			body:      "{\"user_id\": 1000}\n\n{\"id\": 1}\nnot json\n{\"user_id\": -5}\n",
+			want: []BulkRow{
+				{Row: 1, UserID: 1000},
+				{Row: 2, Error: "user_id is required"},
+				{Row: 3, Error: "invalid JSON"},
+				{Row: 4, Error: "invalid user_id"},
+			},
+		},
+		{
+			name:      "csv with header",
+			mediaType: "text/csv",
+			body:      "user_id,name\n1000,alice\nbob\n1001\n",
+			want: []BulkRow{
+				{Row: 1, UserID: 1000},
+				{Row: 2, Error: "invalid user_id"},
+				{Row: 3, UserID: 1001},
+			},
+		},
+		{
+			name:      "csv without header",
+			mediaType: "text/csv",
+			body:      "1000\n1001",
+			want:      []BulkRow{{Row: 1, UserID: 1000}, {Row: 2, UserID: 1001}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := NewBulkRowReader(tt.mediaType, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got := readBulkRows(t, rows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected rows %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("user ids", func(t *testing.T) {
+		want := []BulkRow{{Row: 1, UserID: 1000}, {Row: 2, Error: "invalid user_id"}}
+		if got := readBulkRows(t, NewUserIDRows([]int64{1000, 0})); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected rows %v, got %v", want, got)
+		}
+	})
+
+	t.Run("unsupported media type", func(t *testing.T) {
+		if _, err := NewBulkRowReader("text/plain", strings.NewReader("")); err == nil {
+			t.Errorf("Expected an error for text/plain")
+		}
+	})
+}
+
+func TestBulkMembershipRequestValidate(t *testing.T) {
+	if err := (BulkMembershipRequest{}).Validate(); !errors.Is(err, ErrInvalidMembership) {
+		t.Errorf("Expected ErrInvalidMembership without segments, got %v", err)
+	}
+	overlap := BulkMembershipRequest{AddSegments: []Slug{"VIDEO"}, DeleteSegments: []Slug{"VIDEO"}}
+	if err := overlap.Validate(); !errors.Is(err, ErrInvalidMembership) {
+		t.Errorf("Expected ErrInvalidMembership for overlapping slugs, got %v", err)
+	}
+	if err := (BulkMembershipRequest{AddSegments: []Slug{"VIDEO"}}).Validate(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		done, total int64
+		want        float64
+	}{
+		{0, 0, 100},
+		{0, 3, 0},
+		{1, 3, 33.33},
+		{3, 3, 100},
+	}
+	for _, tt := range tests {
+		if got := Percent(tt.done, tt.total); got != tt.want {
+			t.Errorf("Expected Percent(%d, %d) = %v, got %v", tt.done, tt.total, tt.want, got)
+		}
+	}
+}
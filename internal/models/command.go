@@ -0,0 +1,71 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	CommandStatusOK    = "ok"
+	CommandStatusError = "error"
+)
+
+// MembershipCommand is a membership change requested by another service
+// through the command topic. It carries the same fields as the PATCH
+// /user_segments body; a single change can also be sent with action and
+// segment.
+type MembershipCommand struct {
+	UpdateSegmentsRequest
+	RequestID string `json:"request_id"` // Client-supplied ID echoed in the reply
+	Action    string `json:"action"`     // "add" or "delete" ("remove" is accepted too)
+	Segment   Slug   `json:"segment"`    // Segment for action
+}
+
+// Normalize folds action and segment into the add and delete lists.
+func (c *MembershipCommand) Normalize() error {
+	if c.Action == "" {
+		return nil
+	}
+	if c.Segment == "" {
+		return fmt.Errorf("segment is required for action %q", c.Action)
+	}
+
+	switch c.Action {
+	case ActionAdd:
+		c.AddSegments = append(c.AddSegments, c.Segment)
+	case ActionDelete, "remove":
+		c.DeleteSegments = append(c.DeleteSegments, c.Segment)
+	default:
+		return fmt.Errorf("unknown action %q", c.Action)
+	}
+	return nil
+}
+
+// CommandReply acknowledges a MembershipCommand on the reply topic.
+type CommandReply struct {
+	RequestID string        `json:"request_id"`
+	UserID    int64         `json:"user_id"`
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	Results   []SlugOutcome `json:"results,omitempty"` // Per-slug outcome, when the update ran
+}
+
+// NewCommandReplyEvent builds the outbox event of the reply, keyed by the request ID.
+func NewCommandReplyEvent(topic string, cmd MembershipCommand, results []SlugOutcome, err error) (OutboxEvent, error) {
+	reply := CommandReply{
+		RequestID: cmd.RequestID,
+		UserID:    cmd.UserID,
+		Status:    CommandStatusOK,
+		Results:   results,
+	}
+	if err != nil {
+		reply.Status = CommandStatusError
+		reply.Error = err.Error()
+	}
+
+	payload, err := json.Marshal(reply)
+	if err != nil {
+		return OutboxEvent{}, err
+	}
+	return OutboxEvent{Topic: topic, Key: cmd.RequestID, Payload: payload}, nil
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// DLQMessage is a message that could not be processed and was moved to a dead-letter topic.
+// @description Message stored in a dead-letter topic.
+type DLQMessage struct {
+	Topic           string    `json:"topic"`            // Dead-letter topic
+	Partition       int32     `json:"partition"`        // Partition in the dead-letter topic
+	Offset          int64     `json:"offset"`           // Offset in the dead-letter topic
+	Key             string    `json:"key"`              // Original message key
+	Value           string    `json:"value"`            // Original message value
+	Timestamp       time.Time `json:"timestamp"`        // Time the message was dead-lettered
+	Error           string    `json:"error"`            // Last processing error
+	Attempts        int       `json:"attempts"`         // Number of processing attempts
+	SourceTopic     string    `json:"source_topic"`     // Topic the message was consumed from
+	SourcePartition int32     `json:"source_partition"` // Partition the message was consumed from
+	SourceOffset    int64     `json:"source_offset"`    // Offset the message was consumed from
+}
+
+// DLQReplayRequest selects a dead-letter message to publish back to its source topic.
+type DLQReplayRequest struct {
+	Partition int32 `json:"partition" example:"0"` // Partition in the dead-letter topic
+	Offset    int64 `json:"offset" example:"42"`   // Offset in the dead-letter topic
+}
@@ -0,0 +1,45 @@
+package models
+
+import "errors"
+
+var (
+	// ErrUserNotFound is returned when a user with the requested ID does not exist.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrSegmentNotFound is returned when a segment with the requested slug does not exist.
+	ErrSegmentNotFound = errors.New("segment not found")
+	// ErrInvalidSegment is returned when segment metadata fails validation.
+	ErrInvalidSegment = errors.New("invalid segment")
+	// ErrInvalidMembership is returned when a membership update is malformed.
+	ErrInvalidMembership = errors.New("invalid membership update")
+	// ErrMembershipRejected is returned when a strict membership update names
+	// unknown or archived segments.
+	ErrMembershipRejected = errors.New("membership update rejected")
+	// ErrBulkJobNotFound is returned when a bulk membership job with the requested ID does not exist.
+	ErrBulkJobNotFound = errors.New("bulk job not found")
+	// ErrJobNotFound is returned when a background job with the requested ID does not exist.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobFinished is returned when a finished job is cancelled.
+	ErrJobFinished = errors.New("job already finished")
+	// ErrJobCancelled is returned to a running job that was cancelled.
+	ErrJobCancelled = errors.New("job cancelled")
+	// ErrJobLeaseLost is returned when a worker reports on a job whose lease
+	// expired and that another worker may have claimed since.
+	ErrJobLeaseLost = errors.New("job lease lost")
+	// ErrInvalidReportDate is returned when the month of a report is not in YYYY-MM format.
+	ErrInvalidReportDate = errors.New("invalid report date")
+	// ErrReportNotFound is returned when a stored report does not exist.
+	ErrReportNotFound = errors.New("report not found")
+	// ErrInvalidReportLink is returned when a report download link is not
+	// signed by this service or has expired.
+	ErrInvalidReportLink = errors.New("invalid or expired report link")
+	// ErrDLQMessageNotFound is returned when a dead-letter topic has no message at the requested offset.
+	ErrDLQMessageNotFound = errors.New("dead-letter message not found")
+	// ErrAPIKeyNotFound is returned when an API key with the requested ID does not exist.
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrAPIKeyExists is returned when an API key with the requested name already exists.
+	ErrAPIKeyExists = errors.New("API key already exists")
+	// ErrUnauthenticated is returned when a request carries no valid credentials.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden is returned when the principal may not change a segment.
+	ErrForbidden = errors.New("forbidden")
+)
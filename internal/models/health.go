@@ -0,0 +1,21 @@
+package models
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+// DependencyHealth is the result of checking one dependency.
+type DependencyHealth struct {
+	Status    string  `json:"status"`          // "up" or "down"
+	LatencyMS float64 `json:"latency_ms"`      // Duration of the check in milliseconds
+	Lag       *int64  `json:"lag,omitempty"`   // Uncommitted messages, consumer only
+	Error     string  `json:"error,omitempty"` // Why the dependency is down
+}
+
+// HealthReport is the readiness of the service with a breakdown per dependency.
+type HealthReport struct {
+	Status       string                      `json:"status"`                 // "up" when every dependency is up
+	Error        string                      `json:"error,omitempty"`        // Set when the service is shutting down
+	Dependencies map[string]DependencyHealth `json:"dependencies,omitempty"` // Keyed by dependency name
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JobStatus is the state of a background job.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"    // Waiting for a worker, or for the next attempt
+	JobRunning   JobStatus = "running"   // Claimed by a worker
+	JobSucceeded JobStatus = "succeeded" // Finished, see Result
+	JobFailed    JobStatus = "failed"    // Every attempt failed, see Error
+	JobCancelled JobStatus = "cancelled" // Cancelled through the API
+)
+
+// Finished reports whether the job will not change anymore.
+func (s JobStatus) Finished() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+// Kinds of background jobs. Every kind is run by the handler registered for
+// it on the job queue.
+const (
+	JobBulkMembership = "bulk_membership" // Applies a bulk membership job, see BulkMembershipPayload
+	JobHistoryReport  = "history_report"  // Writes a membership history report, see HistoryReportPayload
+	JobSegmentPurge   = "segment_purge"   // Deletes an archived segment, see SegmentPurgePayload
+)
+
+// Job is a unit of background work stored in the jobs table. A worker claims
+// a job with a lease it extends while the job runs; a job whose lease expires,
+// because its replica stopped, is claimed again by another worker.
+// @description Background job and its progress.
+type Job struct {
+	ID              int64           `json:"id" example:"42"`
+	Kind            string          `json:"kind" example:"history_report"`
+	Status          JobStatus       `json:"status" example:"running" enums:"queued,running,succeeded,failed,cancelled"`
+	Payload         json.RawMessage `json:"payload" swaggertype:"object"`          // Input of the job, depends on the kind
+	Result          json.RawMessage `json:"result,omitempty" swaggertype:"object"` // Output of a succeeded job, depends on the kind
+	Error           string          `json:"error,omitempty"`                       // Error of the last failed attempt
+	Progress        float64         `json:"progress" example:"25"`                 // Percentage reported by the running attempt
+	Attempts        int             `json:"attempts" example:"1"`                  // Attempts started so far
+	MaxAttempts     int             `json:"max_attempts" example:"3"`
+	CancelRequested bool            `json:"cancel_requested,omitempty"` // The running attempt is asked to stop
+	Actor           string          `json:"actor,omitempty"`            // Principal that submitted the job
+	RequestID       string          `json:"request_id,omitempty"`       // Request that submitted the job
+	RunAt           time.Time       `json:"run_at"`                     // Earliest start of the next attempt
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+
+	// Teams and scopes Actor had when it submitted the job, which the job
+	// runs with.
+	ActorTeams  []string `json:"-"`
+	ActorScopes []Scope  `json:"-"`
+}
+
+// Submitter returns the principal that submitted the job as it was then, or
+// false when the job was submitted without authentication.
+func (j Job) Submitter() (Principal, bool) {
+	if j.Actor == "" {
+		return Principal{}, false
+	}
+	return Principal{Subject: j.Actor, Scopes: j.ActorScopes, Teams: j.ActorTeams}, true
+}
+
+// LastAttempt reports whether a failure of the running attempt fails the job.
+func (j Job) LastAttempt() bool {
+	return j.Attempts >= j.MaxAttempts
+}
+
+// BulkMembershipPayload is the payload of a bulk_membership job.
+type BulkMembershipPayload struct {
+	BulkJobID int64 `json:"bulk_job_id"`
+}
+
+// HistoryReportPayload is the payload of a history_report job.
+type HistoryReportPayload struct {
+	UserID int64  `json:"user_id"`
+	Date   string `json:"date"` // Year-Month in YYYY-MM format
+}
+
+// SegmentPurgePayload is the payload of a segment_purge job.
+type SegmentPurgePayload struct {
+	Slug Slug `json:"slug"`
+}
+
+// HistoryReportResult is the result of a history_report job. Only File is
+// stored with the job; URL is signed anew whenever the job is read.
+type HistoryReportResult struct {
+	File string `json:"file" example:"user_1000_history_2024-05.csv"`
+	URL  string `json:"url,omitempty" example:"/reports/user_1000_history_2024-05.csv?expires=1717200000&signature=..."` // Download link of the report, which expires
+}
+
+// SegmentPurgeResult is the result of a segment_purge job.
+type SegmentPurgeResult struct {
+	Slug    Slug  `json:"slug"`
+	Removed int64 `json:"removed"` // Memberships removed with the segment
+}
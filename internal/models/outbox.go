@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+const (
+	TopicUserSegments  = "user-segments"
+	TopicSegmentExpiry = "segment_expiry"
+)
+
+const (
+	ActionAdd    = "add"
+	ActionDelete = "delete"
+)
+
+// MembershipEvent is the payload published when a user joins or leaves a segment.
+type MembershipEvent struct {
+	UserID  int64  `json:"user_id"`
+	Segment Slug   `json:"segment"`
+	Action  string `json:"action"`
+	TTL     string `json:"ttl,omitempty"`
+}
+
+// OutboxEvent is a Kafka message stored in the outbox table until the relay publishes it.
+type OutboxEvent struct {
+	ID       int64
+	Topic    string
+	Key      string
+	Payload  json.RawMessage
+	Headers  map[string]string // Kafka headers, e.g. the trace context of the change
+	Attempts int
+}
+
+// NewMembershipEvents builds the outbox events for one membership change. An
+// add with a TTL is also announced on the segment_expiry topic.
+func NewMembershipEvents(userID int64, slug Slug, action string, ttl *time.Time) ([]OutboxEvent, error) {
+	event := MembershipEvent{
+		UserID:  userID,
+		Segment: slug,
+		Action:  action,
+	}
+	if ttl != nil {
+		event.TTL = ttl.Format(time.RFC3339)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	key := strconv.FormatInt(userID, 10)
+	events := make([]OutboxEvent, 0, 2)
+	if action == ActionAdd && ttl != nil {
+		events = append(events, OutboxEvent{Topic: TopicSegmentExpiry, Key: key, Payload: payload})
+	}
+	events = append(events, OutboxEvent{Topic: TopicUserSegments, Key: key, Payload: payload})
+	return events, nil
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewMembershipEvents(t *testing.T) {
+	events, err := NewMembershipEvents(1000, "DISCOUNT_30", ActionDelete, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(events) != 1 || events[0].Topic != TopicUserSegments {
+		t.Errorf("Expected one %s event, got %+v", TopicUserSegments, events)
+	}
+	if events[0].Key != "1000" {
+		t.Errorf("Expected key '1000', got '%s'", events[0].Key)
+	}
+
+	ttl := time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC)
+	events, err = NewMembershipEvents(1000, "DISCOUNT_30", ActionAdd, &ttl)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(events) != 2 || events[0].Topic != TopicSegmentExpiry || events[1].Topic != TopicUserSegments {
+		t.Errorf("Expected %s and %s events, got %+v", TopicSegmentExpiry, TopicUserSegments, events)
+	}
+
+	expected := `{"user_id":1000,"segment":"DISCOUNT_30","action":"add","ttl":"2024-12-31T23:59:59Z"}`
+	if string(events[1].Payload) != expected {
+		t.Errorf("Expected payload '%s', got '%s'", expected, events[1].Payload)
+	}
+}
@@ -1,13 +1,205 @@
-package models
-
-type Slug string
-
-type Segments struct {
-	ID   int64 `json:"id"`
-	Slug Slug  `json:"slug"`
-}
-
-// SegmentRequest used to create segment
-type SegmentRequest struct {
-	Slug Slug `json:"slug" example:"DISCOUNT_30"` // segment name
-}
+package models
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Slug string
+
+// SegmentExpiryAction tells what happens to a segment at its ExpiresAt. In
+// both cases its memberships are removed.
+type SegmentExpiryAction string
+
+const (
+	SegmentExpiryArchive SegmentExpiryAction = "archive" // Archive the segment
+	SegmentExpiryDelete  SegmentExpiryAction = "delete"  // Delete the segment
+)
+
+type Segments struct {
+	ID           int64               `json:"id"`
+	Slug         Slug                `json:"slug"`
+	AutoPercent  *float64            `json:"auto_percent,omitempty"`
+	Owner        *string             `json:"owner,omitempty"`
+	Description  *string             `json:"description,omitempty" example:"30% discount for new customers"`
+	Tags         []string            `json:"tags" example:"promo"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+	ActiveFrom   *time.Time          `json:"active_from,omitempty"`
+	ActiveUntil  *time.Time          `json:"active_until,omitempty"`
+	ExpiresAt    *time.Time          `json:"expires_at,omitempty"`
+	ExpiryAction SegmentExpiryAction `json:"expiry_action" example:"archive" enums:"archive,delete"`
+}
+
+// SegmentRef identifies an existing segment.
+type SegmentRef struct {
+	ID       int64
+	Slug     Slug
+	Archived bool
+}
+
+// Active reports whether at falls between ActiveFrom, inclusive, and
+// ActiveUntil, exclusive. Missing dates do not bound the segment.
+func (s Segments) Active(at time.Time) bool {
+	if s.ActiveFrom != nil && at.Before(*s.ActiveFrom) {
+		return false
+	}
+	return s.ActiveUntil == nil || at.Before(*s.ActiveUntil)
+}
+
+// Validate normalizes the tags and checks the auto_percent share and that the
+// active period is not empty.
+func (s *Segments) Validate() error {
+	tags, err := normalizeTags(s.Tags)
+	if err != nil {
+		return err
+	}
+	s.Tags = tags
+	switch s.ExpiryAction {
+	case "":
+		s.ExpiryAction = SegmentExpiryArchive
+	case SegmentExpiryArchive, SegmentExpiryDelete:
+	default:
+		return fmt.Errorf("%w: unknown expiry_action %q", ErrInvalidSegment, s.ExpiryAction)
+	}
+	// auto_percent is stored as NUMERIC(5, 2), so a share that rounds to 0
+	// would fail the check of the column.
+	if s.AutoPercent != nil && (math.Round(*s.AutoPercent*100) <= 0 || *s.AutoPercent > 100) {
+		return fmt.Errorf("%w: auto_percent must be in range [0.01, 100]", ErrInvalidSegment)
+	}
+	if s.ActiveFrom != nil && s.ActiveUntil != nil && !s.ActiveFrom.Before(*s.ActiveUntil) {
+		return fmt.Errorf("%w: active_from must be before active_until", ErrInvalidSegment)
+	}
+	return nil
+}
+
+// normalizeTags trims the tags and returns them sorted without duplicates.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, fmt.Errorf("%w: tags must not be empty", ErrInvalidSegment)
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
+
+// SegmentRequest used to create segment
+type SegmentRequest struct {
+	Slug         Slug                `json:"slug" example:"DISCOUNT_30"`                                               // segment name
+	AutoPercent  *float64            `json:"auto_percent,omitempty" example:"10"`                                      // share of users enrolled automatically, [0.01, 100]
+	Owner        *string             `json:"owner,omitempty" example:"growth"`                                         // owner team or principal, the creator by default
+	Description  *string             `json:"description,omitempty" example:"30% discount for new customers"`           // what the segment is for
+	Tags         []string            `json:"tags,omitempty" example:"promo"`                                           // free-form tags
+	ActiveFrom   *time.Time          `json:"active_from,omitempty" example:"2024-01-01T00:00:00Z" format:"date-time"`  // start of the active period
+	ActiveUntil  *time.Time          `json:"active_until,omitempty" example:"2024-02-01T00:00:00Z" format:"date-time"` // end of the active period, exclusive
+	ExpiresAt    *time.Time          `json:"expires_at,omitempty" example:"2024-03-01T00:00:00Z" format:"date-time"`   // when the segment expires
+	ExpiryAction SegmentExpiryAction `json:"expiry_action,omitempty" example:"archive" enums:"archive,delete"`         // archive (default) or delete on expiry
+}
+
+// SegmentPatch changes the metadata of a segment. Omitted fields are kept.
+type SegmentPatch struct {
+	Description       *string              `json:"description,omitempty" example:"30% discount for new customers"`           // what the segment is for, empty to clear
+	Owner             *string              `json:"owner,omitempty" example:"growth"`                                         // owner team or principal
+	Tags              *[]string            `json:"tags,omitempty" example:"promo"`                                           // replaces all tags
+	ActiveFrom        *time.Time           `json:"active_from,omitempty" example:"2024-01-01T00:00:00Z" format:"date-time"`  // start of the active period
+	ActiveUntil       *time.Time           `json:"active_until,omitempty" example:"2024-02-01T00:00:00Z" format:"date-time"` // end of the active period, exclusive
+	RemoveActiveFrom  bool                 `json:"remove_active_from,omitempty"`                                             // clear the start of the active period
+	RemoveActiveUntil bool                 `json:"remove_active_until,omitempty"`                                            // clear the end of the active period
+	ExpiresAt         *time.Time           `json:"expires_at,omitempty" example:"2024-03-01T00:00:00Z" format:"date-time"`   // when the segment expires
+	ExpiryAction      *SegmentExpiryAction `json:"expiry_action,omitempty" example:"archive" enums:"archive,delete"`         // archive or delete on expiry
+	RemoveExpiry      bool                 `json:"remove_expiry,omitempty"`                                                  // cancel the expiry
+}
+
+// Apply copies the fields set in p to segment and validates the result.
+func (p SegmentPatch) Apply(segment *Segments) error {
+	if p.Description != nil {
+		segment.Description = p.Description
+		if *p.Description == "" {
+			segment.Description = nil
+		}
+	}
+	if p.Owner != nil {
+		segment.Owner = p.Owner
+	}
+	if p.Tags != nil {
+		segment.Tags = *p.Tags
+	}
+	if p.ActiveFrom != nil {
+		segment.ActiveFrom = p.ActiveFrom
+	}
+	if p.ActiveUntil != nil {
+		segment.ActiveUntil = p.ActiveUntil
+	}
+	if p.RemoveActiveFrom {
+		segment.ActiveFrom = nil
+	}
+	if p.RemoveActiveUntil {
+		segment.ActiveUntil = nil
+	}
+	if p.ExpiresAt != nil {
+		segment.ExpiresAt = p.ExpiresAt
+	}
+	if p.RemoveExpiry {
+		segment.ExpiresAt = nil
+	}
+	if p.ExpiryAction != nil {
+		segment.ExpiryAction = *p.ExpiryAction
+	}
+	return segment.Validate()
+}
+
+// SegmentFilter selects segments in GET /segments. Empty fields match every
+// segment.
+type SegmentFilter struct {
+	Tag    string `query:"tag"`    // Segments with this tag
+	Owner  string `query:"owner"`  // Segments owned by this principal or team
+	Active *bool  `query:"active"` // Segments inside or outside their active period now
+}
+
+// SegmentACL lists who may change a segment. The owner, a principal or one
+// of its teams, manages the segment; writers may only change its members.
+// A segment without owner and writers is open to every principal.
+// @description Access control list of a segment.
+type SegmentACL struct {
+	Slug    Slug     `json:"slug" example:"DISCOUNT_30"`       // Segment slug
+	Owner   *string  `json:"owner,omitempty" example:"growth"` // Owner team or principal
+	Writers []string `json:"writers" example:"billing"`        // Principals or teams allowed to change members
+}
+
+// SegmentACLRequest replaces the owner and writers of a segment.
+type SegmentACLRequest struct {
+	Owner   *string  `json:"owner,omitempty" example:"growth"` // Owner team or principal, none when omitted
+	Writers []string `json:"writers" example:"billing"`        // Principals or teams allowed to change members
+}
+
+func (a SegmentACL) open() bool {
+	return a.Owner == nil && len(a.Writers) == 0
+}
+
+// CanManage reports whether p may delete the segment or change its ACL.
+func (a SegmentACL) CanManage(p Principal) bool {
+	if p.HasScope(ScopeAdmin) || a.open() {
+		return true
+	}
+	return a.Owner != nil && p.Is(*a.Owner)
+}
+
+// CanWrite reports whether p may add users to or remove them from the segment.
+func (a SegmentACL) CanWrite(p Principal) bool {
+	if a.CanManage(p) {
+		return true
+	}
+	for _, writer := range a.Writers {
+		if p.Is(writer) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSegmentACL(t *testing.T) {
+	owner := "growth"
+	acl := SegmentACL{Slug: "DISCOUNT_30", Owner: &owner, Writers: []string{"billing"}}
+
+	tests := []struct {
+		name      string
+		principal Principal
+		canManage bool
+		canWrite  bool
+	}{
+		{"owner subject", Principal{Subject: "growth"}, true, true},
+		{"owner team", Principal{Subject: "alice", Teams: []string{"growth"}}, true, true},
+		{"writer", Principal{Subject: "billing"}, false, true},
+		{"admin", Principal{Subject: "ops", Scopes: []Scope{ScopeAdmin}}, true, true},
+		{"stranger", Principal{Subject: "crm", Scopes: []Scope{ScopeMembershipsWrite}}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acl.CanManage(tt.principal); got != tt.canManage {
+				t.Errorf("Expected CanManage %v, got %v", tt.canManage, got)
+			}
+			if got := acl.CanWrite(tt.principal); got != tt.canWrite {
+				t.Errorf("Expected CanWrite %v, got %v", tt.canWrite, got)
+			}
+		})
+	}
+
+	t.Run("open segment", func(t *testing.T) {
+		open := SegmentACL{Slug: "VIDEO"}
+		if !open.CanManage(Principal{Subject: "crm"}) || !open.CanWrite(Principal{Subject: "crm"}) {
+			t.Errorf("Expected a segment without owner and writers to be open")
+		}
+	})
+}
+
+func TestSegmentsActive(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	segment := Segments{Slug: "DISCOUNT_30", ActiveFrom: &from, ActiveUntil: &until}
+
+	tests := []struct {
+		name   string
+		at     time.Time
+		active bool
+	}{
+		{"before", from.Add(-time.Second), false},
+		{"at start", from, true},
+		{"inside", from.Add(24 * time.Hour), true},
+		{"at end", until, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := segment.Active(tt.at); got != tt.active {
+				t.Errorf("Expected Active %v, got %v", tt.active, got)
+			}
+		})
+	}
+
+	if !(Segments{Slug: "VIDEO"}).Active(from) {
+		t.Errorf("Expected a segment without dates to be active")
+	}
+}
+
+func TestSegmentPatchApply(t *testing.T) {
+	owner := "growth"
+	description := "30% discount"
+	tags := []string{" promo", "q1", "promo"}
+	segment := Segments{Slug: "DISCOUNT_30", Owner: &owner, Description: &description}
+
+	empty := ""
+	err := SegmentPatch{Description: &empty, Tags: &tags}.Apply(&segment)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if segment.Description != nil {
+		t.Errorf("Expected an empty description to clear it, got %q", *segment.Description)
+	}
+	if segment.Owner != &owner {
+		t.Errorf("Expected the owner to be kept")
+	}
+	if !slices.Equal(segment.Tags, []string{"promo", "q1"}) {
+		t.Errorf("Expected normalized tags, got %v", segment.Tags)
+	}
+
+	from := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	until := from.Add(-time.Hour)
+	err = SegmentPatch{ActiveFrom: &from, ActiveUntil: &until}.Apply(&segment)
+	if !errors.Is(err, ErrInvalidSegment) {
+		t.Errorf("Expected ErrInvalidSegment for an empty active period, got %v", err)
+	}
+
+	segment = Segments{Slug: "DISCOUNT_30", ActiveFrom: &until, ActiveUntil: &from}
+	if err := (SegmentPatch{RemoveActiveFrom: true, RemoveActiveUntil: true}).Apply(&segment); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if segment.ActiveFrom != nil || segment.ActiveUntil != nil {
+		t.Errorf("Expected the active period to be cleared, got %v - %v", segment.ActiveFrom, segment.ActiveUntil)
+	}
+}
+
+func TestSegmentPatchApply_Expiry(t *testing.T) {
+	expiresAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	segment := Segments{Slug: "BLACK_FRIDAY"}
+
+	deleteAction := SegmentExpiryDelete
+	if err := (SegmentPatch{ExpiresAt: &expiresAt, ExpiryAction: &deleteAction}).Apply(&segment); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if segment.ExpiresAt == nil || !segment.ExpiresAt.Equal(expiresAt) || segment.ExpiryAction != SegmentExpiryDelete {
+		t.Errorf("Expected expiry at %v with delete, got %v with %q", expiresAt, segment.ExpiresAt, segment.ExpiryAction)
+	}
+
+	if err := (SegmentPatch{RemoveExpiry: true}).Apply(&segment); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if segment.ExpiresAt != nil {
+		t.Errorf("Expected the expiry to be removed, got %v", segment.ExpiresAt)
+	}
+
+	unknown := SegmentExpiryAction("drop")
+	if err := (SegmentPatch{ExpiryAction: &unknown}).Apply(&segment); !errors.Is(err, ErrInvalidSegment) {
+		t.Errorf("Expected ErrInvalidSegment for an unknown expiry action, got %v", err)
+	}
+}
+
+func TestSegmentsValidateAutoPercent(t *testing.T) {
+	tests := []struct {
+		percent float64
+		valid   bool
+	}{
+		{0, false},
+		{0.004, false},
+		{0.005, true},
+		{10, true},
+		{100, true},
+		{100.5, false},
+	}
+	for _, tt := range tests {
+		segment := Segments{Slug: "VIDEO", AutoPercent: &tt.percent}
+		if err := segment.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Expected auto_percent %v valid %v, got %v", tt.percent, tt.valid, err)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package models
+
+// Stats are the entity counts exported as metrics.
+type Stats struct {
+	Users       int64
+	Segments    int64
+	Memberships map[Slug]int64 // Members per segment, including empty segments
+}
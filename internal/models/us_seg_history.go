@@ -1,23 +1,64 @@
-package models
-
-import "time"
-
-type OperationType string
-
-const (
-	ADD    OperationType = "ADD"
-	DELETE OperationType = "DELETE"
-)
-
-type UserHistory struct {
-	UserID int64         `json:"user_id"`
-	Date   OperationType `json:"operation_type"`
-}
-
-type UserSegmentsHistory struct {
-	ID            int64         `json:"id"`
-	UserID        int64         `json:"user_id"`
-	SegmentSlug   Slug          `json:"segment_slug"`
-	OperationType OperationType `json:"operation_type"`
-	OperationDate time.Time     `json:"operation_date"`
-}
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type OperationType string
+
+const (
+	ADD    OperationType = "ADD"
+	DELETE OperationType = "DELETE"
+)
+
+// HistorySource tells what made a membership change.
+type HistorySource string
+
+const (
+	SourceAPI     HistorySource = "api"     // HTTP API call
+	SourceKafka   HistorySource = "kafka"   // Command from the command topic
+	SourceTTL     HistorySource = "ttl"     // Membership TTL expiry
+	SourceAuto    HistorySource = "auto"    // auto_percent enrollment
+	SourceCascade HistorySource = "cascade" // Deletion of the user or the segment
+	SourceBulk    HistorySource = "bulk"    // Bulk membership job
+)
+
+const (
+	// ReasonExpired marks history rows written when a membership TTL has passed.
+	ReasonExpired = "expired"
+	// ReasonAutoPercent marks history rows of auto_percent enrollments.
+	ReasonAutoPercent = "auto_percent"
+	// ReasonSegmentDeleted marks history rows of memberships removed together
+	// with their segment.
+	ReasonSegmentDeleted = "segment_deleted"
+	// ReasonUserDeleted marks history rows of memberships removed together
+	// with their user.
+	ReasonUserDeleted = "user_deleted"
+	// ReasonSegmentExpired marks history rows of memberships removed when
+	// their segment expired.
+	ReasonSegmentExpired = "segment_expired"
+)
+
+type UserHistory struct {
+	UserID int64         `json:"user_id"`
+	Date   OperationType `json:"operation_type"`
+}
+
+type UserSegmentsHistory struct {
+	ID            int64         `json:"id"`
+	UserID        int64         `json:"user_id"`
+	SegmentSlug   Slug          `json:"segment_slug"`
+	OperationType OperationType `json:"operation_type"`
+	OperationDate time.Time     `json:"operation_date"`
+	Reason        string        `json:"reason,omitempty"`
+	Source        HistorySource `json:"source,omitempty"`
+	Actor         string        `json:"actor,omitempty"`
+	RequestID     string        `json:"request_id,omitempty"`
+}
+
+// HistoryReportName returns the file name of the history report of the user
+// for the month date in YYYY-MM format.
+func HistoryReportName(userID int64, date string) string {
+	return fmt.Sprintf("user_%d_history_%s.csv", userID, date)
+}
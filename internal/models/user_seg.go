@@ -1,24 +1,134 @@
-package models
-
-// UserSegment represents a user and their associated segments.
-// @description Model representing a user and their associated segments.
-type UserSegments struct {
-	UserID   int64  `json:"user_id"`  // User's unique ID
-	Segments []Slug `json:"segments"` // List of associated segments
-}
-
-// UserSegment represents a single user-segment relationship.
-// @description Model representing a relationship between a user and a segment.
-type UserSegment struct {
-	UserID   int64 `json:"user_id"`  // User's unique ID
-	Segments Slug  `json:"segments"` // Associated segment
-}
-
-// UpdateSegmentsRequest is used for updating a user's segments.
-// @description Request payload for updating a user's associated segments.
-type UpdateSegmentsRequest struct {
-	AddSegments    []Slug  `json:"add_segments" example:"[\"VOICE_MESSAGES\"]"`  // Segments to add
-	DeleteSegments []Slug  `json:"delete_segments" example:"[\"CHAT_SUPPORT\"]"` // Segments to delete
-	UserID         int64   `json:"user_id" example:"123"`                        // User's unique ID
-	TTL            *string `json:"ttl"`                                          // TTL default NULL
-}
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// UserSegment represents a user and their associated segments.
+// @description Model representing a user and their associated segments.
+type UserSegments struct {
+	UserID   int64  `json:"user_id"`  // User's unique ID
+	Segments []Slug `json:"segments"` // List of associated segments
+}
+
+// UserSegment represents a single user-segment relationship.
+// @description Model representing a relationship between a user and a segment.
+type UserSegment struct {
+	UserID   int64 `json:"user_id"`  // User's unique ID
+	Segments Slug  `json:"segments"` // Associated segment
+}
+
+// UpdateSegmentsRequest is used for updating a user's segments.
+// @description Request payload for updating a user's associated segments.
+type UpdateSegmentsRequest struct {
+	AddSegments    []Slug  `json:"add_segments" example:"[\"VOICE_MESSAGES\"]"`  // Segments to add
+	DeleteSegments []Slug  `json:"delete_segments" example:"[\"CHAT_SUPPORT\"]"` // Segments to delete
+	UserID         int64   `json:"user_id" example:"123"`                        // User's unique ID
+	TTL            *string `json:"ttl"`                                          // TTL default NULL
+	Reason         string  `json:"reason,omitempty" example:"trial ended"`       // Reason recorded in the history
+	// Mode is strict (default) to reject the whole update when a segment is
+	// unknown or archived, or best_effort to apply the remaining slugs.
+	Mode UpdateMode `json:"mode,omitempty" example:"strict" enums:"strict,best_effort"`
+}
+
+// UpdateMode tells how a membership update treats unknown and archived segments.
+type UpdateMode string
+
+const (
+	ModeStrict     UpdateMode = "strict"
+	ModeBestEffort UpdateMode = "best_effort"
+)
+
+// Validate defaults the mode to strict and rejects unknown modes and slugs
+// that are both added and deleted.
+func (r *UpdateSegmentsRequest) Validate() error {
+	switch r.Mode {
+	case "":
+		r.Mode = ModeStrict
+	case ModeStrict, ModeBestEffort:
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidMembership, r.Mode)
+	}
+	for _, slug := range r.AddSegments {
+		if slices.Contains(r.DeleteSegments, slug) {
+			return fmt.Errorf("%w: segment %s is both added and deleted", ErrInvalidMembership, slug)
+		}
+	}
+	return nil
+}
+
+// MembershipOutcome is what a membership update did with one slug.
+type MembershipOutcome string
+
+const (
+	OutcomeAdded          MembershipOutcome = "added"           // The user joined the segment
+	OutcomeAlreadyMember  MembershipOutcome = "already_member"  // The user was a member, only the TTL was set
+	OutcomeRemoved        MembershipOutcome = "removed"         // The user left the segment
+	OutcomeNotMember      MembershipOutcome = "not_member"      // The user was not a member
+	OutcomeUnknownSegment MembershipOutcome = "unknown_segment" // The segment does not exist
+	OutcomeArchived       MembershipOutcome = "archived"        // The segment is archived
+)
+
+// Failed reports whether the slug could not be applied.
+func (o MembershipOutcome) Failed() bool {
+	return o == OutcomeUnknownSegment || o == OutcomeArchived
+}
+
+// SlugOutcome is the outcome of one slug of a membership update.
+type SlugOutcome struct {
+	Slug    Slug              `json:"slug" example:"DISCOUNT_30"` // Segment slug
+	Action  string            `json:"action" example:"add"`       // add or delete
+	Outcome MembershipOutcome `json:"outcome" example:"added"`    // What happened
+}
+
+// UpdateSegmentsResult reports the outcome of every slug of a membership
+// update. Applied is false when a strict update was rejected.
+// @description Per-slug outcome of a membership update.
+type UpdateSegmentsResult struct {
+	UserID  int64         `json:"user_id" example:"123"` // User's unique ID
+	Mode    UpdateMode    `json:"mode" example:"strict"` // Mode the update ran in
+	Applied bool          `json:"applied"`               // Whether the changes were saved
+	Results []SlugOutcome `json:"results"`               // One entry per requested slug
+}
+
+// Failed returns the slugs that could not be applied.
+func (r UpdateSegmentsResult) Failed() []SlugOutcome {
+	var failed []SlugOutcome
+	for _, result := range r.Results {
+		if result.Outcome.Failed() {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// SegmentMember represents a user belonging to a segment.
+// @description Member of a segment with an optional membership TTL.
+type SegmentMember struct {
+	UserID int64      `json:"user_id"`       // User's unique ID
+	TTL    *time.Time `json:"ttl,omitempty"` // Membership expiry, only when requested
+}
+
+// SegmentUsers is a page of segment members or their total count.
+// @description Page of users belonging to a segment.
+type SegmentUsers struct {
+	Slug       Slug            `json:"slug"`                  // Segment slug
+	Users      []SegmentMember `json:"users,omitempty"`       // Members on this page
+	NextCursor *int64          `json:"next_cursor,omitempty"` // Cursor for the next page, absent on the last page
+	Count      *int64          `json:"count,omitempty"`       // Total number of members, only in count mode
+}
+
+// ParseTTL parses the optional RFC 3339 TTL.
+func (r UpdateSegmentsRequest) ParseTTL() (*time.Time, error) {
+	if r.TTL == nil {
+		return nil, nil
+	}
+
+	ttl, err := time.Parse(time.RFC3339, *r.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TTL format: %w", err)
+	}
+	return &ttl, nil
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUpdateSegmentsRequestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   UpdateSegmentsRequest
+		mode  UpdateMode
+		valid bool
+	}{
+		{"default mode", UpdateSegmentsRequest{AddSegments: []Slug{"VIDEO"}}, ModeStrict, true},
+		{"best effort", UpdateSegmentsRequest{AddSegments: []Slug{"VIDEO"}, Mode: ModeBestEffort}, ModeBestEffort, true},
+		{"unknown mode", UpdateSegmentsRequest{Mode: "lenient"}, "lenient", false},
+		{"overlapping slugs", UpdateSegmentsRequest{AddSegments: []Slug{"VIDEO"}, DeleteSegments: []Slug{"VIDEO"}}, ModeStrict, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.valid && err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidMembership) {
+				t.Fatalf("Expected ErrInvalidMembership, got %v", err)
+			}
+			if tt.req.Mode != tt.mode {
+				t.Errorf("Expected mode %q, got %q", tt.mode, tt.req.Mode)
+			}
+		})
+	}
+}
+
+func TestUpdateSegmentsResultFailed(t *testing.T) {
+	result := UpdateSegmentsResult{Results: []SlugOutcome{
+		{Slug: "VIDEO", Action: ActionAdd, Outcome: OutcomeAdded},
+		{Slug: "OLD", Action: ActionAdd, Outcome: OutcomeArchived},
+		{Slug: "NOPE", Action: ActionDelete, Outcome: OutcomeUnknownSegment},
+		{Slug: "SUPPORT", Action: ActionDelete, Outcome: OutcomeNotMember},
+	}}
+
+	failed := result.Failed()
+	if len(failed) != 2 || failed[0].Slug != "OLD" || failed[1].Slug != "NOPE" {
+		t.Errorf("Expected OLD and NOPE to fail, got %v", failed)
+	}
+}
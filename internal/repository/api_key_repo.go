@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"API/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// uniqueViolation is the Postgres error code of a unique constraint violation.
+const uniqueViolation = "23505"
+
+//go:generate mockery --name=APIKeyRepository --output=mocks --outpkg=mocks
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey, hash string) error
+	AuthenticateAPIKey(ctx context.Context, hash string) (models.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	DeleteAPIKey(ctx context.Context, id int64) error
+}
+
+type APIKeyRepositoryDB struct {
+	DB *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepositoryDB {
+	return &APIKeyRepositoryDB{DB: db}
+}
+
+// CreateAPIKey stores the key hash and fills in the ID and creation time of
+// key. A taken name returns models.ErrAPIKeyExists.
+func (r *APIKeyRepositoryDB) CreateAPIKey(ctx context.Context, key *models.APIKey, hash string) error {
+	query := `
+	INSERT INTO api_keys (name, prefix, key_hash, scopes)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at;`
+
+	scopes := make([]string, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	err := r.DB.QueryRowContext(ctx, query, key.Name, key.Prefix, hash, pq.Array(scopes)).
+		Scan(&key.ID, &key.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return fmt.Errorf("%w: %s", models.ErrAPIKeyExists, key.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create API key %s: %w", key.Name, err)
+	}
+	return nil
+}
+
+// AuthenticateAPIKey returns the key with the given hash and records its use.
+// last_used_at is written at most once a minute per key, so authenticated
+// reads do not each cost a write. An unknown hash returns
+// models.ErrAPIKeyNotFound.
+func (r *APIKeyRepositoryDB) AuthenticateAPIKey(ctx context.Context, hash string) (models.APIKey, error) {
+	query := `
+	WITH used AS (
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE key_hash = $1
+		AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+		RETURNING last_used_at
+	)
+	SELECT id, name, prefix, scopes, created_at, COALESCE((SELECT last_used_at FROM used), last_used_at)
+	FROM api_keys
+	WHERE key_hash = $1;`
+
+	key, err := scanAPIKey(r.DB.QueryRowContext(ctx, query, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, models.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("failed to authenticate API key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *APIKeyRepositoryDB) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	query := `
+	SELECT id, name, prefix, scopes, created_at, last_used_at
+	FROM api_keys
+	ORDER BY id;`
+
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *APIKeyRepositoryDB) DeleteAPIKey(ctx context.Context, id int64) error {
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM api_keys WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key %d: %w", id, err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return models.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var (
+		key    models.APIKey
+		scopes []string
+	)
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&scopes), &key.CreatedAt, &key.LastUsedAt); err != nil {
+		return models.APIKey{}, err
+	}
+	key.Scopes = make([]models.Scope, len(scopes))
+	for i, scope := range scopes {
+		key.Scopes[i] = models.Scope(scope)
+	}
+	return key, nil
+}
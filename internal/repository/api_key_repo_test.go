@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"API/internal/models"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewAPIKeyRepository(mockDB)
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should store the hash and return the ID", func(t *testing.T) {
+		key := models.APIKey{Name: "billing", Prefix: "ugk_abcdef", Scopes: []models.Scope{models.ScopeReportsRead}}
+
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO api_keys (name, prefix, key_hash, scopes)")).
+			WithArgs("billing", "ugk_abcdef", "hash", `{"reports:read"}`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, createdAt))
+
+		err := repo.CreateAPIKey(context.Background(), &key, "hash")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), key.ID)
+		assert.Equal(t, createdAt, key.CreatedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should report a taken name", func(t *testing.T) {
+		key := models.APIKey{Name: "billing", Prefix: "ugk_abcdef", Scopes: []models.Scope{models.ScopeReportsRead}}
+
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO api_keys (name, prefix, key_hash, scopes)")).
+			WillReturnError(&pq.Error{Code: uniqueViolation})
+
+		err := repo.CreateAPIKey(context.Background(), &key, "hash")
+
+		assert.ErrorIs(t, err, models.ErrAPIKeyExists)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewAPIKeyRepository(mockDB)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should return the key and record its use", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("last_used_at < NOW() - INTERVAL '1 minute'")).
+			WithArgs("hash").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "prefix", "scopes", "created_at", "last_used_at"}).
+				AddRow(7, "billing", "ugk_abcdef", `{memberships:write,reports:read}`, now, now))
+
+		key, err := repo.AuthenticateAPIKey(context.Background(), "hash")
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.APIKey{
+			ID:         7,
+			Name:       "billing",
+			Prefix:     "ugk_abcdef",
+			Scopes:     []models.Scope{models.ScopeMembershipsWrite, models.ScopeReportsRead},
+			CreatedAt:  now,
+			LastUsedAt: &now,
+		}, key)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should return ErrAPIKeyNotFound for unknown hash", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("last_used_at < NOW() - INTERVAL '1 minute'")).
+			WithArgs("unknown").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "prefix", "scopes", "created_at", "last_used_at"}))
+
+		_, err := repo.AuthenticateAPIKey(context.Background(), "unknown")
+
+		assert.ErrorIs(t, err, models.ErrAPIKeyNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteAPIKey(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewAPIKeyRepository(mockDB)
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM api_keys WHERE id = $1")).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.DeleteAPIKey(context.Background(), 7)
+
+	assert.ErrorIs(t, err, models.ErrAPIKeyNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
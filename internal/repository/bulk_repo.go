@@ -0,0 +1,371 @@
+package repository
+
+import (
+	"API/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lib/pq"
+)
+
+// bulkUserNotFound is the error report entry of rows whose user does not exist.
+const bulkUserNotFound = "user not found"
+
+//go:generate mockery --name=BulkJobRepository --output=mocks --outpkg=mocks
+type BulkJobRepository interface {
+	CreateBulkJob(ctx context.Context, job models.BulkJob, queued models.Job, rows models.BulkRowReader) (models.BulkJob, error)
+	GetBulkJob(ctx context.Context, id int64) (models.BulkJob, error)
+	ApplyBulkChunk(ctx context.Context, id int64, size int64) (models.BulkJob, error)
+	FailBulkJob(ctx context.Context, id int64, reason string) error
+	BulkJobErrors(ctx context.Context, id int64, fn func(models.BulkRow) error) error
+}
+
+type BulkJobRepositoryDB struct {
+	DB                *sql.DB
+	HistoryRepository UserSegmentHistoryRepository
+	OutboxRepository  OutboxRepository
+	JobRepository     JobRepository
+}
+
+func NewBulkJobRepository(db *sql.DB, historyRepo UserSegmentHistoryRepository, outboxRepo OutboxRepository, jobRepo JobRepository) *BulkJobRepositoryDB {
+	return &BulkJobRepositoryDB{
+		DB:                db,
+		HistoryRepository: historyRepo,
+		OutboxRepository:  outboxRepo,
+		JobRepository:     jobRepo,
+	}
+}
+
+const bulkJobColumns = `id, COALESCE(job_id, 0), status, add_segments, delete_segments, ttl, COALESCE(reason, ''), COALESCE(actor, ''),
+	COALESCE(request_id, ''), total_rows, processed_rows, failed_rows, COALESCE(error, ''),
+	created_at, updated_at, finished_at`
+
+func scanBulkJob(row rowScanner) (models.BulkJob, error) {
+	var (
+		job                models.BulkJob
+		addSlugs, delSlugs []string
+	)
+	err := row.Scan(&job.ID, &job.JobID, &job.Status, pq.Array(&addSlugs), pq.Array(&delSlugs), &job.TTL, &job.Reason, &job.Actor,
+		&job.RequestID, &job.TotalRows, &job.ProcessedRows, &job.FailedRows, &job.Error,
+		&job.CreatedAt, &job.UpdatedAt, &job.FinishedAt)
+	if err != nil {
+		return models.BulkJob{}, err
+	}
+	job.AddSegments = toSlugs(addSlugs)
+	job.DeleteSegments = toSlugs(delSlugs)
+	job.Progress = models.Percent(job.ProcessedRows, job.TotalRows)
+	return job, nil
+}
+
+func toSlugs(values []string) []models.Slug {
+	slugs := make([]models.Slug, len(values))
+	for i, value := range values {
+		slugs[i] = models.Slug(value)
+	}
+	return slugs
+}
+
+// CreateBulkJob stores job together with every row of rows, which are copied
+// into bulk_job_rows with COPY so the input is never held in memory, and
+// queues the background job queued that applies them. Rows that could not
+// be parsed are stored with their error and counted as failed. A job without
+// rows is done at once.
+func (r *BulkJobRepositoryDB) CreateBulkJob(ctx context.Context, job models.BulkJob, queued models.Job, rows models.BulkRowReader) (models.BulkJob, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.BulkJob{}, err
+	}
+	defer tx.Rollback()
+
+	const insert = `
+	INSERT INTO bulk_jobs (add_segments, delete_segments, ttl, reason, actor, request_id)
+	VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''))
+	RETURNING id;`
+
+	var id int64
+	err = tx.QueryRowContext(ctx, insert, pq.Array(job.AddSegments), pq.Array(job.DeleteSegments), job.TTL,
+		job.Reason, job.Actor, job.RequestID).Scan(&id)
+	if err != nil {
+		return models.BulkJob{}, fmt.Errorf("failed to create bulk job: %w", err)
+	}
+
+	queued.Payload, _ = json.Marshal(models.BulkMembershipPayload{BulkJobID: id})
+	if queued, err = r.JobRepository.SaveJob(ctx, tx, queued); err != nil {
+		return models.BulkJob{}, err
+	}
+
+	total, failed, err := copyBulkRows(ctx, tx, id, rows)
+	if err != nil {
+		return models.BulkJob{}, err
+	}
+
+	status := models.BulkJobPending
+	if total == 0 {
+		status = models.BulkJobDone
+	}
+	update := `
+	UPDATE bulk_jobs
+	SET total_rows = $2, failed_rows = $3, status = $4, job_id = $5,
+		finished_at = CASE WHEN $4 = '` + string(models.BulkJobDone) + `' THEN NOW() END
+	WHERE id = $1
+	RETURNING ` + bulkJobColumns + `;`
+
+	created, err := scanBulkJob(tx.QueryRowContext(ctx, update, id, total, failed, status, queued.ID))
+	if err != nil {
+		return models.BulkJob{}, fmt.Errorf("failed to count rows of bulk job %d: %w", id, err)
+	}
+	return created, tx.Commit()
+}
+
+func copyBulkRows(ctx context.Context, tx *sql.Tx, jobID int64, rows models.BulkRowReader) (total, failed int64, err error) {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("bulk_job_rows", "job_id", "row_number", "user_id", "error"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to start copying bulk rows: %w", err)
+	}
+	defer stmt.Close()
+
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+
+		userID := sql.NullInt64{Int64: row.UserID, Valid: row.UserID != 0}
+		rowErr := sql.NullString{String: row.Error, Valid: row.Error != ""}
+		if _, err := stmt.ExecContext(ctx, jobID, row.Row, userID, rowErr); err != nil {
+			return 0, 0, fmt.Errorf("failed to copy bulk row %d: %w", row.Row, err)
+		}
+		total++
+		if rowErr.Valid {
+			failed++
+		}
+	}
+
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return 0, 0, fmt.Errorf("failed to copy bulk rows: %w", err)
+	}
+	return total, failed, nil
+}
+
+func (r *BulkJobRepositoryDB) GetBulkJob(ctx context.Context, id int64) (models.BulkJob, error) {
+	query := `SELECT ` + bulkJobColumns + ` FROM bulk_jobs WHERE id = $1;`
+
+	job, err := scanBulkJob(r.DB.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.BulkJob{}, fmt.Errorf("%w: %d", models.ErrBulkJobNotFound, id)
+	}
+	return job, err
+}
+
+// ApplyBulkChunk applies the next size rows of the job in one transaction:
+// rows of unknown users are marked failed, the memberships of the others are
+// added and removed with set-based statements, and history rows and outbox
+// events are written in batches. The job row is locked first, so replicas
+// working on the same job apply every chunk exactly once. It returns the job
+// with its new progress; finished jobs are returned unchanged.
+func (r *BulkJobRepositoryDB) ApplyBulkChunk(ctx context.Context, id int64, size int64) (models.BulkJob, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.BulkJob{}, err
+	}
+	defer tx.Rollback()
+
+	lock := `SELECT ` + bulkJobColumns + ` FROM bulk_jobs WHERE id = $1 FOR UPDATE;`
+	job, err := scanBulkJob(tx.QueryRowContext(ctx, lock, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.BulkJob{}, fmt.Errorf("%w: %d", models.ErrBulkJobNotFound, id)
+	}
+	if err != nil {
+		return models.BulkJob{}, fmt.Errorf("failed to lock bulk job %d: %w", id, err)
+	}
+	if job.Status.Finished() {
+		return job, nil
+	}
+
+	first, last := job.ProcessedRows+1, min(job.ProcessedRows+size, job.TotalRows)
+
+	const markUnknown = `
+	UPDATE bulk_job_rows r
+	SET error = $4
+	WHERE r.job_id = $1
+	AND r.row_number BETWEEN $2 AND $3
+	AND r.error IS NULL
+	AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = r.user_id);`
+
+	result, err := tx.ExecContext(ctx, markUnknown, id, first, last, bulkUserNotFound)
+	if err != nil {
+		return models.BulkJob{}, fmt.Errorf("failed to check users of bulk job %d: %w", id, err)
+	}
+	failed, err := result.RowsAffected()
+	if err != nil {
+		return models.BulkJob{}, err
+	}
+
+	added, updated, err := bulkAdd(ctx, tx, job, first, last)
+	if err != nil {
+		return models.BulkJob{}, err
+	}
+	removed, err := bulkDelete(ctx, tx, job, first, last)
+	if err != nil {
+		return models.BulkJob{}, err
+	}
+	if err := recordMemberships(ctx, tx, r.HistoryRepository, r.OutboxRepository, added, updated, removed, job.TTL); err != nil {
+		return models.BulkJob{}, err
+	}
+
+	status := models.BulkJobRunning
+	if last == job.TotalRows {
+		status = models.BulkJobDone
+	}
+	progress := `
+	UPDATE bulk_jobs
+	SET processed_rows = $2, failed_rows = failed_rows + $3, status = $4, updated_at = NOW(),
+		finished_at = CASE WHEN $4 = '` + string(models.BulkJobDone) + `' THEN NOW() END
+	WHERE id = $1
+	RETURNING ` + bulkJobColumns + `;`
+
+	job, err = scanBulkJob(tx.QueryRowContext(ctx, progress, id, last, failed, status))
+	if err != nil {
+		return models.BulkJob{}, fmt.Errorf("failed to save progress of bulk job %d: %w", id, err)
+	}
+	return job, tx.Commit()
+}
+
+// bulkAdd upserts the memberships of the valid rows first to last in the
+// segments to add and returns the new ones and those whose TTL was updated.
+func bulkAdd(ctx context.Context, tx *sql.Tx, job models.BulkJob, first, last int64) (added, updated []models.UserSegment, err error) {
+	if len(job.AddSegments) == 0 {
+		return nil, nil, nil
+	}
+
+	const query = `
+	WITH upserted AS (
+		INSERT INTO user_segments (user_id, segment_id, ttl)
+		SELECT DISTINCT r.user_id, s.id, $5::TIMESTAMP
+		FROM bulk_job_rows r
+		JOIN users u ON u.id = r.user_id
+		CROSS JOIN segments s
+		WHERE r.job_id = $1
+		AND r.row_number BETWEEN $2 AND $3
+		AND r.error IS NULL
+		AND s.slug = ANY($4)
+		AND s.archived_at IS NULL
+		ON CONFLICT (user_id, segment_id) DO UPDATE
+		SET ttl = EXCLUDED.ttl
+		RETURNING user_id, segment_id, (xmax = 0) AS inserted
+	)
+	SELECT u.user_id, s.slug, u.inserted
+	FROM upserted u
+	JOIN segments s ON s.id = u.segment_id
+	ORDER BY u.user_id, s.slug;`
+
+	rows, err := tx.QueryContext(ctx, query, job.ID, first, last, pq.Array(job.AddSegments), job.TTL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to add memberships of bulk job %d: %w", job.ID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			membership models.UserSegment
+			inserted   bool
+		)
+		if err := rows.Scan(&membership.UserID, &membership.Segments, &inserted); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan added membership: %w", err)
+		}
+		if inserted {
+			added = append(added, membership)
+		} else {
+			updated = append(updated, membership)
+		}
+	}
+	return added, updated, rows.Err()
+}
+
+// bulkDelete removes the memberships of the valid rows first to last in the
+// segments to delete and returns the removed ones.
+func bulkDelete(ctx context.Context, tx *sql.Tx, job models.BulkJob, first, last int64) ([]models.UserSegment, error) {
+	if len(job.DeleteSegments) == 0 {
+		return nil, nil
+	}
+
+	const query = `
+	WITH deleted AS (
+		DELETE FROM user_segments us
+		USING segments s
+		WHERE us.segment_id = s.id
+		AND s.slug = ANY($4)
+		AND s.archived_at IS NULL
+		AND us.user_id IN (
+			SELECT user_id FROM bulk_job_rows
+			WHERE job_id = $1 AND row_number BETWEEN $2 AND $3 AND error IS NULL
+		)
+		RETURNING us.user_id, s.slug
+	)
+	SELECT user_id, slug FROM deleted ORDER BY user_id, slug;`
+
+	rows, err := tx.QueryContext(ctx, query, job.ID, first, last, pq.Array(job.DeleteSegments))
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove memberships of bulk job %d: %w", job.ID, err)
+	}
+	defer rows.Close()
+
+	var removed []models.UserSegment
+	for rows.Next() {
+		var membership models.UserSegment
+		if err := rows.Scan(&membership.UserID, &membership.Segments); err != nil {
+			return nil, fmt.Errorf("failed to scan removed membership: %w", err)
+		}
+		removed = append(removed, membership)
+	}
+	return removed, rows.Err()
+}
+
+// FailBulkJob stops an unfinished job with reason. Rows applied before stay applied.
+func (r *BulkJobRepositoryDB) FailBulkJob(ctx context.Context, id int64, reason string) error {
+	const query = `
+	UPDATE bulk_jobs
+	SET status = 'failed', error = $2, updated_at = NOW(), finished_at = NOW()
+	WHERE id = $1
+	AND status IN ('pending', 'running');`
+
+	if _, err := r.DB.ExecContext(ctx, query, id, reason); err != nil {
+		return fmt.Errorf("failed to fail bulk job %d: %w", id, err)
+	}
+	return nil
+}
+
+// BulkJobErrors passes the failed rows of the job to fn in row order without
+// loading them all at once.
+func (r *BulkJobRepositoryDB) BulkJobErrors(ctx context.Context, id int64, fn func(models.BulkRow) error) error {
+	const query = `
+	SELECT row_number, COALESCE(user_id, 0), error
+	FROM bulk_job_rows
+	WHERE job_id = $1
+	AND error IS NOT NULL
+	ORDER BY row_number;`
+
+	rows, err := r.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to read errors of bulk job %d: %w", id, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row models.BulkRow
+		if err := rows.Scan(&row.Row, &row.UserID, &row.Error); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"API/internal/models"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func bulkJobRows(status models.BulkJobStatus, total, processed, failed int64) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows([]string{"id", "job_id", "status", "add_segments", "delete_segments", "ttl", "reason", "actor",
+		"request_id", "total_rows", "processed_rows", "failed_rows", "error", "created_at", "updated_at", "finished_at"}).
+		AddRow(7, 43, status, "{VIDEO}", "{}", nil, "campaign", "billing", "req-1", total, processed, failed, "", now, now, nil)
+}
+
+func TestCreateBulkJob(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewBulkJobRepository(mockDB, NewUserSegmentHistoryRepository(mockDB), NewOutboxRepository(mockDB), NewJobRepository(mockDB))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO bulk_jobs")).
+		WithArgs(`{"VIDEO"}`, "{}", nil, "campaign", "billing", "req-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO jobs")).
+		WithArgs(models.JobBulkMembership, `{"bulk_job_id":7}`, 3, "billing", "req-1", `{"growth"}`, "{}").
+		WillReturnRows(jobRows(43, models.JobQueued, 0))
+	copyIn := mock.ExpectPrepare(regexp.QuoteMeta(`COPY "bulk_job_rows" ("job_id", "row_number", "user_id", "error") FROM STDIN`))
+	copyIn.ExpectExec().WithArgs(7, 1, 1000, nil).WillReturnResult(sqlmock.NewResult(0, 1))
+	copyIn.ExpectExec().WithArgs(7, 2, nil, "invalid user_id").WillReturnResult(sqlmock.NewResult(0, 1))
+	copyIn.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SET total_rows = $2, failed_rows = $3, status = $4, job_id = $5")).
+		WithArgs(7, 2, 1, models.BulkJobPending, 43).
+		WillReturnRows(bulkJobRows(models.BulkJobPending, 2, 0, 1))
+	mock.ExpectCommit()
+
+	job, err := repo.CreateBulkJob(context.Background(), models.BulkJob{
+		AddSegments:    []models.Slug{"VIDEO"},
+		DeleteSegments: []models.Slug{},
+		Reason:         "campaign",
+		Actor:          "billing",
+		RequestID:      "req-1",
+	}, models.Job{
+		Kind:        models.JobBulkMembership,
+		MaxAttempts: 3,
+		Actor:       "billing",
+		RequestID:   "req-1",
+		ActorTeams:  []string{"growth"},
+	}, models.NewUserIDRows([]int64{1000, 0}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), job.ID)
+	assert.Equal(t, int64(43), job.JobID)
+	assert.Equal(t, int64(2), job.TotalRows)
+	assert.Equal(t, []models.Slug{"VIDEO"}, job.AddSegments)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyBulkChunk(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewBulkJobRepository(mockDB, NewUserSegmentHistoryRepository(mockDB), NewOutboxRepository(mockDB), NewJobRepository(mockDB))
+
+	t.Run("should apply the next chunk and save progress", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("FROM bulk_jobs WHERE id = $1 FOR UPDATE;")).
+			WithArgs(7).
+			WillReturnRows(bulkJobRows(models.BulkJobPending, 3, 0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = r.user_id)")).
+			WithArgs(7, 1, 2, "user not found").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO user_segments (user_id, segment_id, ttl)")).
+			WithArgs(7, 1, 2, `{"VIDEO"}`, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "slug", "inserted"}).
+				AddRow(1000, "VIDEO", true).
+				AddRow(1001, "VIDEO", false))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO user_segments_history")).
+			WithArgs("{1000}", `{"VIDEO"}`, `{"ADD"}`, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+			WithArgs(`{"user-segments"}`, `{"1000"}`, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("SET processed_rows = $2, failed_rows = failed_rows + $3, status = $4")).
+			WithArgs(7, 2, 1, models.BulkJobRunning).
+			WillReturnRows(bulkJobRows(models.BulkJobRunning, 3, 2, 1))
+		mock.ExpectCommit()
+
+		job, err := repo.ApplyBulkChunk(context.Background(), 7, 2)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.BulkJobRunning, job.Status)
+		assert.Equal(t, 66.66, job.Progress)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should leave a finished job alone", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("FROM bulk_jobs WHERE id = $1 FOR UPDATE;")).
+			WithArgs(7).
+			WillReturnRows(bulkJobRows(models.BulkJobDone, 3, 3, 1))
+		mock.ExpectRollback()
+
+		job, err := repo.ApplyBulkChunk(context.Background(), 7, 2)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.BulkJobDone, job.Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
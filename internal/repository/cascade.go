@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"API/internal/models"
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// cascadeBatchSize is the number of memberships removed per transaction when
+// a segment or a user is deleted.
+const cascadeBatchSize = 1000
+
+// cascadeDelete describes the deletion of a row that memberships reference.
+type cascadeDelete struct {
+	lock   string               // Selects the id of the row FOR UPDATE, by $1
+	column string               // user_segments column referencing the row
+	delete string               // Deletes or archives the row by its id in $1
+	source models.HistorySource // Source of the history rows, cascade when empty
+	reason string               // Reason of the history rows
+}
+
+// run removes the memberships of the row selected by key in batches, writing
+// DELETE history rows and outbox events for each of them, and deletes the
+// row together with the last batch. Every batch locks
+// the row first, so no membership can be added to it while it is emptied.
+// It returns the number of removed memberships; a missing row is not an error.
+func (c cascadeDelete) run(ctx context.Context, db *sql.DB, key any) (int64, error) {
+	query := `
+	WITH doomed AS (
+		SELECT user_id, segment_id
+		FROM user_segments
+		WHERE ` + c.column + ` = $1
+		ORDER BY user_id, segment_id
+		LIMIT $2
+		FOR UPDATE
+	), deleted AS (
+		DELETE FROM user_segments us
+		USING doomed d
+		WHERE us.user_id = d.user_id
+		AND us.segment_id = d.segment_id
+		RETURNING us.user_id, us.segment_id
+	), changed AS (
+		SELECT d.user_id, s.slug
+		FROM deleted d
+		JOIN segments s ON s.id = d.segment_id
+	), history AS (
+		` + historyInsert("changed", models.DELETE, 4) + `
+	), events AS (
+		` + outboxMembershipInsert("changed", models.ActionDelete, "$3") + `
+	)
+	SELECT COUNT(*) FROM changed;`
+
+	var total int64
+	for {
+		removed, done, err := c.batch(ctx, db, key, query)
+		total += removed
+		if err != nil || done {
+			return total, err
+		}
+	}
+}
+
+func (c cascadeDelete) batch(ctx context.Context, db *sql.DB, key any, query string) (removed int64, done bool, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRowContext(ctx, c.lock, key).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, true, nil
+		}
+		return 0, false, fmt.Errorf("failed to lock %v: %w", key, err)
+	}
+
+	source := cmp.Or(c.source, models.SourceCascade)
+	args := append([]any{id, cascadeBatchSize, outboxHeaders(ctx, nil)}, historyArgs(ctx, source, c.reason)...)
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&removed); err != nil {
+		return 0, false, fmt.Errorf("failed to remove memberships of %v: %w", key, err)
+	}
+
+	if removed < cascadeBatchSize {
+		if _, err := tx.ExecContext(ctx, c.delete, id); err != nil {
+			return 0, false, fmt.Errorf("failed to delete %v: %w", key, err)
+		}
+		done = true
+	}
+	return removed, done, tx.Commit()
+}
@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"API/internal/models"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// JobStore queues background jobs and records the attempts of the workers
+// running them.
+//
+//go:generate mockery --name=JobStore --output=mocks --outpkg=mocks
+type JobStore interface {
+	EnqueueJob(ctx context.Context, job models.Job) (models.Job, error)
+	GetJob(ctx context.Context, id int64) (models.Job, error)
+	ClaimJob(ctx context.Context, worker string, kinds []string, lease time.Duration) (models.Job, bool, error)
+	HeartbeatJob(ctx context.Context, id int64, worker string, progress float64, lease time.Duration) (bool, error)
+	CompleteJob(ctx context.Context, id int64, worker string, result json.RawMessage) error
+	RetryJob(ctx context.Context, id int64, worker, reason string, delay time.Duration) error
+	FailJob(ctx context.Context, id int64, worker, reason string) error
+	ReleaseJob(ctx context.Context, id int64, worker string) error
+	MarkJobCancelled(ctx context.Context, id int64, worker string) error
+	CancelJob(ctx context.Context, id int64) (models.Job, error)
+	DeleteFinishedJobs(ctx context.Context, before time.Time) (int64, error)
+}
+
+type JobRepository interface {
+	JobStore
+	SaveJob(ctx context.Context, q DBTX, job models.Job) (models.Job, error)
+}
+
+type JobRepositoryDB struct {
+	DB *sql.DB
+}
+
+func NewJobRepository(db *sql.DB) *JobRepositoryDB {
+	return &JobRepositoryDB{DB: db}
+}
+
+const jobColumns = `id, kind, payload, status, progress, result, COALESCE(error, ''), attempts, max_attempts,
+	cancel_requested, COALESCE(actor, ''), COALESCE(request_id, ''), run_at, created_at, updated_at,
+	started_at, finished_at, actor_teams, actor_scopes`
+
+func scanJob(row rowScanner) (models.Job, error) {
+	var (
+		job             models.Job
+		payload, result []byte
+		scopes          []string
+	)
+	err := row.Scan(&job.ID, &job.Kind, &payload, &job.Status, &job.Progress, &result, &job.Error, &job.Attempts,
+		&job.MaxAttempts, &job.CancelRequested, &job.Actor, &job.RequestID, &job.RunAt, &job.CreatedAt, &job.UpdatedAt,
+		&job.StartedAt, &job.FinishedAt, pq.Array(&job.ActorTeams), pq.Array(&scopes))
+	if err != nil {
+		return models.Job{}, err
+	}
+	job.Payload = payload
+	job.Result = result
+	for _, scope := range scopes {
+		job.ActorScopes = append(job.ActorScopes, models.Scope(scope))
+	}
+	return job, nil
+}
+
+// SaveJob queues job using q, which is normally the transaction that stores
+// what the job works on, so the job exists exactly when its input does.
+func (r *JobRepositoryDB) SaveJob(ctx context.Context, q DBTX, job models.Job) (models.Job, error) {
+	query := `
+	INSERT INTO jobs (kind, payload, max_attempts, actor, request_id, actor_teams, actor_scopes)
+	VALUES ($1, $2::JSONB, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7)
+	RETURNING ` + jobColumns + `;`
+
+	// Empty rather than nil slices, which would be stored as NULL.
+	teams := append([]string{}, job.ActorTeams...)
+	scopes := make([]string, 0, len(job.ActorScopes))
+	for _, scope := range job.ActorScopes {
+		scopes = append(scopes, string(scope))
+	}
+	saved, err := scanJob(q.QueryRowContext(ctx, query, job.Kind, cmp.Or(string(job.Payload), "{}"), job.MaxAttempts,
+		job.Actor, job.RequestID, pq.Array(teams), pq.Array(scopes)))
+	if err != nil {
+		return models.Job{}, fmt.Errorf("failed to queue %s job: %w", job.Kind, err)
+	}
+	return saved, nil
+}
+
+// EnqueueJob queues a job that does not belong to any other change.
+func (r *JobRepositoryDB) EnqueueJob(ctx context.Context, job models.Job) (models.Job, error) {
+	return r.SaveJob(ctx, r.DB, job)
+}
+
+func (r *JobRepositoryDB) GetJob(ctx context.Context, id int64) (models.Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE id = $1;`
+
+	job, err := scanJob(r.DB.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Job{}, fmt.Errorf("%w: %d", models.ErrJobNotFound, id)
+	}
+	return job, err
+}
+
+// ClaimJob starts the next attempt of the oldest due job of one of kinds and
+// leases it to worker for lease. Queued jobs are claimed once they are due,
+// running jobs once their lease expired, which resumes the jobs of a replica
+// that stopped. SKIP LOCKED lets replicas claim jobs concurrently without
+// waiting for each other or claiming the same job. It returns false when no
+// job is due.
+func (r *JobRepositoryDB) ClaimJob(ctx context.Context, worker string, kinds []string, lease time.Duration) (models.Job, bool, error) {
+	query := `
+	UPDATE jobs
+	SET status = 'running', attempts = attempts + 1, locked_by = $1,
+		locked_until = NOW() + make_interval(secs => $3), started_at = COALESCE(started_at, NOW()), updated_at = NOW()
+	WHERE id = (
+		SELECT id FROM jobs
+		WHERE kind = ANY($2)
+		AND (
+			(status = 'queued' AND run_at <= NOW())
+			OR (status = 'running' AND locked_until < NOW())
+		)
+		ORDER BY run_at, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + jobColumns + `;`
+
+	job, err := scanJob(r.DB.QueryRowContext(ctx, query, worker, pq.Array(kinds), lease.Seconds()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Job{}, false, nil
+	}
+	if err != nil {
+		return models.Job{}, false, fmt.Errorf("failed to claim job: %w", err)
+	}
+	return job, true, nil
+}
+
+// HeartbeatJob saves the progress of a running job, extends the lease of
+// worker and reports whether the job was cancelled. It returns
+// ErrJobLeaseLost when worker does not hold the job anymore.
+func (r *JobRepositoryDB) HeartbeatJob(ctx context.Context, id int64, worker string, progress float64, lease time.Duration) (bool, error) {
+	const query = `
+	UPDATE jobs
+	SET progress = $3, locked_until = NOW() + make_interval(secs => $4), updated_at = NOW()
+	WHERE id = $1
+	AND locked_by = $2
+	AND status = 'running'
+	RETURNING cancel_requested;`
+
+	var cancelled bool
+	err := r.DB.QueryRowContext(ctx, query, id, worker, progress, lease.Seconds()).Scan(&cancelled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("%w: %d", models.ErrJobLeaseLost, id)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to save progress of job %d: %w", id, err)
+	}
+	return cancelled, nil
+}
+
+// CompleteJob marks the running attempt of worker succeeded with result.
+func (r *JobRepositoryDB) CompleteJob(ctx context.Context, id int64, worker string, result json.RawMessage) error {
+	var value any
+	if result != nil {
+		value = string(result)
+	}
+	return r.endAttempt(ctx, id, worker,
+		`status = 'succeeded', progress = 100, result = $3::JSONB, error = NULL, finished_at = NOW()`, value)
+}
+
+// RetryJob ends the failed attempt of worker and queues the next one after
+// delay.
+func (r *JobRepositoryDB) RetryJob(ctx context.Context, id int64, worker, reason string, delay time.Duration) error {
+	return r.endAttempt(ctx, id, worker,
+		`status = 'queued', error = $3, run_at = NOW() + make_interval(secs => $4)`, reason, delay.Seconds())
+}
+
+// FailJob ends the last attempt of worker and fails the job with reason.
+func (r *JobRepositoryDB) FailJob(ctx context.Context, id int64, worker, reason string) error {
+	return r.endAttempt(ctx, id, worker, `status = 'failed', error = $3, finished_at = NOW()`, reason)
+}
+
+// ReleaseJob queues the job of a worker that stops without finishing it. The
+// attempt is not counted, so a restart does not use up the attempts of a job.
+func (r *JobRepositoryDB) ReleaseJob(ctx context.Context, id int64, worker string) error {
+	return r.endAttempt(ctx, id, worker, `status = 'queued', attempts = attempts - 1, run_at = NOW()`)
+}
+
+// MarkJobCancelled ends the attempt of worker that stopped on a cancellation.
+func (r *JobRepositoryDB) MarkJobCancelled(ctx context.Context, id int64, worker string) error {
+	return r.endAttempt(ctx, id, worker, `status = 'cancelled', finished_at = NOW()`)
+}
+
+// endAttempt applies set to the job if worker still holds it and releases
+// the lease. Further arguments of set start at $3.
+func (r *JobRepositoryDB) endAttempt(ctx context.Context, id int64, worker, set string, args ...any) error {
+	query := `
+	UPDATE jobs
+	SET ` + set + `, locked_by = NULL, locked_until = NULL, updated_at = NOW()
+	WHERE id = $1
+	AND locked_by = $2
+	AND status = 'running';`
+
+	result, err := r.DB.ExecContext(ctx, query, append([]any{id, worker}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to end attempt of job %d: %w", id, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: %d", models.ErrJobLeaseLost, id)
+	}
+	return nil
+}
+
+// CancelJob cancels a queued job at once and asks the worker of a running job
+// to stop, which it notices at its next heartbeat. It returns ErrJobFinished
+// for a finished job.
+func (r *JobRepositoryDB) CancelJob(ctx context.Context, id int64) (models.Job, error) {
+	query := `
+	UPDATE jobs
+	SET cancel_requested = TRUE,
+		status = CASE WHEN status = 'queued' THEN 'cancelled' ELSE status END,
+		finished_at = CASE WHEN status = 'queued' THEN NOW() END,
+		updated_at = NOW()
+	WHERE id = $1
+	AND status IN ('queued', 'running')
+	RETURNING ` + jobColumns + `;`
+
+	job, err := scanJob(r.DB.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := r.GetJob(ctx, id); err != nil {
+			return models.Job{}, err
+		}
+		return models.Job{}, fmt.Errorf("%w: %d", models.ErrJobFinished, id)
+	}
+	if err != nil {
+		return models.Job{}, fmt.Errorf("failed to cancel job %d: %w", id, err)
+	}
+	return job, nil
+}
+
+// DeleteFinishedJobs removes jobs that finished before the given time. What
+// a job worked on is deleted with it by foreign keys, such as the bulk job
+// and its rows of a bulk_membership job.
+func (r *JobRepositoryDB) DeleteFinishedJobs(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.DB.ExecContext(ctx, `DELETE FROM jobs WHERE finished_at < $1;`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete finished jobs: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"API/internal/models"
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func jobRows(id int64, status models.JobStatus, attempts int) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows([]string{"id", "kind", "payload", "status", "progress", "result", "error", "attempts",
+		"max_attempts", "cancel_requested", "actor", "request_id", "run_at", "created_at", "updated_at",
+		"started_at", "finished_at", "actor_teams", "actor_scopes"}).
+		AddRow(id, models.JobBulkMembership, []byte(`{"bulk_job_id":7}`), status, 0, nil, "", attempts,
+			3, false, "billing", "req-1", now, now, now, nil, nil, "{growth}", "{users:write}")
+}
+
+func TestClaimJob(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewJobRepository(mockDB)
+	claim := regexp.QuoteMeta("FOR UPDATE SKIP LOCKED")
+
+	t.Run("should lease the next due job", func(t *testing.T) {
+		mock.ExpectQuery(claim).
+			WithArgs("replica-1", `{"bulk_membership","history_report"}`, 60.0).
+			WillReturnRows(jobRows(43, models.JobRunning, 1))
+
+		job, ok, err := repo.ClaimJob(context.Background(), "replica-1", []string{"bulk_membership", "history_report"}, time.Minute)
+
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, int64(43), job.ID)
+		assert.Equal(t, 1, job.Attempts)
+		assert.JSONEq(t, `{"bulk_job_id":7}`, string(job.Payload))
+		assert.Nil(t, job.Result)
+		assert.Equal(t, []string{"growth"}, job.ActorTeams)
+		assert.Equal(t, []models.Scope{models.ScopeUsersWrite}, job.ActorScopes)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should report that no job is due", func(t *testing.T) {
+		mock.ExpectQuery(claim).WillReturnError(sql.ErrNoRows)
+
+		_, ok, err := repo.ClaimJob(context.Background(), "replica-1", []string{"bulk_membership"}, time.Minute)
+
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestEndJobAttempt(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewJobRepository(mockDB)
+
+	t.Run("should queue the next attempt after the delay", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("SET status = 'queued', error = $3, run_at = NOW() + make_interval(secs => $4)")).
+			WithArgs(43, "replica-1", "database error", 20.0).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.RetryJob(context.Background(), 43, "replica-1", "database error", 20*time.Second)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should fail when another worker holds the job", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("AND locked_by = $2")).
+			WithArgs(43, "replica-1", `{"removed":3}`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.CompleteJob(context.Background(), 43, "replica-1", []byte(`{"removed":3}`))
+
+		assert.ErrorIs(t, err, models.ErrJobLeaseLost)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCancelJob(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewJobRepository(mockDB)
+	cancel := regexp.QuoteMeta("SET cancel_requested = TRUE")
+
+	t.Run("should ask a running job to stop", func(t *testing.T) {
+		mock.ExpectQuery(cancel).WithArgs(43).WillReturnRows(jobRows(43, models.JobRunning, 1))
+
+		job, err := repo.CancelJob(context.Background(), 43)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.JobRunning, job.Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should refuse a finished job", func(t *testing.T) {
+		mock.ExpectQuery(cancel).WithArgs(43).WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(regexp.QuoteMeta("FROM jobs WHERE id = $1;")).
+			WithArgs(43).
+			WillReturnRows(jobRows(43, models.JobSucceeded, 1))
+
+		_, err := repo.CancelJob(context.Background(), 43)
+
+		assert.ErrorIs(t, err, models.ErrJobFinished)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should report an unknown job", func(t *testing.T) {
+		mock.ExpectQuery(cancel).WithArgs(44).WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery(regexp.QuoteMeta("FROM jobs WHERE id = $1;")).WithArgs(44).WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.CancelJob(context.Background(), 44)
+
+		assert.ErrorIs(t, err, models.ErrJobNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,149 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	models "API/internal/models"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// BulkJobRepository is an autogenerated mock type for the BulkJobRepository type
+type BulkJobRepository struct {
+	mock.Mock
+}
+
+// ApplyBulkChunk provides a mock function with given fields: ctx, id, size
+func (_m *BulkJobRepository) ApplyBulkChunk(ctx context.Context, id int64, size int64) (models.BulkJob, error) {
+	ret := _m.Called(ctx, id, size)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ApplyBulkChunk")
+	}
+
+	var r0 models.BulkJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) (models.BulkJob, error)); ok {
+		return rf(ctx, id, size)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) models.BulkJob); ok {
+		r0 = rf(ctx, id, size)
+	} else {
+		r0 = ret.Get(0).(models.BulkJob)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int64) error); ok {
+		r1 = rf(ctx, id, size)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// BulkJobErrors provides a mock function with given fields: ctx, id, fn
+func (_m *BulkJobRepository) BulkJobErrors(ctx context.Context, id int64, fn func(models.BulkRow) error) error {
+	ret := _m.Called(ctx, id, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BulkJobErrors")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, func(models.BulkRow) error) error); ok {
+		r0 = rf(ctx, id, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateBulkJob provides a mock function with given fields: ctx, job, queued, rows
+func (_m *BulkJobRepository) CreateBulkJob(ctx context.Context, job models.BulkJob, queued models.Job, rows models.BulkRowReader) (models.BulkJob, error) {
+	ret := _m.Called(ctx, job, queued, rows)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateBulkJob")
+	}
+
+	var r0 models.BulkJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.BulkJob, models.Job, models.BulkRowReader) (models.BulkJob, error)); ok {
+		return rf(ctx, job, queued, rows)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.BulkJob, models.Job, models.BulkRowReader) models.BulkJob); ok {
+		r0 = rf(ctx, job, queued, rows)
+	} else {
+		r0 = ret.Get(0).(models.BulkJob)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.BulkJob, models.Job, models.BulkRowReader) error); ok {
+		r1 = rf(ctx, job, queued, rows)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FailBulkJob provides a mock function with given fields: ctx, id, reason
+func (_m *BulkJobRepository) FailBulkJob(ctx context.Context, id int64, reason string) error {
+	ret := _m.Called(ctx, id, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FailBulkJob")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) error); ok {
+		r0 = rf(ctx, id, reason)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetBulkJob provides a mock function with given fields: ctx, id
+func (_m *BulkJobRepository) GetBulkJob(ctx context.Context, id int64) (models.BulkJob, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBulkJob")
+	}
+
+	var r0 models.BulkJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (models.BulkJob, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) models.BulkJob); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(models.BulkJob)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewBulkJobRepository creates a new instance of BulkJobRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewBulkJobRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *BulkJobRepository {
+	mock := &BulkJobRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
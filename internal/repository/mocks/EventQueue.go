@@ -0,0 +1,47 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	models "API/internal/models"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// EventQueue is an autogenerated mock type for the EventQueue type
+type EventQueue struct {
+	mock.Mock
+}
+
+// Enqueue provides a mock function with given fields: ctx, events
+func (_m *EventQueue) Enqueue(ctx context.Context, events []models.OutboxEvent) error {
+	ret := _m.Called(ctx, events)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Enqueue")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []models.OutboxEvent) error); ok {
+		r0 = rf(ctx, events)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewEventQueue creates a new instance of EventQueue. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewEventQueue(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *EventQueue {
+	mock := &EventQueue{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
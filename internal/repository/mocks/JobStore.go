@@ -0,0 +1,298 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	jsontext "encoding/json/jsontext"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "API/internal/models"
+
+	time "time"
+)
+
+// JobStore is an autogenerated mock type for the JobStore type
+type JobStore struct {
+	mock.Mock
+}
+
+// CancelJob provides a mock function with given fields: ctx, id
+func (_m *JobStore) CancelJob(ctx context.Context, id int64) (models.Job, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CancelJob")
+	}
+
+	var r0 models.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (models.Job, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) models.Job); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(models.Job)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClaimJob provides a mock function with given fields: ctx, worker, kinds, lease
+func (_m *JobStore) ClaimJob(ctx context.Context, worker string, kinds []string, lease time.Duration) (models.Job, bool, error) {
+	ret := _m.Called(ctx, worker, kinds, lease)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimJob")
+	}
+
+	var r0 models.Job
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string, time.Duration) (models.Job, bool, error)); ok {
+		return rf(ctx, worker, kinds, lease)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string, time.Duration) models.Job); ok {
+		r0 = rf(ctx, worker, kinds, lease)
+	} else {
+		r0 = ret.Get(0).(models.Job)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, []string, time.Duration) bool); ok {
+		r1 = rf(ctx, worker, kinds, lease)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, []string, time.Duration) error); ok {
+		r2 = rf(ctx, worker, kinds, lease)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// CompleteJob provides a mock function with given fields: ctx, id, worker, result
+func (_m *JobStore) CompleteJob(ctx context.Context, id int64, worker string, result jsontext.Value) error {
+	ret := _m.Called(ctx, id, worker, result)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CompleteJob")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, jsontext.Value) error); ok {
+		r0 = rf(ctx, id, worker, result)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteFinishedJobs provides a mock function with given fields: ctx, before
+func (_m *JobStore) DeleteFinishedJobs(ctx context.Context, before time.Time) (int64, error) {
+	ret := _m.Called(ctx, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteFinishedJobs")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) (int64, error)); ok {
+		return rf(ctx, before)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int64); ok {
+		r0 = rf(ctx, before)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// EnqueueJob provides a mock function with given fields: ctx, job
+func (_m *JobStore) EnqueueJob(ctx context.Context, job models.Job) (models.Job, error) {
+	ret := _m.Called(ctx, job)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnqueueJob")
+	}
+
+	var r0 models.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Job) (models.Job, error)); ok {
+		return rf(ctx, job)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Job) models.Job); ok {
+		r0 = rf(ctx, job)
+	} else {
+		r0 = ret.Get(0).(models.Job)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Job) error); ok {
+		r1 = rf(ctx, job)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FailJob provides a mock function with given fields: ctx, id, worker, reason
+func (_m *JobStore) FailJob(ctx context.Context, id int64, worker string, reason string) error {
+	ret := _m.Called(ctx, id, worker, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FailJob")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, string) error); ok {
+		r0 = rf(ctx, id, worker, reason)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetJob provides a mock function with given fields: ctx, id
+func (_m *JobStore) GetJob(ctx context.Context, id int64) (models.Job, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetJob")
+	}
+
+	var r0 models.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (models.Job, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) models.Job); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(models.Job)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HeartbeatJob provides a mock function with given fields: ctx, id, worker, progress, lease
+func (_m *JobStore) HeartbeatJob(ctx context.Context, id int64, worker string, progress float64, lease time.Duration) (bool, error) {
+	ret := _m.Called(ctx, id, worker, progress, lease)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HeartbeatJob")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, float64, time.Duration) (bool, error)); ok {
+		return rf(ctx, id, worker, progress, lease)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, float64, time.Duration) bool); ok {
+		r0 = rf(ctx, id, worker, progress, lease)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string, float64, time.Duration) error); ok {
+		r1 = rf(ctx, id, worker, progress, lease)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkJobCancelled provides a mock function with given fields: ctx, id, worker
+func (_m *JobStore) MarkJobCancelled(ctx context.Context, id int64, worker string) error {
+	ret := _m.Called(ctx, id, worker)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkJobCancelled")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) error); ok {
+		r0 = rf(ctx, id, worker)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReleaseJob provides a mock function with given fields: ctx, id, worker
+func (_m *JobStore) ReleaseJob(ctx context.Context, id int64, worker string) error {
+	ret := _m.Called(ctx, id, worker)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReleaseJob")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) error); ok {
+		r0 = rf(ctx, id, worker)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RetryJob provides a mock function with given fields: ctx, id, worker, reason, delay
+func (_m *JobStore) RetryJob(ctx context.Context, id int64, worker string, reason string, delay time.Duration) error {
+	ret := _m.Called(ctx, id, worker, reason, delay)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RetryJob")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, string, time.Duration) error); ok {
+		r0 = rf(ctx, id, worker, reason, delay)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewJobStore creates a new instance of JobStore. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewJobStore(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *JobStore {
+	mock := &JobStore{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
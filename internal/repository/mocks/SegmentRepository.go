@@ -0,0 +1,393 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	models "API/internal/models"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// SegmentRepository is an autogenerated mock type for the SegmentRepository type
+type SegmentRepository struct {
+	mock.Mock
+}
+
+// ArchiveSegmentDB provides a mock function with given fields: ctx, slug
+func (_m *SegmentRepository) ArchiveSegmentDB(ctx context.Context, slug models.Slug) error {
+	ret := _m.Called(ctx, slug)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ArchiveSegmentDB")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) error); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateSegmentDB provides a mock function with given fields: ctx, segment
+func (_m *SegmentRepository) CreateSegmentDB(ctx context.Context, segment models.Segments) (int64, error) {
+	ret := _m.Called(ctx, segment)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateSegmentDB")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Segments) (int64, error)); ok {
+		return rf(ctx, segment)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Segments) int64); ok {
+		r0 = rf(ctx, segment)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Segments) error); ok {
+		r1 = rf(ctx, segment)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteSegmentDB provides a mock function with given fields: ctx, slug
+func (_m *SegmentRepository) DeleteSegmentDB(ctx context.Context, slug models.Slug) error {
+	ret := _m.Called(ctx, slug)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteSegmentDB")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) error); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ExpireSegments provides a mock function with given fields: ctx, limit
+func (_m *SegmentRepository) ExpireSegments(ctx context.Context, limit int) ([]models.Slug, error) {
+	ret := _m.Called(ctx, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExpireSegments")
+	}
+
+	var r0 []models.Slug
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]models.Slug, error)); ok {
+		return rf(ctx, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []models.Slug); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Slug)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOneSegmentID provides a mock function with given fields: ctx, slug
+func (_m *SegmentRepository) GetOneSegmentID(ctx context.Context, slug models.Slug) (int64, error) {
+	ret := _m.Called(ctx, slug)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetOneSegmentID")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) (int64, error)); ok {
+		return rf(ctx, slug)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) int64); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Slug) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSegmentACLs provides a mock function with given fields: ctx, slugs
+func (_m *SegmentRepository) GetSegmentACLs(ctx context.Context, slugs []models.Slug) ([]models.SegmentACL, error) {
+	ret := _m.Called(ctx, slugs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSegmentACLs")
+	}
+
+	var r0 []models.SegmentACL
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []models.Slug) ([]models.SegmentACL, error)); ok {
+		return rf(ctx, slugs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []models.Slug) []models.SegmentACL); ok {
+		r0 = rf(ctx, slugs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.SegmentACL)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []models.Slug) error); ok {
+		r1 = rf(ctx, slugs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSegmentDB provides a mock function with given fields: ctx, slug
+func (_m *SegmentRepository) GetSegmentDB(ctx context.Context, slug models.Slug) (models.Segments, error) {
+	ret := _m.Called(ctx, slug)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSegmentDB")
+	}
+
+	var r0 models.Segments
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) (models.Segments, error)); ok {
+		return rf(ctx, slug)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) models.Segments); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Get(0).(models.Segments)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Slug) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LookupSegments provides a mock function with given fields: ctx, slugs
+func (_m *SegmentRepository) LookupSegments(ctx context.Context, slugs []models.Slug) ([]models.SegmentRef, error) {
+	ret := _m.Called(ctx, slugs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LookupSegments")
+	}
+
+	var r0 []models.SegmentRef
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []models.Slug) ([]models.SegmentRef, error)); ok {
+		return rf(ctx, slugs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []models.Slug) []models.SegmentRef); ok {
+		r0 = rf(ctx, slugs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.SegmentRef)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []models.Slug) error); ok {
+		r1 = rf(ctx, slugs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeArchivedSegments provides a mock function with given fields: ctx, before, limit
+func (_m *SegmentRepository) PurgeArchivedSegments(ctx context.Context, before time.Time, limit int) ([]models.Slug, error) {
+	ret := _m.Called(ctx, before, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeArchivedSegments")
+	}
+
+	var r0 []models.Slug
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) ([]models.Slug, error)); ok {
+		return rf(ctx, before, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []models.Slug); ok {
+		r0 = rf(ctx, before, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Slug)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, before, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeSegmentDB provides a mock function with given fields: ctx, slug
+func (_m *SegmentRepository) PurgeSegmentDB(ctx context.Context, slug models.Slug) (int64, error) {
+	ret := _m.Called(ctx, slug)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeSegmentDB")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) (int64, error)); ok {
+		return rf(ctx, slug)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) int64); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Slug) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RestoreSegmentDB provides a mock function with given fields: ctx, slug
+func (_m *SegmentRepository) RestoreSegmentDB(ctx context.Context, slug models.Slug) error {
+	ret := _m.Called(ctx, slug)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreSegmentDB")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) error); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SelectAllSegmentsDB provides a mock function with given fields: ctx, filter
+func (_m *SegmentRepository) SelectAllSegmentsDB(ctx context.Context, filter models.SegmentFilter) ([]models.Segments, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SelectAllSegmentsDB")
+	}
+
+	var r0 []models.Segments
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.SegmentFilter) ([]models.Segments, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.SegmentFilter) []models.Segments); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Segments)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.SegmentFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetSegmentACL provides a mock function with given fields: ctx, acl
+func (_m *SegmentRepository) SetSegmentACL(ctx context.Context, acl models.SegmentACL) error {
+	ret := _m.Called(ctx, acl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetSegmentACL")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.SegmentACL) error); ok {
+		r0 = rf(ctx, acl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateSegmentDB provides a mock function with given fields: ctx, segment
+func (_m *SegmentRepository) UpdateSegmentDB(ctx context.Context, segment models.Segments) (models.Segments, error) {
+	ret := _m.Called(ctx, segment)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateSegmentDB")
+	}
+
+	var r0 models.Segments
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Segments) (models.Segments, error)); ok {
+		return rf(ctx, segment)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Segments) models.Segments); ok {
+		r0 = rf(ctx, segment)
+	} else {
+		r0 = ret.Get(0).(models.Segments)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Segments) error); ok {
+		r1 = rf(ctx, segment)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewSegmentRepository creates a new instance of SegmentRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewSegmentRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *SegmentRepository {
+	mock := &SegmentRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,253 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	models "API/internal/models"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// UserSegmentRepository is an autogenerated mock type for the UserSegmentRepository type
+type UserSegmentRepository struct {
+	mock.Mock
+}
+
+// CountSegmentUsersDB provides a mock function with given fields: ctx, slug
+func (_m *UserSegmentRepository) CountSegmentUsersDB(ctx context.Context, slug models.Slug) (int64, error) {
+	ret := _m.Called(ctx, slug)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountSegmentUsersDB")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) (int64, error)); ok {
+		return rf(ctx, slug)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) int64); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Slug) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateAutoEnrolledUser provides a mock function with given fields: ctx, user
+func (_m *UserSegmentRepository) CreateAutoEnrolledUser(ctx context.Context, user *models.Users) ([]models.Slug, error) {
+	ret := _m.Called(ctx, user)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateAutoEnrolledUser")
+	}
+
+	var r0 []models.Slug
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Users) ([]models.Slug, error)); ok {
+		return rf(ctx, user)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Users) []models.Slug); ok {
+		r0 = rf(ctx, user)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Slug)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *models.Users) error); ok {
+		r1 = rf(ctx, user)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteExpiredUserSegments provides a mock function with given fields: ctx, limit
+func (_m *UserSegmentRepository) DeleteExpiredUserSegments(ctx context.Context, limit int) ([]models.UserSegment, error) {
+	ret := _m.Called(ctx, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteExpiredUserSegments")
+	}
+
+	var r0 []models.UserSegment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]models.UserSegment, error)); ok {
+		return rf(ctx, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []models.UserSegment); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.UserSegment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteUserSegment provides a mock function with given fields: ctx, userID, slug
+func (_m *UserSegmentRepository) DeleteUserSegment(ctx context.Context, userID int64, slug models.Slug) error {
+	ret := _m.Called(ctx, userID, slug)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUserSegment")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, models.Slug) error); ok {
+		r0 = rf(ctx, userID, slug)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetAllUserSegmentsDB provides a mock function with given fields: ctx
+func (_m *UserSegmentRepository) GetAllUserSegmentsDB(ctx context.Context) ([]models.UserSegment, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAllUserSegmentsDB")
+	}
+
+	var r0 []models.UserSegment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]models.UserSegment, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []models.UserSegment); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.UserSegment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSegmentUsersDB provides a mock function with given fields: ctx, slug, cursor, limit
+func (_m *UserSegmentRepository) GetSegmentUsersDB(ctx context.Context, slug models.Slug, cursor int64, limit int) ([]models.SegmentMember, error) {
+	ret := _m.Called(ctx, slug, cursor, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSegmentUsersDB")
+	}
+
+	var r0 []models.SegmentMember
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug, int64, int) ([]models.SegmentMember, error)); ok {
+		return rf(ctx, slug, cursor, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug, int64, int) []models.SegmentMember); ok {
+		r0 = rf(ctx, slug, cursor, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.SegmentMember)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Slug, int64, int) error); ok {
+		r1 = rf(ctx, slug, cursor, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserSegmentsDВ provides a mock function with given fields: ctx, id
+func (_m *UserSegmentRepository) GetUserSegmentsDВ(ctx context.Context, id int64) (models.UserSegments, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserSegmentsDВ")
+	}
+
+	var r0 models.UserSegments
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (models.UserSegments, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) models.UserSegments); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(models.UserSegments)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateUserSegments provides a mock function with given fields: ctx, slugsToAdd, slugsToDelete, userID, ttl, mode
+func (_m *UserSegmentRepository) UpdateUserSegments(ctx context.Context, slugsToAdd []models.Slug, slugsToDelete []models.Slug, userID int64, ttl *time.Time, mode models.UpdateMode) (models.UpdateSegmentsResult, error) {
+	ret := _m.Called(ctx, slugsToAdd, slugsToDelete, userID, ttl, mode)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateUserSegments")
+	}
+
+	var r0 models.UpdateSegmentsResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []models.Slug, []models.Slug, int64, *time.Time, models.UpdateMode) (models.UpdateSegmentsResult, error)); ok {
+		return rf(ctx, slugsToAdd, slugsToDelete, userID, ttl, mode)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []models.Slug, []models.Slug, int64, *time.Time, models.UpdateMode) models.UpdateSegmentsResult); ok {
+		r0 = rf(ctx, slugsToAdd, slugsToDelete, userID, ttl, mode)
+	} else {
+		r0 = ret.Get(0).(models.UpdateSegmentsResult)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []models.Slug, []models.Slug, int64, *time.Time, models.UpdateMode) error); ok {
+		r1 = rf(ctx, slugsToAdd, slugsToDelete, userID, ttl, mode)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewUserSegmentRepository creates a new instance of UserSegmentRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewUserSegmentRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *UserSegmentRepository {
+	mock := &UserSegmentRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
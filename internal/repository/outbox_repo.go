@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"API/internal/logging"
+	"API/internal/models"
+	"API/internal/tracing"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// outboxRelayLockID is the advisory lock held by the relay that is currently
+// publishing, so only one replica drains the outbox and the order is kept.
+const outboxRelayLockID int64 = 0x6f7574626f78
+
+// outboxMembershipInsert enqueues a membership event for every row of the
+// relation named in from, which must expose user_id and slug columns. It is
+// used as a CTE by statements that change many memberships at once; headers
+// is the placeholder of the JSON headers argument, see outboxHeaders.
+func outboxMembershipInsert(from, action, headers string) string {
+	return `INSERT INTO outbox (topic, key, payload, headers)
+		SELECT '` + models.TopicUserSegments + `', user_id::TEXT,
+			json_build_object('user_id', user_id, 'segment', slug, 'action', '` + action + `'),
+			` + headers + `::JSONB
+		FROM ` + from + `
+		ORDER BY user_id, slug`
+}
+
+// outboxHeaders returns the JSON encoded Kafka headers of events written
+// under ctx, so consumers can continue the trace of the change and log it
+// with its request ID.
+func outboxHeaders(ctx context.Context, headers map[string]string) string {
+	merged := tracing.Headers(ctx)
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		merged[logging.RequestIDKey] = requestID
+	}
+	for key, value := range headers {
+		merged[key] = value
+	}
+	encoded, _ := json.Marshal(merged)
+	return string(encoded)
+}
+
+// EventQueue enqueues events that are not part of a membership change, such
+// as command replies.
+//
+//go:generate mockery --name=EventQueue --output=mocks --outpkg=mocks
+type EventQueue interface {
+	Enqueue(ctx context.Context, events []models.OutboxEvent) error
+}
+
+type OutboxRepository interface {
+	EventQueue
+	SaveEvents(ctx context.Context, q DBTX, events []models.OutboxEvent) error
+	PublishPending(ctx context.Context, limit int, publish func(event models.OutboxEvent) error) (int, error)
+	DeleteSentBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type OutboxRepositoryDB struct {
+	DB *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepositoryDB {
+	return &OutboxRepositoryDB{DB: db}
+}
+
+// SaveEvents stores events in the outbox using q, which is normally the
+// transaction that made the change the events describe.
+func (r *OutboxRepositoryDB) SaveEvents(ctx context.Context, q DBTX, events []models.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	topics := make([]string, len(events))
+	keys := make([]string, len(events))
+	payloads := make([]string, len(events))
+	headers := make([]string, len(events))
+	for i, event := range events {
+		topics[i] = event.Topic
+		keys[i] = event.Key
+		payloads[i] = string(event.Payload)
+		headers[i] = outboxHeaders(ctx, event.Headers)
+	}
+
+	const query = `
+	INSERT INTO outbox (topic, key, payload, headers)
+	SELECT topic, key, payload::JSONB, headers::JSONB
+	FROM UNNEST($1::TEXT[], $2::TEXT[], $3::TEXT[], $4::TEXT[]) WITH ORDINALITY AS e(topic, key, payload, headers, n)
+	ORDER BY n;`
+
+	if _, err := q.ExecContext(ctx, query, pq.Array(topics), pq.Array(keys), pq.Array(payloads), pq.Array(headers)); err != nil {
+		return fmt.Errorf("failed to save outbox events: %w", err)
+	}
+	return nil
+}
+
+// Enqueue stores events that do not belong to any other change.
+func (r *OutboxRepositoryDB) Enqueue(ctx context.Context, events []models.OutboxEvent) error {
+	return r.SaveEvents(ctx, r.DB, events)
+}
+
+// PublishPending passes up to limit unsent events to publish in insertion
+// order and marks the published ones as sent. It stops at the first failure
+// so later events never overtake an earlier one, records the failure on that
+// event and returns the error. When another relay holds the lock it does
+// nothing.
+func (r *OutboxRepositoryDB) PublishPending(ctx context.Context, limit int, publish func(event models.OutboxEvent) error) (int, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, outboxRelayLockID).Scan(&locked); err != nil {
+		return 0, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	const query = `
+	SELECT id, topic, key, payload, headers, attempts
+	FROM outbox
+	WHERE sent_at IS NULL
+	ORDER BY id
+	LIMIT $1;`
+
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch outbox events: %w", err)
+	}
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var (
+			event   models.OutboxEvent
+			headers []byte
+		)
+		if err := rows.Scan(&event.ID, &event.Topic, &event.Key, &event.Payload, &headers, &event.Attempts); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		if err := json.Unmarshal(headers, &event.Headers); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to decode headers of outbox event %d: %w", event.ID, err)
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var (
+		sent       []int64
+		publishErr error
+	)
+	for _, event := range events {
+		if publishErr = publish(event); publishErr != nil {
+			const failQuery = `UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1;`
+			if _, err := tx.ExecContext(ctx, failQuery, event.ID, publishErr.Error()); err != nil {
+				return 0, fmt.Errorf("failed to record outbox failure: %w", err)
+			}
+			break
+		}
+		sent = append(sent, event.ID)
+	}
+
+	if len(sent) > 0 {
+		const sentQuery = `UPDATE outbox SET sent_at = NOW() WHERE id = ANY($1);`
+		if _, err := tx.ExecContext(ctx, sentQuery, pq.Array(sent)); err != nil {
+			return 0, fmt.Errorf("failed to mark outbox events as sent: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(sent), publishErr
+}
+
+// DeleteSentBefore removes events published before the given time.
+func (r *OutboxRepositoryDB) DeleteSentBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.DB.ExecContext(ctx, `DELETE FROM outbox WHERE sent_at < $1;`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sent outbox events: %w", err)
+	}
+	return res.RowsAffected()
+}
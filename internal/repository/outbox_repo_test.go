@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"API/internal/logging"
+	"API/internal/models"
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishPending(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewOutboxRepository(mockDB)
+
+	t.Run("should do nothing when another relay holds the lock", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT pg_try_advisory_xact_lock($1)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+		mock.ExpectRollback()
+
+		sent, err := repo.PublishPending(context.Background(), 10, func(models.OutboxEvent) error {
+			t.Fatal("publish must not be called")
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, sent)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should stop at the first failed event and keep the rest pending", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT pg_try_advisory_xact_lock($1)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+		mock.ExpectQuery(regexp.QuoteMeta("FROM outbox")).
+			WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "key", "payload", "headers", "attempts"}).
+				AddRow(1, models.TopicUserSegments, "1000", []byte(`{}`), []byte(`{}`), 0).
+				AddRow(2, models.TopicUserSegments, "1000", []byte(`{}`), []byte(`{}`), 0).
+				AddRow(3, models.TopicUserSegments, "1000", []byte(`{}`), []byte(`{}`), 0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET attempts = attempts + 1`)).
+			WithArgs(2, "kafka is down").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET sent_at = NOW()`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		var published []int64
+		sent, err := repo.PublishPending(context.Background(), 10, func(event models.OutboxEvent) error {
+			if event.ID == 2 {
+				return errors.New("kafka is down")
+			}
+			published = append(published, event.ID)
+			return nil
+		})
+
+		assert.EqualError(t, err, "kafka is down")
+		assert.Equal(t, 1, sent)
+		assert.Equal(t, []int64{1}, published)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOutboxHeaders(t *testing.T) {
+	t.Run("should carry the request ID of the context", func(t *testing.T) {
+		ctx := logging.WithRequestID(context.Background(), "req-1")
+
+		headers := outboxHeaders(ctx, map[string]string{"key": "value"})
+
+		assert.JSONEq(t, `{"request_id": "req-1", "key": "value"}`, headers)
+	})
+
+	t.Run("should be empty without request ID and trace", func(t *testing.T) {
+		assert.JSONEq(t, `{}`, outboxHeaders(context.Background(), nil))
+	})
+}
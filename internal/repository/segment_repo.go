@@ -1,123 +1,496 @@
-package repository
-
-import (
-	"API/internal/models"
-	"database/sql"
-	"fmt"
-	"log/slog"
-
-	"github.com/lib/pq"
-)
-
-type SegmentRepository interface {
-	CreateSegmentDB(slug models.Slug) error
-	DeleteSegmentDB(slug models.Slug) error
-	SelectAllSegmentsDB() ([]models.Segments, error)
-	GetSegmentID(slugs []models.Slug) ([]int64, error)
-	GetOneSegmentID(slug models.Slug) (int64, error)
-}
-
-type SegmentRepositoryDB struct {
-	DB *sql.DB
-}
-
-func NewSegmentRepository(db *sql.DB) *SegmentRepositoryDB {
-	return &SegmentRepositoryDB{DB: db}
-}
-
-func (r *SegmentRepositoryDB) CreateSegmentDB(slug models.Slug) error {
-	const op = "internal/repository/CreateSegment"
-
-	queryCheck := `SELECT id FROM segments WHERE slug = $1`
-	var id int64
-	err := r.DB.QueryRow(queryCheck, slug).Scan(&id)
-	if err == nil {
-		return fmt.Errorf("segment already exists with id %d", id)
-	} else if err != sql.ErrNoRows {
-		return err
-	}
-
-	query := `INSERT INTO segments (slug) VALUES ($1);`
-	if _, err := r.DB.Exec(query, slug); err != nil {
-		slog.String("op", op)
-		return err
-	}
-	return nil
-}
-
-func (r *SegmentRepositoryDB) DeleteSegmentDB(slug models.Slug) error {
-	const op = "internal/repository/DeleteSegment"
-	query := `DELETE FROM segments WHERE slug = $1`
-
-	if _, err := r.DB.Exec(query, slug); err != nil {
-		slog.String("op", op)
-		return err
-	}
-	return nil
-}
-
-func (r *SegmentRepositoryDB) SelectAllSegmentsDB() ([]models.Segments, error) {
-	const op = "internal/repository/SelectAllSegments"
-	query := `SELECT id, slug FROM segments`
-
-	rows, err := r.DB.Query(query)
-	if err != nil {
-		slog.String("op", op)
-		return nil, err
-	}
-	defer rows.Close()
-	var segments []models.Segments
-	for rows.Next() {
-		var segment models.Segments
-		if err := rows.Scan(&segment.ID, &segment.Slug); err != nil {
-			slog.String("op", op)
-			return nil, err
-		}
-		segments = append(segments, segment)
-	}
-	if err := rows.Err(); err != nil {
-		slog.String("op", op)
-		return nil, err
-	}
-	return segments, nil
-}
-
-func (r *SegmentRepositoryDB) GetSegmentID(slugs []models.Slug) ([]int64, error) {
-	query := `SELECT id FROM segments WHERE slug = ANY($1);`
-
-	rows, err := r.DB.Query(query, pq.Array(slugs))
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
-	}
-	defer rows.Close()
-	var segmentIDs []int64
-	for rows.Next() {
-		var id int64
-		if err := rows.Scan(&id); err != nil {
-			return nil, fmt.Errorf("failed to scan segment ID: %w", err)
-		}
-		segmentIDs = append(segmentIDs, id)
-	}
-
-	if len(segmentIDs) != len(slugs) {
-		return nil, fmt.Errorf("some slugs do not exist in segments table")
-	}
-
-	return segmentIDs, nil
-}
-
-func (r *SegmentRepositoryDB) GetOneSegmentID(slug models.Slug) (int64, error) {
-	const query = `SELECT id FROM segments WHERE slug = $1;`
-
-	var slugID int64
-
-	err := r.DB.QueryRow(query, slug).Scan(&slugID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return 0, fmt.Errorf("segment with slug '%s' not found", slug)
-		}
-		return 0, fmt.Errorf("failed to get segment ID: %w", err)
-	}
-
-	return slugID, nil
-}
+package repository
+
+import (
+	"API/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//go:generate mockery --name=SegmentRepository --output=mocks --outpkg=mocks
+type SegmentRepository interface {
+	CreateSegmentDB(ctx context.Context, segment models.Segments) (int64, error)
+	DeleteSegmentDB(ctx context.Context, slug models.Slug) error
+	ArchiveSegmentDB(ctx context.Context, slug models.Slug) error
+	RestoreSegmentDB(ctx context.Context, slug models.Slug) error
+	PurgeArchivedSegments(ctx context.Context, before time.Time, limit int) ([]models.Slug, error)
+	PurgeSegmentDB(ctx context.Context, slug models.Slug) (int64, error)
+	ExpireSegments(ctx context.Context, limit int) ([]models.Slug, error)
+	SelectAllSegmentsDB(ctx context.Context, filter models.SegmentFilter) ([]models.Segments, error)
+	GetSegmentDB(ctx context.Context, slug models.Slug) (models.Segments, error)
+	UpdateSegmentDB(ctx context.Context, segment models.Segments) (models.Segments, error)
+	LookupSegments(ctx context.Context, slugs []models.Slug) ([]models.SegmentRef, error)
+	GetOneSegmentID(ctx context.Context, slug models.Slug) (int64, error)
+	GetSegmentACLs(ctx context.Context, slugs []models.Slug) ([]models.SegmentACL, error)
+	SetSegmentACL(ctx context.Context, acl models.SegmentACL) error
+}
+
+type SegmentRepositoryDB struct {
+	DB     *sql.DB
+	Logger *slog.Logger
+}
+
+func NewSegmentRepository(db *sql.DB, logger *slog.Logger) *SegmentRepositoryDB {
+	return &SegmentRepositoryDB{DB: db, Logger: logger}
+}
+
+// autoPercentCondition selects the deterministic share of users (alias u)
+// that falls into the auto_percent of a segment (alias s). A user is hashed
+// together with the slug into one of 10000 buckets, so the same user always
+// lands in the same bucket for a given segment.
+const autoPercentCondition = `mod(hashtext(s.slug || ':' || u.id)::BIGINT + 2147483648, 10000) < s.auto_percent * 100`
+
+// CreateSegmentDB creates a segment and, when AutoPercent is set, enrolls the
+// matching share of existing users in the same transaction, together with
+// their history rows and outbox events. It returns the number of enrolled users.
+func (r *SegmentRepositoryDB) CreateSegmentDB(ctx context.Context, segment models.Segments) (int64, error) {
+	const op = "internal/repository/CreateSegment"
+
+	queryCheck := `SELECT id FROM segments WHERE slug = $1`
+	var id int64
+	err := r.DB.QueryRowContext(ctx, queryCheck, segment.Slug).Scan(&id)
+	if err == nil {
+		return 0, fmt.Errorf("segment already exists with id %d", id)
+	} else if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO segments (slug, auto_percent, owner, description, tags, active_from, active_until, expires_at, expiry_action)
+	VALUES ($1, $2, $3, $4, COALESCE($5, '{}'), $6, $7, $8, COALESCE(NULLIF($9, ''), 'archive'))
+	RETURNING id;`
+	err = tx.QueryRowContext(ctx, query, segment.Slug, segment.AutoPercent, segment.Owner, segment.Description,
+		pq.Array(segment.Tags), segment.ActiveFrom, segment.ActiveUntil, segment.ExpiresAt, segment.ExpiryAction).Scan(&id)
+	if err != nil {
+		r.Logger.ErrorContext(ctx, "Query failed", "op", op, "error", err)
+		return 0, err
+	}
+
+	var enrolled int64
+	if segment.AutoPercent != nil {
+		if enrolled, err = r.enrollByPercent(ctx, tx, id); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return enrolled, nil
+}
+
+func (r *SegmentRepositoryDB) enrollByPercent(ctx context.Context, tx *sql.Tx, segmentID int64) (int64, error) {
+	query := `
+	WITH enrolled AS (
+		INSERT INTO user_segments (user_id, segment_id)
+		SELECT u.id, s.id
+		FROM users u
+		JOIN segments s ON s.id = $1
+		WHERE ` + autoPercentCondition + `
+		ON CONFLICT (user_id, segment_id) DO NOTHING
+		RETURNING user_id, segment_id
+	), changed AS (
+		SELECT e.user_id, s.slug
+		FROM enrolled e
+		JOIN segments s ON s.id = e.segment_id
+	), history AS (
+		` + historyInsert("changed", models.ADD, 3) + `
+	), events AS (
+		` + outboxMembershipInsert("changed", models.ActionAdd, "$2") + `
+	)
+	SELECT COUNT(*) FROM changed;`
+
+	var enrolled int64
+	args := append([]any{segmentID, outboxHeaders(ctx, nil)}, historyArgs(ctx, models.SourceAuto, models.ReasonAutoPercent)...)
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&enrolled); err != nil {
+		return 0, fmt.Errorf("failed to enroll users into segment %d: %w", segmentID, err)
+	}
+	return enrolled, nil
+}
+
+// deleteSegment removes the memberships of a segment before the segment itself.
+var deleteSegment = cascadeDelete{
+	lock:   `SELECT id FROM segments WHERE slug = $1 FOR UPDATE;`,
+	column: "segment_id",
+	delete: `DELETE FROM segments WHERE id = $1;`,
+	reason: models.ReasonSegmentDeleted,
+}
+
+// DeleteSegmentDB deletes the segment. Its memberships are removed first in
+// batches, with history rows and outbox events for each of them.
+func (r *SegmentRepositoryDB) DeleteSegmentDB(ctx context.Context, slug models.Slug) error {
+	const op = "internal/repository/DeleteSegment"
+
+	removed, err := deleteSegment.run(ctx, r.DB, slug)
+	if err != nil {
+		r.Logger.ErrorContext(ctx, "Query failed", "op", op, "error", err, "removed", removed)
+		return err
+	}
+	r.Logger.InfoContext(ctx, "Segment deleted", "segment", slug, "removed", removed)
+	return nil
+}
+
+// ArchiveSegmentDB hides the segment and freezes its memberships until it is
+// restored or purged.
+func (r *SegmentRepositoryDB) ArchiveSegmentDB(ctx context.Context, slug models.Slug) error {
+	const query = `UPDATE segments SET archived_at = NOW() WHERE slug = $1 AND archived_at IS NULL;`
+	return r.setArchived(ctx, "internal/repository/ArchiveSegment", query, slug)
+}
+
+// RestoreSegmentDB brings back an archived segment with its memberships.
+func (r *SegmentRepositoryDB) RestoreSegmentDB(ctx context.Context, slug models.Slug) error {
+	const query = `UPDATE segments SET archived_at = NULL WHERE slug = $1 AND archived_at IS NOT NULL;`
+	return r.setArchived(ctx, "internal/repository/RestoreSegment", query, slug)
+}
+
+// setArchived runs query for slug and returns ErrSegmentNotFound when no
+// segment was in the state the query expects.
+func (r *SegmentRepositoryDB) setArchived(ctx context.Context, op, query string, slug models.Slug) error {
+	result, err := r.DB.ExecContext(ctx, query, slug)
+	if err != nil {
+		r.Logger.ErrorContext(ctx, "Query failed", "op", op, "error", err)
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", models.ErrSegmentNotFound, slug)
+	}
+	return nil
+}
+
+// purgeSegment deletes an archived segment like deleteSegment, and stops if
+// the segment is restored in between.
+var purgeSegment = cascadeDelete{
+	lock:   `SELECT id FROM segments WHERE slug = $1 AND archived_at IS NOT NULL FOR UPDATE;`,
+	column: "segment_id",
+	delete: `DELETE FROM segments WHERE id = $1;`,
+	reason: models.ReasonSegmentDeleted,
+}
+
+// PurgeArchivedSegments deletes up to limit segments archived before the
+// given time, with their memberships, and returns their slugs.
+func (r *SegmentRepositoryDB) PurgeArchivedSegments(ctx context.Context, before time.Time, limit int) ([]models.Slug, error) {
+	const query = `
+	SELECT slug FROM segments
+	WHERE archived_at IS NOT NULL
+	AND archived_at <= $1
+	ORDER BY archived_at
+	LIMIT $2;`
+
+	slugs, err := r.selectSlugs(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select archived segments: %w", err)
+	}
+
+	purged := make([]models.Slug, 0, len(slugs))
+	for _, slug := range slugs {
+		removed, err := purgeSegment.run(ctx, r.DB, slug)
+		if err != nil {
+			return purged, err
+		}
+		r.Logger.InfoContext(ctx, "Archived segment purged", "segment", slug, "removed", removed)
+		purged = append(purged, slug)
+	}
+	return purged, nil
+}
+
+// PurgeSegmentDB deletes the archived segment with its memberships before
+// the retention ends and returns the number of removed memberships. A
+// segment that is not archived, or was restored meanwhile, is left alone.
+func (r *SegmentRepositoryDB) PurgeSegmentDB(ctx context.Context, slug models.Slug) (int64, error) {
+	removed, err := purgeSegment.run(ctx, r.DB, slug)
+	if err != nil {
+		return removed, fmt.Errorf("failed to purge segment %s: %w", slug, err)
+	}
+	r.Logger.InfoContext(ctx, "Archived segment purged", "segment", slug, "removed", removed)
+	return removed, nil
+}
+
+// expiredSegmentLock selects a segment whose expiry has passed, so an expiry
+// postponed between two batches stops the removal.
+const expiredSegmentLock = `SELECT id FROM segments WHERE slug = $1 AND archived_at IS NULL AND expires_at <= NOW() FOR UPDATE;`
+
+// expireSegment holds the removal of an expired segment for each expiry action.
+var expireSegment = map[models.SegmentExpiryAction]cascadeDelete{
+	models.SegmentExpiryArchive: {
+		lock:   expiredSegmentLock,
+		column: "segment_id",
+		delete: `UPDATE segments SET archived_at = NOW(), expires_at = NULL WHERE id = $1;`,
+		source: models.SourceTTL,
+		reason: models.ReasonSegmentExpired,
+	},
+	models.SegmentExpiryDelete: {
+		lock:   expiredSegmentLock,
+		column: "segment_id",
+		delete: `DELETE FROM segments WHERE id = $1;`,
+		source: models.SourceTTL,
+		reason: models.ReasonSegmentExpired,
+	},
+}
+
+// ExpireSegments removes the memberships of up to limit segments whose
+// expiry has passed, with history rows and outbox events for each of them,
+// then archives or deletes every segment according to its expiry action. It
+// returns the slugs of the expired segments.
+func (r *SegmentRepositoryDB) ExpireSegments(ctx context.Context, limit int) ([]models.Slug, error) {
+	const query = `
+	SELECT slug, expiry_action FROM segments
+	WHERE archived_at IS NULL
+	AND expires_at <= NOW()
+	ORDER BY expires_at
+	LIMIT $1;`
+
+	rows, err := r.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select expired segments: %w", err)
+	}
+	actions := make(map[models.Slug]models.SegmentExpiryAction)
+	var slugs []models.Slug
+	for rows.Next() {
+		var (
+			slug   models.Slug
+			action models.SegmentExpiryAction
+		)
+		if err := rows.Scan(&slug, &action); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan expired segment: %w", err)
+		}
+		actions[slug] = action
+		slugs = append(slugs, slug)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	expired := make([]models.Slug, 0, len(slugs))
+	for _, slug := range slugs {
+		removed, err := expireSegment[actions[slug]].run(ctx, r.DB, slug)
+		if err != nil {
+			return expired, err
+		}
+		r.Logger.InfoContext(ctx, "Segment expired", "segment", slug, "action", actions[slug], "removed", removed)
+		expired = append(expired, slug)
+	}
+	return expired, nil
+}
+
+func (r *SegmentRepositoryDB) selectSlugs(ctx context.Context, query string, args ...any) ([]models.Slug, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slugs []models.Slug
+	for rows.Next() {
+		var slug models.Slug
+		if err := rows.Scan(&slug); err != nil {
+			return nil, err
+		}
+		slugs = append(slugs, slug)
+	}
+	return slugs, rows.Err()
+}
+
+// segmentColumns are the columns read by scanSegment.
+const segmentColumns = `id, slug, auto_percent, owner, description, tags, created_at, updated_at, active_from, active_until, expires_at, expiry_action`
+
+// activeCondition holds for segments inside their active period.
+const activeCondition = `(active_from IS NULL OR active_from <= NOW()) AND (active_until IS NULL OR active_until > NOW())`
+
+func scanSegment(row rowScanner) (models.Segments, error) {
+	var segment models.Segments
+	err := row.Scan(&segment.ID, &segment.Slug, &segment.AutoPercent, &segment.Owner, &segment.Description,
+		pq.Array(&segment.Tags), &segment.CreatedAt, &segment.UpdatedAt, &segment.ActiveFrom, &segment.ActiveUntil,
+		&segment.ExpiresAt, &segment.ExpiryAction)
+	return segment, err
+}
+
+// SelectAllSegmentsDB returns the segments that are not archived and match
+// filter, ordered by slug.
+func (r *SegmentRepositoryDB) SelectAllSegmentsDB(ctx context.Context, filter models.SegmentFilter) ([]models.Segments, error) {
+	const op = "internal/repository/SelectAllSegments"
+	query := `
+	SELECT ` + segmentColumns + `
+	FROM segments
+	WHERE archived_at IS NULL
+	AND ($1 = '' OR tags @> ARRAY[$1]::TEXT[])
+	AND ($2 = '' OR owner = $2)
+	AND ($3::BOOLEAN IS NULL OR (` + activeCondition + `) = $3)
+	ORDER BY slug;`
+
+	rows, err := r.DB.QueryContext(ctx, query, filter.Tag, filter.Owner, filter.Active)
+	if err != nil {
+		r.Logger.ErrorContext(ctx, "Query failed", "op", op, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var segments []models.Segments
+	for rows.Next() {
+		segment, err := scanSegment(rows)
+		if err != nil {
+			r.Logger.ErrorContext(ctx, "Query failed", "op", op, "error", err)
+			return nil, err
+		}
+		segments = append(segments, segment)
+	}
+	if err := rows.Err(); err != nil {
+		r.Logger.ErrorContext(ctx, "Query failed", "op", op, "error", err)
+		return nil, err
+	}
+	return segments, nil
+}
+
+// GetSegmentDB returns the segment unless it is archived.
+func (r *SegmentRepositoryDB) GetSegmentDB(ctx context.Context, slug models.Slug) (models.Segments, error) {
+	query := `SELECT ` + segmentColumns + ` FROM segments WHERE slug = $1 AND archived_at IS NULL;`
+
+	segment, err := scanSegment(r.DB.QueryRowContext(ctx, query, slug))
+	if err == sql.ErrNoRows {
+		return models.Segments{}, fmt.Errorf("%w: %s", models.ErrSegmentNotFound, slug)
+	}
+	if err != nil {
+		return models.Segments{}, fmt.Errorf("failed to get segment %s: %w", slug, err)
+	}
+	return segment, nil
+}
+
+// UpdateSegmentDB stores the owner, description, tags, active period and
+// expiry of the segment and returns it as stored.
+func (r *SegmentRepositoryDB) UpdateSegmentDB(ctx context.Context, segment models.Segments) (models.Segments, error) {
+	query := `
+	UPDATE segments
+	SET owner = $2, description = $3, tags = COALESCE($4, '{}'), active_from = $5, active_until = $6,
+		expires_at = $7, expiry_action = COALESCE(NULLIF($8, ''), 'archive'), updated_at = NOW()
+	WHERE slug = $1 AND archived_at IS NULL
+	RETURNING ` + segmentColumns + `;`
+
+	updated, err := scanSegment(r.DB.QueryRowContext(ctx, query, segment.Slug, segment.Owner, segment.Description,
+		pq.Array(segment.Tags), segment.ActiveFrom, segment.ActiveUntil, segment.ExpiresAt, segment.ExpiryAction))
+	if err == sql.ErrNoRows {
+		return models.Segments{}, fmt.Errorf("%w: %s", models.ErrSegmentNotFound, segment.Slug)
+	}
+	if err != nil {
+		return models.Segments{}, fmt.Errorf("failed to update segment %s: %w", segment.Slug, err)
+	}
+	return updated, nil
+}
+
+// LookupSegments returns the existing segments among slugs, archived ones
+// included. Unknown slugs are left out.
+func (r *SegmentRepositoryDB) LookupSegments(ctx context.Context, slugs []models.Slug) ([]models.SegmentRef, error) {
+	const query = `SELECT id, slug, archived_at IS NOT NULL FROM segments WHERE slug = ANY($1);`
+
+	rows, err := r.DB.QueryContext(ctx, query, pq.Array(slugs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up segments: %w", err)
+	}
+	defer rows.Close()
+
+	refs := make([]models.SegmentRef, 0, len(slugs))
+	for rows.Next() {
+		var ref models.SegmentRef
+		if err := rows.Scan(&ref.ID, &ref.Slug, &ref.Archived); err != nil {
+			return nil, fmt.Errorf("failed to scan segment: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// GetOneSegmentID returns the ID of the segment. An archived segment is not
+// found, so its memberships are neither listed nor changed.
+func (r *SegmentRepositoryDB) GetOneSegmentID(ctx context.Context, slug models.Slug) (int64, error) {
+	const query = `SELECT id FROM segments WHERE slug = $1 AND archived_at IS NULL;`
+
+	var slugID int64
+
+	err := r.DB.QueryRowContext(ctx, query, slug).Scan(&slugID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("%w: %s", models.ErrSegmentNotFound, slug)
+		}
+		return 0, fmt.Errorf("failed to get segment ID: %w", err)
+	}
+
+	return slugID, nil
+}
+
+// GetSegmentACLs returns the ACLs of the existing segments among slugs.
+func (r *SegmentRepositoryDB) GetSegmentACLs(ctx context.Context, slugs []models.Slug) ([]models.SegmentACL, error) {
+	const query = `
+	SELECT s.slug, s.owner, COALESCE(array_agg(w.principal ORDER BY w.principal) FILTER (WHERE w.principal IS NOT NULL), '{}')
+	FROM segments s
+	LEFT JOIN segment_writers w ON w.segment_id = s.id
+	WHERE s.slug = ANY($1)
+	GROUP BY s.id, s.slug, s.owner
+	ORDER BY s.slug;`
+
+	rows, err := r.DB.QueryContext(ctx, query, pq.Array(slugs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get segment ACLs: %w", err)
+	}
+	defer rows.Close()
+
+	acls := make([]models.SegmentACL, 0, len(slugs))
+	for rows.Next() {
+		var acl models.SegmentACL
+		if err := rows.Scan(&acl.Slug, &acl.Owner, pq.Array(&acl.Writers)); err != nil {
+			return nil, fmt.Errorf("failed to scan segment ACL: %w", err)
+		}
+		acls = append(acls, acl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return acls, nil
+}
+
+// SetSegmentACL replaces the owner and writers of the segment.
+func (r *SegmentRepositoryDB) SetSegmentACL(ctx context.Context, acl models.SegmentACL) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var segmentID int64
+	err = tx.QueryRowContext(ctx, `UPDATE segments SET owner = $2 WHERE slug = $1 RETURNING id;`, acl.Slug, acl.Owner).
+		Scan(&segmentID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %s", models.ErrSegmentNotFound, acl.Slug)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set owner of segment %s: %w", acl.Slug, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM segment_writers WHERE segment_id = $1;`, segmentID); err != nil {
+		return fmt.Errorf("failed to clear writers of segment %s: %w", acl.Slug, err)
+	}
+	const insertWriters = `
+	INSERT INTO segment_writers (segment_id, principal)
+	SELECT DISTINCT $1::BIGINT, principal
+	FROM UNNEST($2::TEXT[]) AS w(principal);`
+	if _, err := tx.ExecContext(ctx, insertWriters, segmentID, pq.Array(acl.Writers)); err != nil {
+		return fmt.Errorf("failed to set writers of segment %s: %w", acl.Slug, err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"API/internal/logging"
+	"API/internal/models"
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSegmentACLs(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewSegmentRepository(mockDB, logging.Nop())
+
+	mock.ExpectQuery(regexp.QuoteMeta("LEFT JOIN segment_writers w ON w.segment_id = s.id")).
+		WithArgs(`{"DISCOUNT_30","VIDEO"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "owner", "writers"}).
+			AddRow("DISCOUNT_30", "growth", "{billing,crm}").
+			AddRow("VIDEO", nil, "{}"))
+
+	acls, err := repo.GetSegmentACLs(context.Background(), []models.Slug{"DISCOUNT_30", "VIDEO"})
+
+	owner := "growth"
+	assert.NoError(t, err)
+	assert.Equal(t, []models.SegmentACL{
+		{Slug: "DISCOUNT_30", Owner: &owner, Writers: []string{"billing", "crm"}},
+		{Slug: "VIDEO", Writers: []string{}},
+	}, acls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetSegmentACL(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewSegmentRepository(mockDB, logging.Nop())
+	owner := "growth"
+
+	t.Run("should replace owner and writers", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("UPDATE segments SET owner = $2 WHERE slug = $1")).
+			WithArgs("DISCOUNT_30", owner).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM segment_writers WHERE segment_id = $1")).
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO segment_writers (segment_id, principal)")).
+			WithArgs(3, `{"billing"}`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.SetSegmentACL(context.Background(), models.SegmentACL{
+			Slug:    "DISCOUNT_30",
+			Owner:   &owner,
+			Writers: []string{"billing"},
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should return ErrSegmentNotFound for unknown slug", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("UPDATE segments SET owner = $2 WHERE slug = $1")).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		err := repo.SetSegmentACL(context.Background(), models.SegmentACL{Slug: "UNKNOWN"})
+
+		assert.ErrorIs(t, err, models.ErrSegmentNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteSegmentDB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewSegmentRepository(mockDB, logging.Nop())
+	lock := regexp.QuoteMeta("SELECT id FROM segments WHERE slug = $1 FOR UPDATE")
+	remove := regexp.QuoteMeta("DELETE FROM user_segments us")
+
+	t.Run("should remove memberships in batches before the segment", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lock).WithArgs("VIDEO").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery(remove).
+			WithArgs(3, cascadeBatchSize, sqlmock.AnyArg(), models.ReasonSegmentDeleted, "cascade", "", "").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(cascadeBatchSize))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectQuery(lock).WithArgs("VIDEO").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery(remove).
+			WithArgs(3, cascadeBatchSize, sqlmock.AnyArg(), models.ReasonSegmentDeleted, "cascade", "", "").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM segments WHERE id = $1")).
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.DeleteSegmentDB(context.Background(), "VIDEO")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should ignore unknown segment", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(lock).WithArgs("UNKNOWN").WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		err := repo.DeleteSegmentDB(context.Background(), "UNKNOWN")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestArchiveSegmentDB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewSegmentRepository(mockDB, logging.Nop())
+	query := regexp.QuoteMeta("UPDATE segments SET archived_at = NOW() WHERE slug = $1 AND archived_at IS NULL")
+
+	t.Run("should archive active segment", func(t *testing.T) {
+		mock.ExpectExec(query).WithArgs("VIDEO").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.ArchiveSegmentDB(context.Background(), "VIDEO")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should return ErrSegmentNotFound for unknown or archived segment", func(t *testing.T) {
+		mock.ExpectExec(query).WithArgs("VIDEO").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.ArchiveSegmentDB(context.Background(), "VIDEO")
+
+		assert.ErrorIs(t, err, models.ErrSegmentNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSelectAllSegmentsDB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewSegmentRepository(mockDB, logging.Nop())
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	active := true
+
+	mock.ExpectQuery(regexp.QuoteMeta("AND ($1 = '' OR tags @> ARRAY[$1]::TEXT[])")).
+		WithArgs("promo", "", true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "slug", "auto_percent", "owner", "description", "tags", "created_at", "updated_at", "active_from", "active_until", "expires_at", "expiry_action"}).
+			AddRow(1, "DISCOUNT_30", nil, "growth", "30% discount", "{promo,q1}", created, created, nil, nil, created, "delete"))
+
+	segments, err := repo.SelectAllSegmentsDB(context.Background(), models.SegmentFilter{Tag: "promo", Active: &active})
+
+	owner, description := "growth", "30% discount"
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Segments{{
+		ID:           1,
+		Slug:         "DISCOUNT_30",
+		Owner:        &owner,
+		Description:  &description,
+		Tags:         []string{"promo", "q1"},
+		CreatedAt:    created,
+		UpdatedAt:    created,
+		ExpiresAt:    &created,
+		ExpiryAction: models.SegmentExpiryDelete,
+	}}, segments)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExpireSegments(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewSegmentRepository(mockDB, logging.Nop())
+	lock := regexp.QuoteMeta("SELECT id FROM segments WHERE slug = $1 AND archived_at IS NULL AND expires_at <= NOW() FOR UPDATE")
+	remove := regexp.QuoteMeta("DELETE FROM user_segments us")
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT slug, expiry_action FROM segments")).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "expiry_action"}).
+			AddRow("BLACK_FRIDAY", "archive").
+			AddRow("CYBER_MONDAY", "delete"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs("BLACK_FRIDAY").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectQuery(remove).
+		WithArgs(4, cascadeBatchSize, sqlmock.AnyArg(), models.ReasonSegmentExpired, "ttl", "", "").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE segments SET archived_at = NOW(), expires_at = NULL WHERE id = $1")).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs("CYBER_MONDAY").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery(remove).
+		WithArgs(5, cascadeBatchSize, sqlmock.AnyArg(), models.ReasonSegmentExpired, "ttl", "", "").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM segments WHERE id = $1")).
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	expired, err := repo.ExpireSegments(context.Background(), 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Slug{"BLACK_FRIDAY", "CYBER_MONDAY"}, expired)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
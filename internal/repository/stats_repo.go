@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"API/internal/models"
+	"context"
+	"database/sql"
+)
+
+type StatsRepository interface {
+	GetStats(ctx context.Context) (models.Stats, error)
+}
+
+type StatsRepositoryDB struct {
+	DB *sql.DB
+}
+
+func NewStatsRepository(db *sql.DB) *StatsRepositoryDB {
+	return &StatsRepositoryDB{DB: db}
+}
+
+func (r *StatsRepositoryDB) GetStats(ctx context.Context) (models.Stats, error) {
+	stats := models.Stats{Memberships: make(map[models.Slug]int64)}
+
+	err := r.DB.QueryRowContext(ctx, `SELECT (SELECT COUNT(*) FROM users), (SELECT COUNT(*) FROM segments WHERE archived_at IS NULL)`).
+		Scan(&stats.Users, &stats.Segments)
+	if err != nil {
+		return models.Stats{}, err
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT s.slug, COUNT(us.user_id)
+		FROM segments s
+		LEFT JOIN user_segments us ON us.segment_id = s.id
+		WHERE s.archived_at IS NULL
+		GROUP BY s.slug`)
+	if err != nil {
+		return models.Stats{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var slug models.Slug
+		var count int64
+		if err := rows.Scan(&slug, &count); err != nil {
+			return models.Stats{}, err
+		}
+		stats.Memberships[slug] = count
+	}
+	if err := rows.Err(); err != nil {
+		return models.Stats{}, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"API/internal/models"
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStats(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewStatsRepository(mockDB)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT (SELECT COUNT(*) FROM users), (SELECT COUNT(*) FROM segments WHERE archived_at IS NULL)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"users", "segments"}).AddRow(3, 2))
+	mock.ExpectQuery(regexp.QuoteMeta("LEFT JOIN user_segments us ON us.segment_id = s.id")).
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "count"}).
+			AddRow("DISCOUNT_30", 2).
+			AddRow("VIDEO", 0))
+
+	stats, err := repo.GetStats(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.Stats{
+		Users:       3,
+		Segments:    2,
+		Memberships: map[models.Slug]int64{"DISCOUNT_30": 2, "VIDEO": 0},
+	}, stats)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,201 +1,579 @@
-package repository
-
-import (
-	"API/internal/models"
-	"database/sql"
-	"fmt"
-	"time"
-
-	"github.com/lib/pq"
-)
-
-type UserSegmentRepository interface {
-	GetUserSegmentsDВ(id int64) (models.UserSegments, error)
-	GetAllUserSegmentsDB() ([]models.UserSegment, error)
-	UpdateUserSegments(slugsToAdd []models.Slug, slugsToDelete []models.Slug, userID int64, ttl *time.Time) error
-	DeleteUserSegment(userID int64, slug models.Slug) error
-}
-
-type UserSegmentRepositoryDB struct {
-	DB                *sql.DB
-	UserRepository    UserRepository
-	SegmentRepository SegmentRepository
-	HistoryRepository UserSegmentHistoryRepository
-}
-
-func NewUserSegmentRepository(db *sql.DB, userRepo UserRepository, segmentRepo SegmentRepository, historyRepo UserSegmentHistoryRepository) *UserSegmentRepositoryDB {
-	return &UserSegmentRepositoryDB{
-		DB:                db,
-		UserRepository:    userRepo,
-		SegmentRepository: segmentRepo,
-		HistoryRepository: historyRepo,
-	}
-}
-
-func (r *UserSegmentRepositoryDB) GetUserSegmentsDВ(id int64) (models.UserSegments, error) {
-	query := `
-		SELECT s.slug 
-		FROM user_segments us
-		JOIN segments s ON us.segment_id = s.id
-		WHERE us.user_id = $1;
-	`
-	var segments models.UserSegments
-	rows, err := r.DB.Query(query, id)
-	if err != nil {
-		return segments, err
-	}
-	defer rows.Close()
-	segments.UserID = id
-
-	for rows.Next() {
-		var slug models.Slug
-		if err := rows.Scan(&slug); err != nil {
-			return segments, err
-		}
-		segments.Segments = append(segments.Segments, slug)
-	}
-	if err := rows.Err(); err != nil {
-		return segments, err
-	}
-
-	return segments, nil
-}
-
-func (r *UserSegmentRepositoryDB) GetAllUserSegmentsDB() ([]models.UserSegment, error) {
-	query := `
-	SELECT us.user_id, segments.slug
-	FROM user_segments us
-	JOIN segments ON us.segment_id = segments.id;`
-
-	rows, err := r.DB.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var uSegments []models.UserSegment
-	for rows.Next() {
-		var uSeg models.UserSegment
-		if err := rows.Scan(&uSeg.UserID, &uSeg.Segments); err != nil {
-			return nil, err
-		}
-		uSegments = append(uSegments, uSeg)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return uSegments, nil
-}
-
-func (r *UserSegmentRepositoryDB) addSegmentsToUser(tx *sql.Tx, userID int64, slugsID []int64, ttl *time.Time) error {
-	if len(slugsID) == 0 {
-		return nil
-	}
-
-	const query = `
-	INSERT INTO user_segments (user_id, segment_id, ttl)
-	SELECT $1, UNNEST($2::BIGINT[]), $3
-	ON CONFLICT (user_id, segment_id) DO UPDATE
-	SET ttl = EXCLUDED.ttl;`
-
-	if _, err := tx.Exec(query, userID, pq.Array(slugsID), ttl); err != nil {
-		return fmt.Errorf("failed to add segments to user %d: %w", userID, err)
-	}
-
-	return nil
-}
-
-func (r *UserSegmentRepositoryDB) removeSegmentsFromUser(tx *sql.Tx, userID int64, slugsID []int64) error {
-	if len(slugsID) == 0 {
-		return nil
-	}
-	const query = `
-	DELETE FROM user_segments
-	WHERE user_id = $1
-	AND segment_id = ANY($2)
-	`
-
-	if _, err := tx.Exec(query, userID, pq.Array(slugsID)); err != nil {
-		return fmt.Errorf("failed to remove segments from user %d: %w", userID, err)
-	}
-
-	return nil
-}
-
-func (r *UserSegmentRepositoryDB) UpdateUserSegments(slugsToAdd []models.Slug, slugsToDelete []models.Slug, userID int64, ttl *time.Time) error {
-
-	isexists, err := r.UserRepository.CheckUserExists(userID)
-	if err != nil {
-		return err
-	}
-	if !isexists {
-		return fmt.Errorf("user with id = %d is not exists", userID)
-	}
-
-	SAddID, _ := r.SegmentRepository.GetSegmentID(slugsToAdd)
-	SDeleteID, _ := r.SegmentRepository.GetSegmentID(slugsToDelete)
-
-	tx, err := r.DB.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	if err := r.addSegmentsToUser(tx, userID, SAddID, ttl); err != nil {
-		return fmt.Errorf("failed to get ID for segments to add: %w", err)
-	}
-
-	if err := r.removeSegmentsFromUser(tx, userID, SDeleteID); err != nil {
-		return fmt.Errorf("failed to get ID for segments to delete: %w", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		return err
-	}
-
-	for _, slug := range slugsToAdd {
-		var record models.UserSegmentsHistory
-		record.UserID = userID
-		record.SegmentSlug = slug
-		record.OperationType = models.ADD
-		record.OperationDate = time.Now()
-		r.HistoryRepository.SaveHistoryEntry(record)
-	}
-
-	for _, slug := range slugsToDelete {
-		var record models.UserSegmentsHistory
-		record.UserID = userID
-		record.SegmentSlug = slug
-		record.OperationType = models.DELETE
-		record.OperationDate = time.Now()
-		r.HistoryRepository.SaveHistoryEntry(record)
-	}
-
-	return nil
-}
-
-func (r *UserSegmentRepositoryDB) DeleteUserSegment(userID int64, slug models.Slug) error {
-	isexists, err := r.UserRepository.CheckUserExists(userID)
-	if err != nil {
-		return err
-	}
-	if !isexists {
-		return fmt.Errorf("user with id = %d is not exists", userID)
-	}
-
-	slugID, err := r.SegmentRepository.GetOneSegmentID(slug)
-	if err != nil {
-		return err
-	}
-
-	const query = `
-	DELETE FROM user_segments
-	WHERE user_id = $1 AND segment_id = $2;
-	`
-
-	if _, err = r.DB.Exec(query, userID, slugID); err != nil {
-		return fmt.Errorf("failed to delete user segment (user_id: %d, segment_id: %d): %w", userID, slugID, err)
-	}
-
-	return nil
-}
+package repository
+
+import (
+	"API/internal/models"
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//go:generate mockery --name=UserSegmentRepository --output=mocks --outpkg=mocks
+type UserSegmentRepository interface {
+	GetUserSegmentsDВ(ctx context.Context, id int64) (models.UserSegments, error)
+	GetAllUserSegmentsDB(ctx context.Context) ([]models.UserSegment, error)
+	UpdateUserSegments(ctx context.Context, slugsToAdd []models.Slug, slugsToDelete []models.Slug, userID int64, ttl *time.Time, mode models.UpdateMode) (models.UpdateSegmentsResult, error)
+	DeleteUserSegment(ctx context.Context, userID int64, slug models.Slug) error
+	CreateAutoEnrolledUser(ctx context.Context, user *models.Users) ([]models.Slug, error)
+	GetSegmentUsersDB(ctx context.Context, slug models.Slug, cursor int64, limit int) ([]models.SegmentMember, error)
+	CountSegmentUsersDB(ctx context.Context, slug models.Slug) (int64, error)
+	DeleteExpiredUserSegments(ctx context.Context, limit int) ([]models.UserSegment, error)
+}
+
+type UserSegmentRepositoryDB struct {
+	DB                *sql.DB
+	UserRepository    UserRepository
+	SegmentRepository SegmentRepository
+	HistoryRepository UserSegmentHistoryRepository
+	OutboxRepository  OutboxRepository
+}
+
+func NewUserSegmentRepository(db *sql.DB, userRepo UserRepository, segmentRepo SegmentRepository, historyRepo UserSegmentHistoryRepository, outboxRepo OutboxRepository) *UserSegmentRepositoryDB {
+	return &UserSegmentRepositoryDB{
+		DB:                db,
+		UserRepository:    userRepo,
+		SegmentRepository: segmentRepo,
+		HistoryRepository: historyRepo,
+		OutboxRepository:  outboxRepo,
+	}
+}
+
+func (r *UserSegmentRepositoryDB) GetUserSegmentsDВ(ctx context.Context, id int64) (models.UserSegments, error) {
+	query := `
+		SELECT s.slug 
+		FROM user_segments us
+		JOIN segments s ON us.segment_id = s.id
+		WHERE us.user_id = $1
+		AND s.archived_at IS NULL;
+	`
+	var segments models.UserSegments
+	rows, err := r.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return segments, err
+	}
+	defer rows.Close()
+	segments.UserID = id
+
+	for rows.Next() {
+		var slug models.Slug
+		if err := rows.Scan(&slug); err != nil {
+			return segments, err
+		}
+		segments.Segments = append(segments.Segments, slug)
+	}
+	if err := rows.Err(); err != nil {
+		return segments, err
+	}
+
+	return segments, nil
+}
+
+func (r *UserSegmentRepositoryDB) GetAllUserSegmentsDB(ctx context.Context) ([]models.UserSegment, error) {
+	query := `
+	SELECT us.user_id, segments.slug
+	FROM user_segments us
+	JOIN segments ON us.segment_id = segments.id
+	WHERE segments.archived_at IS NULL;`
+
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uSegments []models.UserSegment
+	for rows.Next() {
+		var uSeg models.UserSegment
+		if err := rows.Scan(&uSeg.UserID, &uSeg.Segments); err != nil {
+			return nil, err
+		}
+		uSegments = append(uSegments, uSeg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return uSegments, nil
+}
+
+// addSegmentsToUser upserts the memberships and returns the slugs that were
+// newly added and the slugs that already existed and only had their TTL
+// updated.
+func (r *UserSegmentRepositoryDB) addSegmentsToUser(ctx context.Context, tx *sql.Tx, userID int64, slugsID []int64, ttl *time.Time) (added, updated []models.Slug, err error) {
+	if len(slugsID) == 0 {
+		return nil, nil, nil
+	}
+
+	const query = `
+	WITH upserted AS (
+		INSERT INTO user_segments (user_id, segment_id, ttl)
+		SELECT $1, UNNEST($2::BIGINT[]), $3
+		ON CONFLICT (user_id, segment_id) DO UPDATE
+		SET ttl = EXCLUDED.ttl
+		RETURNING segment_id, (xmax = 0) AS inserted
+	)
+	SELECT s.slug, u.inserted
+	FROM upserted u
+	JOIN segments s ON s.id = u.segment_id
+	ORDER BY s.slug;`
+
+	rows, err := tx.QueryContext(ctx, query, userID, pq.Array(slugsID), ttl)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to add segments to user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			slug     models.Slug
+			inserted bool
+		)
+		if err := rows.Scan(&slug, &inserted); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan added segment: %w", err)
+		}
+		if inserted {
+			added = append(added, slug)
+		} else {
+			updated = append(updated, slug)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return added, updated, nil
+}
+
+// removeSegmentsFromUser deletes the memberships and returns the slugs the
+// user actually belonged to.
+func (r *UserSegmentRepositoryDB) removeSegmentsFromUser(ctx context.Context, tx *sql.Tx, userID int64, slugsID []int64) ([]models.Slug, error) {
+	if len(slugsID) == 0 {
+		return nil, nil
+	}
+	const query = `
+	WITH deleted AS (
+		DELETE FROM user_segments
+		WHERE user_id = $1
+		AND segment_id = ANY($2)
+		RETURNING segment_id
+	)
+	SELECT s.slug
+	FROM deleted d
+	JOIN segments s ON s.id = d.segment_id
+	ORDER BY s.slug;`
+
+	rows, err := tx.QueryContext(ctx, query, userID, pq.Array(slugsID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove segments from user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var removed []models.Slug
+	for rows.Next() {
+		var slug models.Slug
+		if err := rows.Scan(&slug); err != nil {
+			return nil, fmt.Errorf("failed to scan removed segment: %w", err)
+		}
+		removed = append(removed, slug)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return removed, nil
+}
+
+// recordChanges writes history rows and outbox events for the memberships
+// that were actually added or removed. Memberships that only got a new TTL
+// have no history row, but the new TTL is announced on the segment_expiry topic.
+func (r *UserSegmentRepositoryDB) recordChanges(ctx context.Context, tx *sql.Tx, userID int64, added, updated, removed []models.Slug, ttl *time.Time) error {
+	return recordMemberships(ctx, tx, r.HistoryRepository, r.OutboxRepository,
+		memberships(userID, added), memberships(userID, updated), memberships(userID, removed), ttl)
+}
+
+// recordMemberships is recordChanges for memberships of any number of users.
+func recordMemberships(ctx context.Context, tx *sql.Tx, history UserSegmentHistoryRepository, outbox OutboxRepository, added, updated, removed []models.UserSegment, ttl *time.Time) error {
+	now := time.Now()
+	records := make([]models.UserSegmentsHistory, 0, len(added)+len(removed))
+	var events []models.OutboxEvent
+
+	for _, membership := range added {
+		records = append(records, models.UserSegmentsHistory{
+			UserID:        membership.UserID,
+			SegmentSlug:   membership.Segments,
+			OperationType: models.ADD,
+			OperationDate: now,
+		})
+		slugEvents, err := models.NewMembershipEvents(membership.UserID, membership.Segments, models.ActionAdd, ttl)
+		if err != nil {
+			return err
+		}
+		events = append(events, slugEvents...)
+	}
+
+	if ttl != nil {
+		for _, membership := range updated {
+			slugEvents, err := models.NewMembershipEvents(membership.UserID, membership.Segments, models.ActionAdd, ttl)
+			if err != nil {
+				return err
+			}
+			events = append(events, slugEvents[0])
+		}
+	}
+
+	for _, membership := range removed {
+		records = append(records, models.UserSegmentsHistory{
+			UserID:        membership.UserID,
+			SegmentSlug:   membership.Segments,
+			OperationType: models.DELETE,
+			OperationDate: now,
+		})
+		slugEvents, err := models.NewMembershipEvents(membership.UserID, membership.Segments, models.ActionDelete, nil)
+		if err != nil {
+			return err
+		}
+		events = append(events, slugEvents...)
+	}
+
+	if err := history.SaveHistoryEntries(ctx, tx, records); err != nil {
+		return err
+	}
+	return outbox.SaveEvents(ctx, tx, events)
+}
+
+// memberships pairs userID with every slug.
+func memberships(userID int64, slugs []models.Slug) []models.UserSegment {
+	result := make([]models.UserSegment, len(slugs))
+	for i, slug := range slugs {
+		result[i] = models.UserSegment{UserID: userID, Segments: slug}
+	}
+	return result
+}
+
+// UpdateUserSegments adds and removes the user's memberships in one
+// transaction and reports the outcome of every slug. Unknown and archived
+// segments reject the whole update in strict mode and are skipped in
+// best-effort mode. History rows and outbox events are written in the same
+// transaction and only for memberships that actually changed.
+func (r *UserSegmentRepositoryDB) UpdateUserSegments(ctx context.Context, slugsToAdd []models.Slug, slugsToDelete []models.Slug, userID int64, ttl *time.Time, mode models.UpdateMode) (models.UpdateSegmentsResult, error) {
+	result := models.UpdateSegmentsResult{UserID: userID, Mode: cmp.Or(mode, models.ModeStrict)}
+
+	isexists, err := r.UserRepository.CheckUserExists(ctx, userID)
+	if err != nil {
+		return result, err
+	}
+	if !isexists {
+		return result, fmt.Errorf("%w: %d", models.ErrUserNotFound, userID)
+	}
+
+	refs, err := r.SegmentRepository.LookupSegments(ctx, append(slices.Clip(slugsToAdd), slugsToDelete...))
+	if err != nil {
+		return result, err
+	}
+	found := make(map[models.Slug]models.SegmentRef, len(refs))
+	for _, ref := range refs {
+		found[ref.Slug] = ref
+	}
+
+	// outcomes holds the failed slugs until the changes are known.
+	outcomes := make(map[models.Slug]models.MembershipOutcome)
+	resolve := func(slugs []models.Slug) []int64 {
+		var ids []int64
+		for _, slug := range slugs {
+			ref, ok := found[slug]
+			switch {
+			case !ok:
+				outcomes[slug] = models.OutcomeUnknownSegment
+			case ref.Archived:
+				outcomes[slug] = models.OutcomeArchived
+			default:
+				ids = append(ids, ref.ID)
+			}
+		}
+		return ids
+	}
+	addIDs, deleteIDs := resolve(slugsToAdd), resolve(slugsToDelete)
+
+	if len(outcomes) > 0 && result.Mode == models.ModeStrict {
+		result.Results = slugOutcomes(slugsToAdd, slugsToDelete, outcomes, false)
+		return result, fmt.Errorf("%w: %d segments are unknown or archived", models.ErrMembershipRejected, len(outcomes))
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	added, updated, err := r.addSegmentsToUser(ctx, tx, userID, uniqueIDs(addIDs), ttl)
+	if err != nil {
+		return result, err
+	}
+
+	removed, err := r.removeSegmentsFromUser(ctx, tx, userID, uniqueIDs(deleteIDs))
+	if err != nil {
+		return result, err
+	}
+
+	if err := r.recordChanges(ctx, tx, userID, added, updated, removed, ttl); err != nil {
+		return result, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+
+	for _, slug := range added {
+		outcomes[slug] = models.OutcomeAdded
+	}
+	for _, slug := range updated {
+		outcomes[slug] = models.OutcomeAlreadyMember
+	}
+	for _, slug := range removed {
+		outcomes[slug] = models.OutcomeRemoved
+	}
+	result.Applied = true
+	result.Results = slugOutcomes(slugsToAdd, slugsToDelete, outcomes, true)
+	return result, nil
+}
+
+// uniqueIDs sorts ids and drops duplicates, so a slug repeated in a request
+// is upserted once.
+func uniqueIDs(ids []int64) []int64 {
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// slugOutcomes lists the outcome of each requested slug once, in request
+// order. A rejected update lists only the failed slugs; in an applied one,
+// slugs to delete without an outcome were not members.
+func slugOutcomes(slugsToAdd, slugsToDelete []models.Slug, outcomes map[models.Slug]models.MembershipOutcome, applied bool) []models.SlugOutcome {
+	results := make([]models.SlugOutcome, 0, len(slugsToAdd)+len(slugsToDelete))
+	seen := make(map[models.Slug]bool)
+	collect := func(slugs []models.Slug, action string) {
+		for _, slug := range slugs {
+			if seen[slug] {
+				continue
+			}
+			seen[slug] = true
+			outcome, ok := outcomes[slug]
+			if !ok && !applied {
+				continue
+			}
+			if !ok && action == models.ActionDelete {
+				outcome = models.OutcomeNotMember
+			}
+			results = append(results, models.SlugOutcome{Slug: slug, Action: action, Outcome: outcome})
+		}
+	}
+	collect(slugsToAdd, models.ActionAdd)
+	collect(slugsToDelete, models.ActionDelete)
+	return results
+}
+
+func (r *UserSegmentRepositoryDB) DeleteUserSegment(ctx context.Context, userID int64, slug models.Slug) error {
+	isexists, err := r.UserRepository.CheckUserExists(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !isexists {
+		return fmt.Errorf("%w: %d", models.ErrUserNotFound, userID)
+	}
+
+	slugID, err := r.SegmentRepository.GetOneSegmentID(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	removed, err := r.removeSegmentsFromUser(ctx, tx, userID, []int64{slugID})
+	if err != nil {
+		return fmt.Errorf("failed to delete user segment (user_id: %d, segment_id: %d): %w", userID, slugID, err)
+	}
+
+	if err := r.recordChanges(ctx, tx, userID, nil, nil, removed, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateAutoEnrolledUser creates the user and enrolls it like autoEnrollUser
+// in one transaction, so a user is never created without its auto_percent
+// memberships. It returns the slugs of the segments the user joined.
+func (r *UserSegmentRepositoryDB) CreateAutoEnrolledUser(ctx context.Context, user *models.Users) ([]models.Slug, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := insertUser(ctx, tx, user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	slugs, err := autoEnrollUser(ctx, tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return slugs, nil
+}
+
+// autoEnrollUser adds the user to every segment with auto_percent whose
+// deterministic share the user falls into, writing ADD history rows and
+// outbox events for the new memberships. It returns the slugs of the segments the user joined.
+func autoEnrollUser(ctx context.Context, db DBTX, userID int64) ([]models.Slug, error) {
+	query := `
+	WITH enrolled AS (
+		INSERT INTO user_segments (user_id, segment_id)
+		SELECT u.id, s.id
+		FROM users u
+		JOIN segments s ON s.auto_percent IS NOT NULL AND s.archived_at IS NULL
+		WHERE u.id = $1
+		AND ` + autoPercentCondition + `
+		ON CONFLICT (user_id, segment_id) DO NOTHING
+		RETURNING user_id, segment_id
+	), changed AS (
+		SELECT e.user_id, s.slug
+		FROM enrolled e
+		JOIN segments s ON s.id = e.segment_id
+	), history AS (
+		` + historyInsert("changed", models.ADD, 3) + `
+	), events AS (
+		` + outboxMembershipInsert("changed", models.ActionAdd, "$2") + `
+	)
+	SELECT slug FROM changed;`
+
+	args := append([]any{userID, outboxHeaders(ctx, nil)}, historyArgs(ctx, models.SourceAuto, models.ReasonAutoPercent)...)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to auto enroll user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var slugs []models.Slug
+	for rows.Next() {
+		var slug models.Slug
+		if err := rows.Scan(&slug); err != nil {
+			return nil, fmt.Errorf("failed to scan enrolled segment: %w", err)
+		}
+		slugs = append(slugs, slug)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return slugs, nil
+}
+
+// GetSegmentUsersDB returns up to limit members of the segment with user IDs
+// greater than cursor, ordered by user ID.
+func (r *UserSegmentRepositoryDB) GetSegmentUsersDB(ctx context.Context, slug models.Slug, cursor int64, limit int) ([]models.SegmentMember, error) {
+	segmentID, err := r.SegmentRepository.GetOneSegmentID(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	const query = `
+	SELECT user_id, ttl
+	FROM user_segments
+	WHERE segment_id = $1
+	AND user_id > $2
+	ORDER BY user_id
+	LIMIT $3;`
+
+	rows, err := r.DB.QueryContext(ctx, query, segmentID, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users of segment %s: %w", slug, err)
+	}
+	defer rows.Close()
+
+	members := make([]models.SegmentMember, 0, limit)
+	for rows.Next() {
+		var member models.SegmentMember
+		if err := rows.Scan(&member.UserID, &member.TTL); err != nil {
+			return nil, fmt.Errorf("failed to scan segment member: %w", err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *UserSegmentRepositoryDB) CountSegmentUsersDB(ctx context.Context, slug models.Slug) (int64, error) {
+	segmentID, err := r.SegmentRepository.GetOneSegmentID(ctx, slug)
+	if err != nil {
+		return 0, err
+	}
+
+	const query = `SELECT COUNT(*) FROM user_segments WHERE segment_id = $1;`
+
+	var count int64
+	if err := r.DB.QueryRowContext(ctx, query, segmentID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count users of segment %s: %w", slug, err)
+	}
+	return count, nil
+}
+
+// DeleteExpiredUserSegments removes up to limit memberships whose TTL has
+// passed and writes DELETE history rows with the "expired" reason and outbox
+// events in the same statement. Rows locked by another sweeper are skipped, so several replicas
+// can sweep concurrently without deleting the same membership twice.
+func (r *UserSegmentRepositoryDB) DeleteExpiredUserSegments(ctx context.Context, limit int) ([]models.UserSegment, error) {
+	query := `
+	WITH expired AS (
+		SELECT user_id, segment_id
+		FROM user_segments
+		WHERE ttl IS NOT NULL
+		AND ttl <= NOW()
+		ORDER BY ttl
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	), deleted AS (
+		DELETE FROM user_segments us
+		USING expired e
+		WHERE us.user_id = e.user_id
+		AND us.segment_id = e.segment_id
+		RETURNING us.user_id, us.segment_id
+	), changed AS (
+		SELECT d.user_id, s.slug
+		FROM deleted d
+		JOIN segments s ON s.id = d.segment_id
+	), history AS (
+		` + historyInsert("changed", models.DELETE, 3) + `
+	), events AS (
+		` + outboxMembershipInsert("changed", models.ActionDelete, "$2") + `
+	)
+	SELECT user_id, slug FROM changed;`
+
+	args := append([]any{limit, outboxHeaders(ctx, nil)}, historyArgs(ctx, models.SourceTTL, models.ReasonExpired)...)
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete expired user segments: %w", err)
+	}
+	defer rows.Close()
+
+	var expired []models.UserSegment
+	for rows.Next() {
+		var uSeg models.UserSegment
+		if err := rows.Scan(&uSeg.UserID, &uSeg.Segments); err != nil {
+			return nil, fmt.Errorf("failed to scan expired user segment: %w", err)
+		}
+		expired = append(expired, uSeg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
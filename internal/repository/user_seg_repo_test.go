@@ -1,71 +1,339 @@
-package repository
-
-import (
-	"API/internal/models"
-	"fmt"
-	"regexp"
-	"testing"
-
-	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/stretchr/testify/assert"
-)
-
-func TestGetUserSegmentsDВ(t *testing.T) {
-	mockDB, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("Failed to create sqlmock: %v", err)
-	}
-	defer mockDB.Close()
-
-	repo := NewUserSegmentRepository(mockDB, nil, nil, nil)
-
-	t.Run("should return user segments successfully", func(t *testing.T) {
-		expectedSegments := models.UserSegments{
-			UserID: 1,
-			Segments: []models.Slug{
-				"segment1",
-				"segment2",
-			},
-		}
-
-		rows := sqlmock.NewRows([]string{"slug"}).
-			AddRow("segment1").
-			AddRow("segment2")
-
-		query := `
-			SELECT s.slug 
-			FROM user_segments us
-			JOIN segments s ON us.segment_id = s.id
-			WHERE us.user_id = $1;
-		`
-
-		mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(1).WillReturnRows(rows)
-
-		actualSegments, err := repo.GetUserSegmentsDВ(1)
-
-		assert.NoError(t, err)
-		assert.Equal(t, expectedSegments, actualSegments)
-
-		err = mock.ExpectationsWereMet()
-		assert.NoError(t, err)
-	})
-	t.Run("should return error when query fails", func(t *testing.T) {
-		query := `
-			SELECT s.slug 
-			FROM user_segments us
-			JOIN segments s ON us.segment_id = s.id
-			WHERE us.user_id = $1;
-		`
-		mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(1).WillReturnError(fmt.Errorf("database error"))
-
-		actualSegments, err := repo.GetUserSegmentsDВ(1)
-
-		assert.Error(t, err)
-		assert.EqualError(t, err, "database error")
-		assert.Empty(t, actualSegments)
-
-		err = mock.ExpectationsWereMet()
-		assert.NoError(t, err)
-	})
-
-}
+package repository
+
+import (
+	"API/internal/auth"
+	"API/internal/logging"
+	"API/internal/models"
+	"API/internal/repository/mocks"
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUserSegmentsDВ(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewUserSegmentRepository(mockDB, nil, nil, nil, nil)
+
+	t.Run("should return user segments successfully", func(t *testing.T) {
+		expectedSegments := models.UserSegments{
+			UserID: 1,
+			Segments: []models.Slug{
+				"segment1",
+				"segment2",
+			},
+		}
+
+		rows := sqlmock.NewRows([]string{"slug"}).
+			AddRow("segment1").
+			AddRow("segment2")
+
+		query := `
+			SELECT s.slug 
+			FROM user_segments us
+			JOIN segments s ON us.segment_id = s.id
+			WHERE us.user_id = $1
+			AND s.archived_at IS NULL;
+		`
+
+		mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(1).WillReturnRows(rows)
+
+		actualSegments, err := repo.GetUserSegmentsDВ(context.Background(), 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expectedSegments, actualSegments)
+
+		err = mock.ExpectationsWereMet()
+		assert.NoError(t, err)
+	})
+	t.Run("should return error when query fails", func(t *testing.T) {
+		query := `
+			SELECT s.slug 
+			FROM user_segments us
+			JOIN segments s ON us.segment_id = s.id
+			WHERE us.user_id = $1
+			AND s.archived_at IS NULL;
+		`
+		mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(1).WillReturnError(fmt.Errorf("database error"))
+
+		actualSegments, err := repo.GetUserSegmentsDВ(context.Background(), 1)
+
+		assert.Error(t, err)
+		assert.EqualError(t, err, "database error")
+		assert.Empty(t, actualSegments)
+
+		err = mock.ExpectationsWereMet()
+		assert.NoError(t, err)
+	})
+
+}
+
+func TestCreateAutoEnrolledUser(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewUserSegmentRepository(mockDB, nil, nil, nil, nil)
+	user := &models.Users{ID: 1000, Name: "Alice"}
+
+	t.Run("should create the user with its enrolled segments", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"slug"}).
+			AddRow("DISCOUNT_30").
+			AddRow("VIDEO")
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO users (id, name)")).
+			WithArgs(1000, "Alice").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO user_segments (user_id, segment_id)")).
+			WithArgs(1000, sqlmock.AnyArg(), models.ReasonAutoPercent, "auto", "billing", "").
+			WillReturnRows(rows)
+		mock.ExpectCommit()
+
+		ctx := auth.WithPrincipal(context.Background(), models.Principal{Subject: "billing"})
+		slugs, err := repo.CreateAutoEnrolledUser(ctx, user)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []models.Slug{"DISCOUNT_30", "VIDEO"}, slugs)
+
+		err = mock.ExpectationsWereMet()
+		assert.NoError(t, err)
+	})
+
+	t.Run("should not create the user when enrollment fails", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO users (id, name)")).
+			WithArgs(1000, "Alice").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO user_segments (user_id, segment_id)")).
+			WithArgs(1000, sqlmock.AnyArg(), models.ReasonAutoPercent, "auto", "", "").
+			WillReturnError(fmt.Errorf("database error"))
+		mock.ExpectRollback()
+
+		slugs, err := repo.CreateAutoEnrolledUser(context.Background(), user)
+
+		assert.Error(t, err)
+		assert.Nil(t, slugs)
+
+		err = mock.ExpectationsWereMet()
+		assert.NoError(t, err)
+	})
+}
+
+func TestGetSegmentUsersDB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewUserSegmentRepository(mockDB, nil, NewSegmentRepository(mockDB, logging.Nop()), nil, nil)
+
+	t.Run("should return page of segment members", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM segments WHERE slug = $1 AND archived_at IS NULL;`)).
+			WithArgs("DISCOUNT_30").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+		mock.ExpectQuery(regexp.QuoteMeta("FROM user_segments")).
+			WithArgs(2, 1000, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "ttl"}).
+				AddRow(1002, nil).
+				AddRow(1004, nil))
+
+		members, err := repo.GetSegmentUsersDB(context.Background(), "DISCOUNT_30", 1000, 2)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []models.SegmentMember{{UserID: 1002}, {UserID: 1004}}, members)
+
+		err = mock.ExpectationsWereMet()
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return not found for unknown segment", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM segments WHERE slug = $1 AND archived_at IS NULL;`)).
+			WithArgs("UNKNOWN").
+			WillReturnError(sql.ErrNoRows)
+
+		members, err := repo.GetSegmentUsersDB(context.Background(), "UNKNOWN", 0, 10)
+
+		assert.ErrorIs(t, err, models.ErrSegmentNotFound)
+		assert.Nil(t, members)
+
+		err = mock.ExpectationsWereMet()
+		assert.NoError(t, err)
+	})
+
+	t.Run("should not list the members of an archived segment", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM segments WHERE slug = $1 AND archived_at IS NULL;`)).
+			WithArgs("ARCHIVED").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		members, err := repo.GetSegmentUsersDB(context.Background(), "ARCHIVED", 0, 10)
+
+		assert.ErrorIs(t, err, models.ErrSegmentNotFound)
+		assert.Nil(t, members)
+
+		err = mock.ExpectationsWereMet()
+		assert.NoError(t, err)
+	})
+}
+
+func TestCountSegmentUsersDB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewUserSegmentRepository(mockDB, nil, NewSegmentRepository(mockDB, logging.Nop()), nil, nil)
+
+	t.Run("should count the members of the segment", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM segments WHERE slug = $1 AND archived_at IS NULL;`)).
+			WithArgs("DISCOUNT_30").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM user_segments WHERE segment_id = $1;`)).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+
+		count, err := repo.CountSegmentUsersDB(context.Background(), "DISCOUNT_30")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), count)
+
+		err = mock.ExpectationsWereMet()
+		assert.NoError(t, err)
+	})
+
+	t.Run("should not count the members of an archived segment", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM segments WHERE slug = $1 AND archived_at IS NULL;`)).
+			WithArgs("ARCHIVED").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		_, err := repo.CountSegmentUsersDB(context.Background(), "ARCHIVED")
+
+		assert.ErrorIs(t, err, models.ErrSegmentNotFound)
+
+		err = mock.ExpectationsWereMet()
+		assert.NoError(t, err)
+	})
+}
+
+func TestDeleteUserSegment(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	userRepo := new(mocks.UserRepository)
+	repo := NewUserSegmentRepository(mockDB, userRepo, NewSegmentRepository(mockDB, logging.Nop()), nil, nil)
+
+	t.Run("should not change the memberships of an archived segment", func(t *testing.T) {
+		userRepo.On("CheckUserExists", context.Background(), int64(1000)).Return(true, nil).Once()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM segments WHERE slug = $1 AND archived_at IS NULL;`)).
+			WithArgs("ARCHIVED").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		err := repo.DeleteUserSegment(context.Background(), 1000, "ARCHIVED")
+
+		assert.ErrorIs(t, err, models.ErrSegmentNotFound)
+
+		err = mock.ExpectationsWereMet()
+		assert.NoError(t, err)
+	})
+}
+
+func TestUpdateUserSegments(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	userRepo := new(mocks.UserRepository)
+	repo := NewUserSegmentRepository(
+		mockDB,
+		userRepo,
+		NewSegmentRepository(mockDB, logging.Nop()),
+		NewUserSegmentHistoryRepository(mockDB),
+		NewOutboxRepository(mockDB),
+	)
+
+	t.Run("should record history only for changed memberships", func(t *testing.T) {
+		ctx := logging.WithRequestID(context.Background(), "req-1")
+		ctx = auth.WithPrincipal(ctx, models.Principal{Subject: "billing"})
+		userRepo.On("CheckUserExists", ctx, int64(1000)).Return(true, nil).Once()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, slug, archived_at IS NOT NULL FROM segments WHERE slug = ANY($1);`)).
+			WithArgs(`{"DISCOUNT_30","VIDEO","SUPPORT","OLD"}`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "slug", "archived"}).
+				AddRow(1, "DISCOUNT_30", false).
+				AddRow(2, "VIDEO", false).
+				AddRow(3, "SUPPORT", false))
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO user_segments (user_id, segment_id, ttl)")).
+			WillReturnRows(sqlmock.NewRows([]string{"slug", "inserted"}).
+				AddRow("DISCOUNT_30", true).
+				AddRow("VIDEO", false))
+		mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM user_segments")).
+			WillReturnRows(sqlmock.NewRows([]string{"slug"}))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO user_segments_history")).
+			WithArgs("{1000}", `{"DISCOUNT_30"}`, `{"ADD"}`, sqlmock.AnyArg(), `{""}`, `{"api"}`, `{"billing"}`, `{"req-1"}`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+			WithArgs(`{"user-segments"}`, `{"1000"}`, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		result, err := repo.UpdateUserSegments(
+			ctx,
+			[]models.Slug{"DISCOUNT_30", "VIDEO"},
+			[]models.Slug{"SUPPORT", "OLD"},
+			1000,
+			nil,
+			models.ModeBestEffort,
+		)
+
+		assert.NoError(t, err)
+		assert.True(t, result.Applied)
+		assert.Equal(t, []models.SlugOutcome{
+			{Slug: "DISCOUNT_30", Action: models.ActionAdd, Outcome: models.OutcomeAdded},
+			{Slug: "VIDEO", Action: models.ActionAdd, Outcome: models.OutcomeAlreadyMember},
+			{Slug: "SUPPORT", Action: models.ActionDelete, Outcome: models.OutcomeNotMember},
+			{Slug: "OLD", Action: models.ActionDelete, Outcome: models.OutcomeUnknownSegment},
+		}, result.Results)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		userRepo.AssertExpectations(t)
+	})
+
+	t.Run("should reject the whole update in strict mode", func(t *testing.T) {
+		userRepo.On("CheckUserExists", context.Background(), int64(1000)).Return(true, nil).Once()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, slug, archived_at IS NOT NULL FROM segments WHERE slug = ANY($1);`)).
+			WithArgs(`{"VIDEO","DISCOUNT_30"}`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "slug", "archived"}).
+				AddRow(1, "VIDEO", true).
+				AddRow(2, "DISCOUNT_30", false))
+
+		result, err := repo.UpdateUserSegments(context.Background(), []models.Slug{"VIDEO", "DISCOUNT_30"}, nil, 1000, nil, models.ModeStrict)
+
+		assert.ErrorIs(t, err, models.ErrMembershipRejected)
+		assert.False(t, result.Applied)
+		assert.Equal(t, []models.SlugOutcome{
+			{Slug: "VIDEO", Action: models.ActionAdd, Outcome: models.OutcomeArchived},
+		}, result.Results)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
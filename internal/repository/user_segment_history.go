@@ -1,74 +1,135 @@
-package repository
-
-import (
-	"API/internal/models"
-	"database/sql"
-	"fmt"
-	"time"
-)
-
-type UserSegmentHistoryRepositoryDB struct {
-	DB *sql.DB
-}
-
-func NewUserSegmentHistoryRepository(db *sql.DB) *UserSegmentHistoryRepositoryDB {
-	return &UserSegmentHistoryRepositoryDB{DB: db}
-}
-
-type UserSegmentHistoryRepository interface {
-	SaveHistoryEntry(record models.UserSegmentsHistory) error
-	GetUserHistory(userID int64, start, end time.Time) ([]models.UserSegmentsHistory, error)
-}
-
-func (r *UserSegmentHistoryRepositoryDB) SaveHistoryEntry(record models.UserSegmentsHistory) error {
-	query := `
-		INSERT INTO user_segments_history (user_id, segment_slug, operation_type, operation_date)
-		VALUES ($1, $2, $3, $4)
-	`
-
-	_, err := r.DB.Exec(query, record.UserID, record.SegmentSlug, record.OperationType, record.OperationDate)
-	if err != nil {
-		return fmt.Errorf("failed to save history entry: %w", err)
-	}
-
-	return nil
-}
-
-func (r *UserSegmentHistoryRepositoryDB) GetUserHistory(userID int64, start, end time.Time) ([]models.UserSegmentsHistory, error) {
-
-	query := `
-	SELECT id, user_id, segment_slug, operation_type, operation_date
-	FROM user_segments_history
-	WHERE user_id = $1
-	AND operation_date BETWEEN $2 AND $3;
-	`
-
-	rows, err := r.DB.Query(query, userID, start, end)
-	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
-	}
-	defer rows.Close()
-
-	histories := make([]models.UserSegmentsHistory, 0)
-
-	for rows.Next() {
-		var history models.UserSegmentsHistory
-		if err := rows.Scan(
-			&history.ID,
-			&history.UserID,
-			&history.SegmentSlug,
-			&history.OperationType,
-			&history.OperationDate,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
-		}
-
-		histories = append(histories, history)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %w", err)
-	}
-
-	return histories, nil
-}
+package repository
+
+import (
+	"API/internal/audit"
+	"API/internal/logging"
+	"API/internal/models"
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type UserSegmentHistoryRepositoryDB struct {
+	DB *sql.DB
+}
+
+func NewUserSegmentHistoryRepository(db *sql.DB) *UserSegmentHistoryRepositoryDB {
+	return &UserSegmentHistoryRepositoryDB{DB: db}
+}
+
+type UserSegmentHistoryRepository interface {
+	SaveHistoryEntries(ctx context.Context, q DBTX, records []models.UserSegmentsHistory) error
+	EachUserHistory(ctx context.Context, userID int64, start, end time.Time, fn func(models.UserSegmentsHistory) error) error
+}
+
+// SaveHistoryEntries writes the records using q, which should be the
+// transaction that made the membership changes so history cannot diverge
+// from user_segments. Records without a source, reason, actor or request ID
+// get the ones of ctx.
+func (r *UserSegmentHistoryRepositoryDB) SaveHistoryEntries(ctx context.Context, q DBTX, records []models.UserSegmentsHistory) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	userIDs := make([]int64, len(records))
+	slugs := make([]string, len(records))
+	operations := make([]string, len(records))
+	dates := make([]time.Time, len(records))
+	reasons := make([]string, len(records))
+	sources := make([]string, len(records))
+	actors := make([]string, len(records))
+	requestIDs := make([]string, len(records))
+	for i, record := range records {
+		userIDs[i] = record.UserID
+		slugs[i] = string(record.SegmentSlug)
+		operations[i] = string(record.OperationType)
+		dates[i] = record.OperationDate
+		reasons[i] = cmp.Or(record.Reason, audit.Reason(ctx))
+		sources[i] = string(cmp.Or(record.Source, audit.Source(ctx)))
+		actors[i] = cmp.Or(record.Actor, audit.Actor(ctx))
+		requestIDs[i] = cmp.Or(record.RequestID, logging.RequestID(ctx))
+	}
+
+	query := `
+		INSERT INTO user_segments_history (user_id, segment_slug, operation_type, operation_date, reason, source, actor, request_id)
+		SELECT user_id, segment_slug, operation_type, operation_date, NULLIF(reason, ''), source, NULLIF(actor, ''), NULLIF(request_id, '')
+		FROM UNNEST($1::BIGINT[], $2::TEXT[], $3::TEXT[], $4::TIMESTAMP[], $5::TEXT[], $6::TEXT[], $7::TEXT[], $8::TEXT[])
+			AS h(user_id, segment_slug, operation_type, operation_date, reason, source, actor, request_id)
+	`
+
+	_, err := q.ExecContext(ctx, query, pq.Array(userIDs), pq.Array(slugs), pq.Array(operations), pq.Array(dates),
+		pq.Array(reasons), pq.Array(sources), pq.Array(actors), pq.Array(requestIDs))
+	if err != nil {
+		return fmt.Errorf("failed to save history entries: %w", err)
+	}
+
+	return nil
+}
+
+// historyInsert writes a history row for every row of the relation named in
+// from, which must expose user_id and slug columns. It is used as a CTE by
+// statements that change many memberships at once; param is the number of
+// the first of the four placeholders filled by historyArgs.
+func historyInsert(from string, operation models.OperationType, param int) string {
+	return fmt.Sprintf(`INSERT INTO user_segments_history
+			(user_id, segment_slug, operation_type, operation_date, reason, source, actor, request_id)
+		SELECT user_id, slug, '%s', NOW(), NULLIF($%d, ''), $%d, NULLIF($%d, ''), NULLIF($%d, '')
+		FROM %s`, operation, param, param+1, param+2, param+3, from)
+}
+
+// historyArgs returns the arguments of historyInsert: the reason and source
+// of the change and the actor and request ID of ctx.
+func historyArgs(ctx context.Context, source models.HistorySource, reason string) []any {
+	return []any{reason, string(source), audit.Actor(ctx), logging.RequestID(ctx)}
+}
+
+// EachUserHistory passes the history of the user between start and end to fn
+// in chronological order, row by row, so a report of any size is never held
+// in memory. It stops at the first error of fn and returns it.
+func (r *UserSegmentHistoryRepositoryDB) EachUserHistory(ctx context.Context, userID int64, start, end time.Time, fn func(models.UserSegmentsHistory) error) error {
+	query := `
+	SELECT id, user_id, segment_slug, operation_type, operation_date, COALESCE(reason, ''),
+		COALESCE(source, ''), COALESCE(actor, ''), COALESCE(request_id, '')
+	FROM user_segments_history
+	WHERE user_id = $1
+	AND operation_date BETWEEN $2 AND $3
+	ORDER BY operation_date, id;
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, userID, start, end)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var history models.UserSegmentsHistory
+		if err := rows.Scan(
+			&history.ID,
+			&history.UserID,
+			&history.SegmentSlug,
+			&history.OperationType,
+			&history.OperationDate,
+			&history.Reason,
+			&history.Source,
+			&history.Actor,
+			&history.RequestID,
+		); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		if err := fn(history); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"API/internal/models"
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEachUserHistory(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	repo := NewUserSegmentHistoryRepository(mockDB)
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0).Add(-time.Nanosecond)
+	historyRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "user_id", "segment_slug", "operation_type", "operation_date",
+			"reason", "source", "actor", "request_id"}).
+			AddRow(1, 1000, "VIDEO", models.ADD, start, "campaign", models.SourceBulk, "billing", "req-1").
+			AddRow(2, 1000, "VIDEO", models.DELETE, start.Add(time.Hour), "", models.SourceTTL, "", "")
+	}
+
+	t.Run("should pass every row in order", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("ORDER BY operation_date, id")).
+			WithArgs(1000, start, end).
+			WillReturnRows(historyRows())
+
+		var got []models.UserSegmentsHistory
+		err := repo.EachUserHistory(context.Background(), 1000, start, end, func(history models.UserSegmentsHistory) error {
+			got = append(got, history)
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, got, 2)
+		assert.Equal(t, models.ADD, got[0].OperationType)
+		assert.Equal(t, "campaign", got[0].Reason)
+		assert.Equal(t, models.DELETE, got[1].OperationType)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should stop at the first error of fn", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("ORDER BY operation_date, id")).
+			WithArgs(1000, start, end).
+			WillReturnRows(historyRows())
+
+		stop := errors.New("client gone")
+		calls := 0
+		err := repo.EachUserHistory(context.Background(), 1000, start, end, func(models.UserSegmentsHistory) error {
+			calls++
+			return stop
+		})
+
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 1, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"API/internal/auth"
+	"API/internal/models"
+	"API/internal/repository"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+// bootstrapSubject is the principal subject of the configured bootstrap key.
+const bootstrapSubject = "bootstrap"
+
+//go:generate mockery --name=IAuthService --output=mocks --outpkg=mocks
+type IAuthService interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (models.Principal, error)
+	AuthenticateToken(ctx context.Context, token string) (models.Principal, error)
+	CreateAPIKey(ctx context.Context, req models.CreateAPIKeyRequest) (models.CreatedAPIKey, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	DeleteAPIKey(ctx context.Context, id int64) error
+}
+
+// AuthService authenticates API keys stored in Postgres, the bootstrap key
+// and JWT bearer tokens, and manages the stored API keys.
+type AuthService struct {
+	Keys          repository.APIKeyRepository
+	JWT           *auth.JWTVerifier
+	bootstrapHash string
+}
+
+func NewAuthService(keys repository.APIKeyRepository, jwt *auth.JWTVerifier, bootstrapKey string) *AuthService {
+	service := &AuthService{Keys: keys, JWT: jwt}
+	if bootstrapKey != "" {
+		service.bootstrapHash = auth.HashAPIKey(bootstrapKey)
+	}
+	return service
+}
+
+// AuthenticateAPIKey returns the principal of key. Unknown keys return an
+// error wrapping models.ErrUnauthenticated.
+func (s *AuthService) AuthenticateAPIKey(ctx context.Context, key string) (models.Principal, error) {
+	hash := auth.HashAPIKey(key)
+	if s.bootstrapHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(s.bootstrapHash)) == 1 {
+		return models.Principal{
+			Subject: bootstrapSubject,
+			Method:  models.AuthMethodAPIKey,
+			Scopes:  models.Scopes,
+		}, nil
+	}
+
+	stored, err := s.Keys.AuthenticateAPIKey(ctx, hash)
+	if errors.Is(err, models.ErrAPIKeyNotFound) {
+		return models.Principal{}, fmt.Errorf("%w: unknown API key", models.ErrUnauthenticated)
+	}
+	if err != nil {
+		return models.Principal{}, err
+	}
+	return models.Principal{
+		Subject: stored.Name,
+		Method:  models.AuthMethodAPIKey,
+		Scopes:  stored.Scopes,
+	}, nil
+}
+
+// AuthenticateToken returns the principal of a bearer token, which is either
+// an API key or a JWT.
+func (s *AuthService) AuthenticateToken(ctx context.Context, token string) (models.Principal, error) {
+	if auth.IsAPIKey(token) {
+		return s.AuthenticateAPIKey(ctx, token)
+	}
+
+	principal, err := s.JWT.Verify(token)
+	if err != nil {
+		return models.Principal{}, fmt.Errorf("%w: %w", models.ErrUnauthenticated, err)
+	}
+	return principal, nil
+}
+
+// CreateAPIKey generates and stores a key for a normalized request. The
+// returned secret is not kept.
+func (s *AuthService) CreateAPIKey(ctx context.Context, req models.CreateAPIKeyRequest) (models.CreatedAPIKey, error) {
+	secret, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		return models.CreatedAPIKey{}, fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	key := models.APIKey{
+		Name:   req.Name,
+		Prefix: prefix,
+		Scopes: req.Scopes,
+	}
+	if err := s.Keys.CreateAPIKey(ctx, &key, auth.HashAPIKey(secret)); err != nil {
+		return models.CreatedAPIKey{}, err
+	}
+	return models.CreatedAPIKey{APIKey: key, Key: secret}, nil
+}
+
+func (s *AuthService) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	return s.Keys.ListAPIKeys(ctx)
+}
+
+func (s *AuthService) DeleteAPIKey(ctx context.Context, id int64) error {
+	return s.Keys.DeleteAPIKey(ctx, id)
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"API/internal/audit"
+	"API/internal/logging"
+	"API/internal/models"
+	"API/internal/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+)
+
+//go:generate mockery --name=IBulkMembershipService --output=mocks --outpkg=mocks
+type IBulkMembershipService interface {
+	SubmitBulkJob(ctx context.Context, req models.BulkMembershipRequest, rows models.BulkRowReader) (models.BulkJob, error)
+	GetBulkJob(ctx context.Context, id int64) (models.BulkJob, error)
+	BulkJobErrors(ctx context.Context, id int64, fn func(models.BulkRow) error) error
+}
+
+// BulkMembershipService accepts bulk membership updates and applies them as
+// background jobs, ChunkSize rows per transaction. Jobs are stored with their
+// progress, so a job interrupted by a restart is resumed where it stopped.
+type BulkMembershipService struct {
+	Repo      repository.BulkJobRepository
+	Segments  repository.SegmentRepository
+	Jobs      IJobQueue
+	ChunkSize int64
+	MaxRows   int64
+	Logger    *slog.Logger
+}
+
+func NewBulkMembershipService(repo repository.BulkJobRepository, segments repository.SegmentRepository, jobs IJobQueue, chunkSize, maxRows int64, logger *slog.Logger) *BulkMembershipService {
+	return &BulkMembershipService{
+		Repo:      repo,
+		Segments:  segments,
+		Jobs:      jobs,
+		ChunkSize: chunkSize,
+		MaxRows:   maxRows,
+		Logger:    logger,
+	}
+}
+
+// SubmitBulkJob validates the request, checks that the calling principal
+// may write every segment and that none is unknown or archived, stores the
+// job with its rows and queues the background job that applies them. Rows are read while they are
+// stored; a body with more than MaxRows rows is rejected.
+func (s *BulkMembershipService) SubmitBulkJob(ctx context.Context, req models.BulkMembershipRequest, rows models.BulkRowReader) (models.BulkJob, error) {
+	if err := req.Validate(); err != nil {
+		return models.BulkJob{}, err
+	}
+	ttl, err := req.ParseTTL()
+	if err != nil {
+		return models.BulkJob{}, fmt.Errorf("%w: %v", models.ErrInvalidMembership, err)
+	}
+
+	slugs := append(slices.Clip(req.AddSegments), req.DeleteSegments...)
+	if err := authorizeSegments(ctx, s.Segments, slugs, models.SegmentACL.CanWrite); err != nil {
+		return models.BulkJob{}, err
+	}
+	if err := s.checkSegments(ctx, slugs); err != nil {
+		return models.BulkJob{}, err
+	}
+
+	job, err := s.Repo.CreateBulkJob(ctx, models.BulkJob{
+		AddSegments:    req.AddSegments,
+		DeleteSegments: req.DeleteSegments,
+		TTL:            ttl,
+		Reason:         req.Reason,
+		Actor:          audit.Actor(ctx),
+		RequestID:      logging.RequestID(ctx),
+	}, s.Jobs.NewJob(ctx, models.JobBulkMembership, nil), &checkedRows{rows: rows, max: s.MaxRows})
+	if err != nil {
+		return models.BulkJob{}, err
+	}
+
+	s.Jobs.Wake()
+	return job, nil
+}
+
+// checkSegments rejects slugs of segments that do not exist or are archived.
+func (s *BulkMembershipService) checkSegments(ctx context.Context, slugs []models.Slug) error {
+	refs, err := s.Segments.LookupSegments(ctx, slugs)
+	if err != nil {
+		return err
+	}
+
+	usable := make(map[models.Slug]bool, len(refs))
+	for _, ref := range refs {
+		usable[ref.Slug] = !ref.Archived
+	}
+	var rejected []models.Slug
+	for _, slug := range slugs {
+		if !usable[slug] {
+			rejected = append(rejected, slug)
+		}
+	}
+	if len(rejected) > 0 {
+		return fmt.Errorf("%w: unknown or archived segments %v", models.ErrMembershipRejected, rejected)
+	}
+	return nil
+}
+
+// GetBulkJob returns the bulk job if the calling principal submitted it or is
+// an admin.
+func (s *BulkMembershipService) GetBulkJob(ctx context.Context, id int64) (models.BulkJob, error) {
+	job, err := s.Repo.GetBulkJob(ctx, id)
+	if err != nil {
+		return models.BulkJob{}, err
+	}
+	if !submitterOrAdmin(ctx, job.Actor) {
+		return models.BulkJob{}, fmt.Errorf("%w: bulk job %d was submitted by another principal", models.ErrForbidden, id)
+	}
+	return job, nil
+}
+
+// BulkJobErrors passes the failed rows of the job to fn. Like GetBulkJob it
+// returns ErrBulkJobNotFound or ErrForbidden before calling fn.
+func (s *BulkMembershipService) BulkJobErrors(ctx context.Context, id int64, fn func(models.BulkRow) error) error {
+	if _, err := s.GetBulkJob(ctx, id); err != nil {
+		return err
+	}
+	return s.Repo.BulkJobErrors(ctx, id, fn)
+}
+
+// ProcessBulkJob is the JobHandler of bulk_membership jobs. It applies the
+// remaining rows of the bulk job chunk by chunk and returns the finished bulk
+// job. History rows and events are attributed to the principal and request
+// that submitted it. The bulk job is failed by FailBulkJob when the job ends
+// cancelled or failed.
+func (s *BulkMembershipService) ProcessBulkJob(ctx context.Context, job models.Job, progress func(float64) error) (any, error) {
+	var payload models.BulkMembershipPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	bulk, err := s.Repo.GetBulkJob(ctx, payload.BulkJobID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = audit.WithSource(ctx, models.SourceBulk)
+	ctx = audit.WithReason(ctx, bulk.Reason)
+
+	for !bulk.Status.Finished() {
+		if err := progress(bulk.Progress); err != nil {
+			return nil, err
+		}
+		if bulk, err = s.Repo.ApplyBulkChunk(ctx, payload.BulkJobID, s.ChunkSize); err != nil {
+			return nil, err
+		}
+	}
+	s.Logger.InfoContext(ctx, "Bulk job finished", "bulk_job_id", bulk.ID, "rows", bulk.TotalRows, "failed", bulk.FailedRows)
+	return bulk, nil
+}
+
+// FailBulkJob is the JobFailureHook of bulk_membership jobs. It fails the
+// bulk job of a job that ended cancelled or failed, so the two never disagree;
+// rows applied before stay applied.
+func (s *BulkMembershipService) FailBulkJob(ctx context.Context, job models.Job, reason error) error {
+	var payload models.BulkMembershipPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+	return s.Repo.FailBulkJob(ctx, payload.BulkJobID, reason.Error())
+}
+
+// checkedRows rejects bodies with more than max rows and reports unreadable
+// bodies as invalid requests.
+type checkedRows struct {
+	rows models.BulkRowReader
+	max  int64
+	read int64
+}
+
+func (r *checkedRows) Next() (models.BulkRow, error) {
+	row, err := r.rows.Next()
+	if errors.Is(err, io.EOF) {
+		return row, io.EOF
+	}
+	if err != nil {
+		return row, fmt.Errorf("%w: %v", models.ErrInvalidMembership, err)
+	}
+	if r.read++; r.read > r.max {
+		return row, fmt.Errorf("%w: more than %d rows", models.ErrInvalidMembership, r.max)
+	}
+	return row, nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"API/internal/kafka"
+	"API/internal/models"
+	"context"
+	"fmt"
+)
+
+//go:generate mockery --name=IDLQService --output=mocks --outpkg=mocks
+type IDLQService interface {
+	ListMessages(ctx context.Context, topic string, limit int) ([]models.DLQMessage, error)
+	Replay(ctx context.Context, topic string, partition int32, offset int64) error
+}
+
+type DLQService struct {
+	DLQ *kafka.DLQ
+}
+
+func NewDLQService(dlq *kafka.DLQ) *DLQService {
+	return &DLQService{DLQ: dlq}
+}
+
+func (s *DLQService) ListMessages(ctx context.Context, topic string, limit int) ([]models.DLQMessage, error) {
+	messages, err := s.DLQ.List(topic, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead-letter messages of %s: %w", topic, err)
+	}
+	return messages, nil
+}
+
+func (s *DLQService) Replay(ctx context.Context, topic string, partition int32, offset int64) error {
+	if err := s.DLQ.Replay(ctx, topic, partition, offset); err != nil {
+		return fmt.Errorf("failed to replay dead-letter message %s/%d/%d: %w", topic, partition, offset, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"API/internal/metrics"
+	"API/internal/repository"
+	"API/internal/tracing"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// ExpiryWorker periodically removes memberships whose TTL has passed and
+// segments whose expiry has passed. It reads the ttl and expires_at columns
+// directly, so it does not depend on segment_expiry messages arriving on time.
+type ExpiryWorker struct {
+	Repo      repository.UserSegmentRepository
+	Segments  repository.SegmentRepository
+	Interval  time.Duration
+	BatchSize int
+	Logger    *slog.Logger
+}
+
+func NewExpiryWorker(repo repository.UserSegmentRepository, segments repository.SegmentRepository, interval time.Duration, batchSize int, logger *slog.Logger) *ExpiryWorker {
+	return &ExpiryWorker{
+		Repo:      repo,
+		Segments:  segments,
+		Interval:  interval,
+		BatchSize: batchSize,
+		Logger:    logger,
+	}
+}
+
+// Run sweeps expired memberships every Interval until ctx is cancelled.
+func (w *ExpiryWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	w.Logger.Info("Started TTL expiry worker", "interval", w.Interval, "batch_size", w.BatchSize)
+
+	for {
+		if removed, err := w.Sweep(ctx); err != nil {
+			w.Logger.Error("TTL expiry sweep failed", "error", err)
+		} else if removed > 0 {
+			w.Logger.Info("TTL expiry sweep removed memberships", "removed", removed)
+		}
+		if expired, err := w.SweepSegments(ctx); err != nil {
+			w.Logger.Error("Segment expiry sweep failed", "error", err)
+		} else if expired > 0 {
+			w.Logger.Info("Segment expiry sweep removed segments", "expired", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep deletes expired memberships batch by batch until a batch comes back
+// incomplete, and returns the number of removed memberships.
+func (w *ExpiryWorker) Sweep(ctx context.Context) (int, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ttl sweep")
+	defer span.End()
+
+	removed := 0
+	for ctx.Err() == nil {
+		expired, err := w.Repo.DeleteExpiredUserSegments(ctx, w.BatchSize)
+		if err != nil {
+			return removed, err
+		}
+		removed += len(expired)
+		metrics.TTLExpirations.WithLabelValues("sweeper").Add(float64(len(expired)))
+
+		if len(expired) < w.BatchSize {
+			break
+		}
+	}
+	return removed, nil
+}
+
+// segmentBatchSize is the number of expired segments handled per call, each
+// of them removes its memberships in batches of its own.
+const segmentBatchSize = 10
+
+// SweepSegments archives or deletes expired segments together with their
+// memberships and returns the number of expired segments.
+func (w *ExpiryWorker) SweepSegments(ctx context.Context) (int, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "segment expiry sweep")
+	defer span.End()
+
+	expired := 0
+	for ctx.Err() == nil {
+		slugs, err := w.Segments.ExpireSegments(ctx, segmentBatchSize)
+		expired += len(slugs)
+		if err != nil {
+			return expired, err
+		}
+		if len(slugs) < segmentBatchSize {
+			break
+		}
+	}
+	return expired, nil
+}
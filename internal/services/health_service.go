@@ -0,0 +1,135 @@
+package services
+
+import (
+	"API/internal/models"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pinger is a dependency whose connectivity can be checked.
+//
+//go:generate mockery --name=Pinger --output=mocks --outpkg=mocks
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// LagReporter is a consumer that also reports its uncommitted messages.
+//
+//go:generate mockery --name=LagReporter --output=mocks --outpkg=mocks
+type LagReporter interface {
+	Pinger
+	Lag(ctx context.Context) (int64, error)
+}
+
+// HealthService checks the dependencies the service needs to handle traffic.
+// Each check is bounded by Timeout. A consumer lag above MaxConsumerLag makes
+// the consumer unhealthy; zero only reports the lag.
+type HealthService struct {
+	DB             Pinger
+	Producer       Pinger
+	Consumer       LagReporter
+	Timeout        time.Duration
+	MaxConsumerLag int64
+
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService(db, producer Pinger, consumer LagReporter, timeout time.Duration, maxConsumerLag int64) *HealthService {
+	return &HealthService{
+		DB:             db,
+		Producer:       producer,
+		Consumer:       consumer,
+		Timeout:        timeout,
+		MaxConsumerLag: maxConsumerLag,
+	}
+}
+
+// SetShuttingDown makes every following readiness check fail.
+func (s *HealthService) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+// Readiness checks all dependencies concurrently. The report is up only when
+// every dependency is up and the service is not shutting down.
+func (s *HealthService) Readiness(ctx context.Context) models.HealthReport {
+	if s.shuttingDown.Load() {
+		return models.HealthReport{
+			Status: models.HealthStatusDown,
+			Error:  "shutting down",
+		}
+	}
+
+	checks := map[string]func(ctx context.Context) models.DependencyHealth{
+		"postgres":       pingCheck(s.DB),
+		"kafka_producer": pingCheck(s.Producer),
+		"kafka_consumer": s.checkConsumer,
+	}
+
+	report := models.HealthReport{
+		Status:       models.HealthStatusUp,
+		Dependencies: make(map[string]models.DependencyHealth, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, s.Timeout)
+			defer cancel()
+			result := check(checkCtx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Dependencies[name] = result
+			if result.Status != models.HealthStatusUp {
+				report.Status = models.HealthStatusDown
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+func pingCheck(dependency Pinger) func(ctx context.Context) models.DependencyHealth {
+	return func(ctx context.Context) models.DependencyHealth {
+		start := time.Now()
+		err := dependency.Ping(ctx)
+		return dependencyHealth(start, nil, err)
+	}
+}
+
+func (s *HealthService) checkConsumer(ctx context.Context) models.DependencyHealth {
+	start := time.Now()
+	if err := s.Consumer.Ping(ctx); err != nil {
+		return dependencyHealth(start, nil, err)
+	}
+
+	lag, err := s.Consumer.Lag(ctx)
+	if err != nil {
+		return dependencyHealth(start, nil, err)
+	}
+	if s.MaxConsumerLag > 0 && lag > s.MaxConsumerLag {
+		err = fmt.Errorf("consumer lag %d exceeds %d", lag, s.MaxConsumerLag)
+	}
+	return dependencyHealth(start, &lag, err)
+}
+
+func dependencyHealth(start time.Time, lag *int64, err error) models.DependencyHealth {
+	health := models.DependencyHealth{
+		Status:    models.HealthStatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Lag:       lag,
+	}
+	if err != nil {
+		health.Status = models.HealthStatusDown
+		health.Error = err.Error()
+	}
+	return health
+}
@@ -0,0 +1,429 @@
+package services
+
+import (
+	"API/internal/auth"
+	"API/internal/logging"
+	"API/internal/models"
+	"API/internal/repository"
+	"API/internal/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// jobCleanupInterval is how often finished jobs older than the retention
+	// are deleted.
+	jobCleanupInterval = time.Hour
+	// jobEndTimeout bounds recording the outcome of an attempt.
+	jobEndTimeout = 5 * time.Second
+	// maxJobBackoff caps the doubled backoff between attempts.
+	maxJobBackoff = 24 * time.Hour
+)
+
+// JobHandler runs one attempt of a job. It reports its progress as a
+// percentage through progress, which returns an error once the attempt has
+// to stop, ErrJobCancelled when the job was cancelled; the handler should
+// then return that error. The result is stored as JSON with the job.
+type JobHandler func(ctx context.Context, job models.Job, progress func(percent float64) error) (any, error)
+
+// JobFailureHook settles what a job worked on after the job ended failed or
+// cancelled, reason telling why. It runs whichever way the job ended: when an
+// attempt fails or is cancelled, when a queued job is cancelled, and when a
+// job is found cancelled or out of attempts as it is claimed.
+type JobFailureHook func(ctx context.Context, job models.Job, reason error) error
+
+// JobPresenter completes a job of its kind as it is read, such as with what
+// is not worth storing in its result because it goes stale.
+type JobPresenter func(ctx context.Context, job models.Job) (models.Job, error)
+
+//go:generate mockery --name=IJobQueue --output=mocks --outpkg=mocks
+type IJobQueue interface {
+	NewJob(ctx context.Context, kind string, payload json.RawMessage) models.Job
+	Enqueue(ctx context.Context, kind string, payload any) (models.Job, error)
+	Wake()
+	GetJob(ctx context.Context, id int64) (models.Job, error)
+	CancelJob(ctx context.Context, id int64) (models.Job, error)
+}
+
+// JobQueue runs background jobs stored in the jobs table with Workers
+// workers. A claimed job is leased to the replica for Lease and the lease is
+// extended while the job runs, so the jobs of a replica that stops are
+// claimed again by another one. A failed attempt is retried after Backoff,
+// doubled on every attempt up to a day, until MaxAttempts attempts failed.
+type JobQueue struct {
+	Repo        repository.JobStore
+	WorkerID    string
+	Workers     int
+	Interval    time.Duration
+	Lease       time.Duration
+	Backoff     time.Duration
+	MaxAttempts int
+	Retention   time.Duration
+	Logger      *slog.Logger
+
+	handlers     map[string]JobHandler
+	failureHooks map[string]JobFailureHook
+	presenters   map[string]JobPresenter
+	wake         chan struct{}
+}
+
+func NewJobQueue(repo repository.JobStore, workerID string, workers int, interval, lease, backoff time.Duration, maxAttempts int, retention time.Duration, logger *slog.Logger) *JobQueue {
+	return &JobQueue{
+		Repo:         repo,
+		WorkerID:     workerID,
+		Workers:      workers,
+		Interval:     interval,
+		Lease:        lease,
+		Backoff:      backoff,
+		MaxAttempts:  maxAttempts,
+		Retention:    retention,
+		Logger:       logger,
+		handlers:     make(map[string]JobHandler),
+		failureHooks: make(map[string]JobFailureHook),
+		presenters:   make(map[string]JobPresenter),
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Register sets the handler of the jobs of kind. Handlers must be registered
+// before Run; the workers of this replica only claim jobs of registered kinds.
+func (q *JobQueue) Register(kind string, handler JobHandler) {
+	q.handlers[kind] = handler
+}
+
+// OnFailure sets the failure hook of the jobs of kind. Hooks must be set
+// before Run and before jobs can be cancelled.
+func (q *JobQueue) OnFailure(kind string, hook JobFailureHook) {
+	q.failureHooks[kind] = hook
+}
+
+// OnRead sets the presenter of the jobs of kind. Presenters must be set
+// before jobs can be read.
+func (q *JobQueue) OnRead(kind string, presenter JobPresenter) {
+	q.presenters[kind] = presenter
+}
+
+// NewJob returns a job of kind submitted by the calling principal and
+// request, for repositories that queue it together with its input.
+func (q *JobQueue) NewJob(ctx context.Context, kind string, payload json.RawMessage) models.Job {
+	job := models.Job{
+		Kind:        kind,
+		Payload:     payload,
+		MaxAttempts: q.MaxAttempts,
+		RequestID:   logging.RequestID(ctx),
+	}
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		job.Actor = principal.Subject
+		job.ActorTeams = principal.Teams
+		job.ActorScopes = principal.Scopes
+	}
+	return job
+}
+
+// Enqueue queues a job of kind with payload encoded as JSON and wakes a worker.
+func (q *JobQueue) Enqueue(ctx context.Context, kind string, payload any) (models.Job, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return models.Job{}, fmt.Errorf("failed to encode payload of %s job: %w", kind, err)
+	}
+	job, err := q.Repo.EnqueueJob(ctx, q.NewJob(ctx, kind, encoded))
+	if err != nil {
+		return models.Job{}, err
+	}
+	q.Wake()
+	return job, nil
+}
+
+// Wake makes an idle worker look for due jobs at once.
+func (q *JobQueue) Wake() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// GetJob returns the job, completed by the presenter of its kind, if the
+// calling principal submitted it or is an admin.
+func (q *JobQueue) GetJob(ctx context.Context, id int64) (models.Job, error) {
+	job, err := q.getJob(ctx, id)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if presenter, ok := q.presenters[job.Kind]; ok {
+		return presenter(ctx, job)
+	}
+	return job, nil
+}
+
+func (q *JobQueue) getJob(ctx context.Context, id int64) (models.Job, error) {
+	job, err := q.Repo.GetJob(ctx, id)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if err := authorizeJob(ctx, job); err != nil {
+		return models.Job{}, err
+	}
+	return job, nil
+}
+
+// CancelJob cancels the job if the calling principal submitted it or is an
+// admin. A queued job is cancelled at once and its failure hook runs; a
+// running one stops at its next heartbeat.
+func (q *JobQueue) CancelJob(ctx context.Context, id int64) (models.Job, error) {
+	if _, err := q.getJob(ctx, id); err != nil {
+		return models.Job{}, err
+	}
+	job, err := q.Repo.CancelJob(ctx, id)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if job.Status == models.JobCancelled {
+		endCtx, stop := endContext(ctx)
+		defer stop()
+		q.settle(endCtx, q.Logger.With("job_id", job.ID, "kind", job.Kind), job, models.ErrJobCancelled)
+	}
+	return job, nil
+}
+
+// authorizeJob lets the submitter of a job and admins see and cancel it.
+func authorizeJob(ctx context.Context, job models.Job) error {
+	if !submitterOrAdmin(ctx, job.Actor) {
+		return fmt.Errorf("%w: job %d was submitted by another principal", models.ErrForbidden, job.ID)
+	}
+	return nil
+}
+
+// submitterOrAdmin reports whether the calling principal is actor, the
+// principal that submitted some work, or an admin. Everything is open when
+// authentication is disabled.
+func submitterOrAdmin(ctx context.Context, actor string) bool {
+	principal, ok := auth.PrincipalFrom(ctx)
+	return !ok || principal.HasScope(models.ScopeAdmin) || principal.Subject == actor
+}
+
+// Run starts the workers and deletes finished jobs after the retention until
+// ctx is cancelled. It returns when every worker has stopped; running
+// attempts are interrupted and their jobs queued again.
+func (q *JobQueue) Run(ctx context.Context) {
+	q.Logger.Info("Started job workers", "workers", q.Workers, "worker_id", q.WorkerID, "kinds", q.kinds())
+
+	var wg sync.WaitGroup
+	for range q.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+
+	ticker := time.NewTicker(jobCleanupInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := q.Repo.DeleteFinishedJobs(ctx, time.Now().Add(-q.Retention)); err != nil && ctx.Err() == nil {
+			q.Logger.Error("Job cleanup failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *JobQueue) work(ctx context.Context) {
+	for {
+		claimed, err := q.RunNext(ctx)
+		if err != nil && ctx.Err() == nil {
+			q.Logger.Error("Claiming a job failed", "error", err)
+		}
+		if claimed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(q.Interval):
+		}
+	}
+}
+
+func (q *JobQueue) kinds() []string {
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+// RunNext claims the next due job and runs one attempt of it. It returns
+// false when no job was due.
+func (q *JobQueue) RunNext(ctx context.Context) (bool, error) {
+	job, ok, err := q.Repo.ClaimJob(ctx, q.WorkerID, q.kinds(), q.Lease)
+	if err != nil || !ok {
+		return false, err
+	}
+	q.run(ctx, job)
+	return true, nil
+}
+
+// run runs one attempt of job and records its outcome. The attempt runs as
+// the principal and request that submitted the job, with the teams and scopes
+// the principal had then, so handlers authorize it like the request did.
+func (q *JobQueue) run(ctx context.Context, job models.Job) {
+	logger := q.Logger.With("job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+
+	// A job claimed after its lease expired was left by a replica that
+	// stopped without releasing it.
+	endCtx, stop := endContext(ctx)
+	defer stop()
+	switch {
+	case job.CancelRequested:
+		err := q.Repo.MarkJobCancelled(endCtx, job.ID, q.WorkerID)
+		q.endFailed(endCtx, logger, job, "Job cancelled", err, models.ErrJobCancelled)
+		return
+	case job.Attempts > job.MaxAttempts:
+		reason := errors.New("the worker of the last attempt stopped")
+		err := q.Repo.FailJob(endCtx, job.ID, q.WorkerID, reason.Error())
+		q.endFailed(endCtx, logger, job, "Job failed", err, reason)
+		return
+	}
+
+	jobCtx := logging.WithRequestID(ctx, job.RequestID)
+	if submitter, ok := job.Submitter(); ok {
+		jobCtx = auth.WithPrincipal(jobCtx, submitter)
+	}
+	jobCtx, span := tracing.Tracer().Start(jobCtx, "job "+job.Kind)
+	defer span.End()
+	jobCtx, cancel := context.WithCancelCause(jobCtx)
+	defer cancel(nil)
+
+	var progress atomic.Uint64
+	progress.Store(math.Float64bits(job.Progress))
+	heartbeat := make(chan struct{})
+	go func() {
+		defer close(heartbeat)
+		q.heartbeat(jobCtx, logger, job, &progress, cancel)
+	}()
+
+	result, err := q.handlers[job.Kind](jobCtx, job, func(percent float64) error {
+		progress.Store(math.Float64bits(percent))
+		return context.Cause(jobCtx)
+	})
+	cause := context.Cause(jobCtx)
+	cancel(nil)
+	<-heartbeat
+
+	endCtx, stop = endContext(ctx)
+	defer stop()
+	switch {
+	case errors.Is(cause, models.ErrJobLeaseLost):
+		logger.WarnContext(jobCtx, "Lost the lease of the job, another worker may run it")
+	case err == nil:
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			reason := fmt.Errorf("failed to encode result: %w", err)
+			err = q.Repo.FailJob(endCtx, job.ID, q.WorkerID, reason.Error())
+			q.endFailed(endCtx, logger, job, "Job failed", err, reason)
+			return
+		}
+		q.end(endCtx, logger, "Job succeeded", q.Repo.CompleteJob(endCtx, job.ID, q.WorkerID, encoded))
+	case errors.Is(cause, models.ErrJobCancelled):
+		err := q.Repo.MarkJobCancelled(endCtx, job.ID, q.WorkerID)
+		q.endFailed(endCtx, logger, job, "Job cancelled", err, models.ErrJobCancelled)
+	case ctx.Err() != nil:
+		q.end(endCtx, logger, "Job released on shutdown", q.Repo.ReleaseJob(endCtx, job.ID, q.WorkerID))
+	case job.LastAttempt():
+		logger.ErrorContext(jobCtx, "Job attempt failed", "error", err)
+		q.endFailed(endCtx, logger, job, "Job failed", q.Repo.FailJob(endCtx, job.ID, q.WorkerID, err.Error()), err)
+	default:
+		delay := q.retryDelay(job.Attempts)
+		logger.WarnContext(jobCtx, "Job attempt failed", "retry_in", delay, "error", err)
+		q.end(endCtx, logger, "Job queued for retry", q.Repo.RetryJob(endCtx, job.ID, q.WorkerID, err.Error(), delay))
+	}
+}
+
+// retryDelay returns the backoff after attempts failed attempts: Backoff
+// doubled on every attempt after the first, capped at maxJobBackoff.
+func (q *JobQueue) retryDelay(attempts int) time.Duration {
+	delay := q.Backoff
+	for i := 1; i < attempts && delay < maxJobBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxJobBackoff)
+}
+
+// heartbeat saves the progress and extends the lease every third of the
+// lease until ctx is done. It cancels ctx when the job is cancelled or the
+// lease was lost.
+func (q *JobQueue) heartbeat(ctx context.Context, logger *slog.Logger, job models.Job, progress *atomic.Uint64, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(q.Lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cancelled, err := q.Repo.HeartbeatJob(ctx, job.ID, q.WorkerID, math.Float64frombits(progress.Load()), q.Lease)
+		switch {
+		case errors.Is(err, models.ErrJobLeaseLost):
+			cancel(err)
+			return
+		case err != nil && ctx.Err() == nil:
+			logger.WarnContext(ctx, "Job heartbeat failed", "error", err)
+		case cancelled:
+			cancel(models.ErrJobCancelled)
+			return
+		}
+	}
+}
+
+// endContext returns the context of recording the outcome of an attempt,
+// which is done even when the worker is stopping.
+func endContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), jobEndTimeout)
+}
+
+// end logs the outcome of an attempt, or the failure to record it.
+func (q *JobQueue) end(ctx context.Context, logger *slog.Logger, msg string, err error) {
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to record the outcome of the job", "outcome", msg, "error", err)
+		return
+	}
+	logger.InfoContext(ctx, msg)
+}
+
+// endFailed logs a failed or cancelled outcome like end and, once it is
+// recorded, runs the failure hook of the job.
+func (q *JobQueue) endFailed(ctx context.Context, logger *slog.Logger, job models.Job, msg string, err, reason error) {
+	q.end(ctx, logger, msg, err)
+	if err == nil {
+		q.settle(ctx, logger, job, reason)
+	}
+}
+
+// settle runs the failure hook of the kind of job, if any.
+func (q *JobQueue) settle(ctx context.Context, logger *slog.Logger, job models.Job, reason error) {
+	hook, ok := q.failureHooks[job.Kind]
+	if !ok {
+		return
+	}
+	if err := hook(ctx, job, reason); err != nil {
+		logger.ErrorContext(ctx, "Failed to settle the job after it ended", "reason", reason, "error", err)
+	}
+}
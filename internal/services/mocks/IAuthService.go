@@ -0,0 +1,161 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	models "API/internal/models"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IAuthService is an autogenerated mock type for the IAuthService type
+type IAuthService struct {
+	mock.Mock
+}
+
+// AuthenticateAPIKey provides a mock function with given fields: ctx, key
+func (_m *IAuthService) AuthenticateAPIKey(ctx context.Context, key string) (models.Principal, error) {
+	ret := _m.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AuthenticateAPIKey")
+	}
+
+	var r0 models.Principal
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (models.Principal, error)); ok {
+		return rf(ctx, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.Principal); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(models.Principal)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AuthenticateToken provides a mock function with given fields: ctx, token
+func (_m *IAuthService) AuthenticateToken(ctx context.Context, token string) (models.Principal, error) {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AuthenticateToken")
+	}
+
+	var r0 models.Principal
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (models.Principal, error)); ok {
+		return rf(ctx, token)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.Principal); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Get(0).(models.Principal)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateAPIKey provides a mock function with given fields: ctx, req
+func (_m *IAuthService) CreateAPIKey(ctx context.Context, req models.CreateAPIKeyRequest) (models.CreatedAPIKey, error) {
+	ret := _m.Called(ctx, req)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateAPIKey")
+	}
+
+	var r0 models.CreatedAPIKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.CreateAPIKeyRequest) (models.CreatedAPIKey, error)); ok {
+		return rf(ctx, req)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.CreateAPIKeyRequest) models.CreatedAPIKey); ok {
+		r0 = rf(ctx, req)
+	} else {
+		r0 = ret.Get(0).(models.CreatedAPIKey)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.CreateAPIKeyRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteAPIKey provides a mock function with given fields: ctx, id
+func (_m *IAuthService) DeleteAPIKey(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteAPIKey")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ListAPIKeys provides a mock function with given fields: ctx
+func (_m *IAuthService) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListAPIKeys")
+	}
+
+	var r0 []models.APIKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]models.APIKey, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []models.APIKey); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.APIKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewIAuthService creates a new instance of IAuthService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIAuthService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IAuthService {
+	mock := &IAuthService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,103 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	models "API/internal/models"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IBulkMembershipService is an autogenerated mock type for the IBulkMembershipService type
+type IBulkMembershipService struct {
+	mock.Mock
+}
+
+// BulkJobErrors provides a mock function with given fields: ctx, id, fn
+func (_m *IBulkMembershipService) BulkJobErrors(ctx context.Context, id int64, fn func(models.BulkRow) error) error {
+	ret := _m.Called(ctx, id, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BulkJobErrors")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, func(models.BulkRow) error) error); ok {
+		r0 = rf(ctx, id, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetBulkJob provides a mock function with given fields: ctx, id
+func (_m *IBulkMembershipService) GetBulkJob(ctx context.Context, id int64) (models.BulkJob, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBulkJob")
+	}
+
+	var r0 models.BulkJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (models.BulkJob, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) models.BulkJob); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(models.BulkJob)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SubmitBulkJob provides a mock function with given fields: ctx, req, rows
+func (_m *IBulkMembershipService) SubmitBulkJob(ctx context.Context, req models.BulkMembershipRequest, rows models.BulkRowReader) (models.BulkJob, error) {
+	ret := _m.Called(ctx, req, rows)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SubmitBulkJob")
+	}
+
+	var r0 models.BulkJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.BulkMembershipRequest, models.BulkRowReader) (models.BulkJob, error)); ok {
+		return rf(ctx, req, rows)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.BulkMembershipRequest, models.BulkRowReader) models.BulkJob); ok {
+		r0 = rf(ctx, req, rows)
+	} else {
+		r0 = ret.Get(0).(models.BulkJob)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.BulkMembershipRequest, models.BulkRowReader) error); ok {
+		r1 = rf(ctx, req, rows)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewIBulkMembershipService creates a new instance of IBulkMembershipService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIBulkMembershipService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IBulkMembershipService {
+	mock := &IBulkMembershipService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,77 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	models "API/internal/models"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IDLQService is an autogenerated mock type for the IDLQService type
+type IDLQService struct {
+	mock.Mock
+}
+
+// ListMessages provides a mock function with given fields: ctx, topic, limit
+func (_m *IDLQService) ListMessages(ctx context.Context, topic string, limit int) ([]models.DLQMessage, error) {
+	ret := _m.Called(ctx, topic, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListMessages")
+	}
+
+	var r0 []models.DLQMessage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) ([]models.DLQMessage, error)); ok {
+		return rf(ctx, topic, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) []models.DLQMessage); ok {
+		r0 = rf(ctx, topic, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.DLQMessage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, topic, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Replay provides a mock function with given fields: ctx, topic, partition, offset
+func (_m *IDLQService) Replay(ctx context.Context, topic string, partition int32, offset int64) error {
+	ret := _m.Called(ctx, topic, partition, offset)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Replay")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int32, int64) error); ok {
+		r0 = rf(ctx, topic, partition, offset)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewIDLQService creates a new instance of IDLQService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIDLQService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IDLQService {
+	mock := &IDLQService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
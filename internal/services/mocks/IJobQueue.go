@@ -0,0 +1,138 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	jsontext "encoding/json/jsontext"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "API/internal/models"
+)
+
+// IJobQueue is an autogenerated mock type for the IJobQueue type
+type IJobQueue struct {
+	mock.Mock
+}
+
+// CancelJob provides a mock function with given fields: ctx, id
+func (_m *IJobQueue) CancelJob(ctx context.Context, id int64) (models.Job, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CancelJob")
+	}
+
+	var r0 models.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (models.Job, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) models.Job); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(models.Job)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Enqueue provides a mock function with given fields: ctx, kind, payload
+func (_m *IJobQueue) Enqueue(ctx context.Context, kind string, payload interface{}) (models.Job, error) {
+	ret := _m.Called(ctx, kind, payload)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Enqueue")
+	}
+
+	var r0 models.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, interface{}) (models.Job, error)); ok {
+		return rf(ctx, kind, payload)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, interface{}) models.Job); ok {
+		r0 = rf(ctx, kind, payload)
+	} else {
+		r0 = ret.Get(0).(models.Job)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, interface{}) error); ok {
+		r1 = rf(ctx, kind, payload)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetJob provides a mock function with given fields: ctx, id
+func (_m *IJobQueue) GetJob(ctx context.Context, id int64) (models.Job, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetJob")
+	}
+
+	var r0 models.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (models.Job, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) models.Job); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(models.Job)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewJob provides a mock function with given fields: ctx, kind, payload
+func (_m *IJobQueue) NewJob(ctx context.Context, kind string, payload jsontext.Value) models.Job {
+	ret := _m.Called(ctx, kind, payload)
+
+	if len(ret) == 0 {
+		panic("no return value specified for NewJob")
+	}
+
+	var r0 models.Job
+	if rf, ok := ret.Get(0).(func(context.Context, string, jsontext.Value) models.Job); ok {
+		r0 = rf(ctx, kind, payload)
+	} else {
+		r0 = ret.Get(0).(models.Job)
+	}
+
+	return r0
+}
+
+// Wake provides a mock function with no fields
+func (_m *IJobQueue) Wake() {
+	_m.Called()
+}
+
+// NewIJobQueue creates a new instance of IJobQueue. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIJobQueue(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IJobQueue {
+	mock := &IJobQueue{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -1,9 +1,10 @@
-// Code generated by mockery v2.49.2. DO NOT EDIT.
+// Code generated by mockery v2.53.5. DO NOT EDIT.
 
 package mocks
 
 import (
 	models "API/internal/models"
+	context "context"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -13,17 +14,17 @@ type ISegmentService struct {
 	mock.Mock
 }
 
-// CreateSegment provides a mock function with given fields: slug
-func (_m *ISegmentService) CreateSegment(slug models.Slug) error {
-	ret := _m.Called(slug)
+// CreateSegment provides a mock function with given fields: ctx, segment
+func (_m *ISegmentService) CreateSegment(ctx context.Context, segment models.SegmentRequest) error {
+	ret := _m.Called(ctx, segment)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateSegment")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(models.Slug) error); ok {
-		r0 = rf(slug)
+	if rf, ok := ret.Get(0).(func(context.Context, models.SegmentRequest) error); ok {
+		r0 = rf(ctx, segment)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -31,17 +32,17 @@ func (_m *ISegmentService) CreateSegment(slug models.Slug) error {
 	return r0
 }
 
-// DeleteSegment provides a mock function with given fields: slug
-func (_m *ISegmentService) DeleteSegment(slug models.Slug) error {
-	ret := _m.Called(slug)
+// DeleteSegment provides a mock function with given fields: ctx, slug
+func (_m *ISegmentService) DeleteSegment(ctx context.Context, slug models.Slug) error {
+	ret := _m.Called(ctx, slug)
 
 	if len(ret) == 0 {
 		panic("no return value specified for DeleteSegment")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(models.Slug) error); ok {
-		r0 = rf(slug)
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) error); ok {
+		r0 = rf(ctx, slug)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -49,9 +50,9 @@ func (_m *ISegmentService) DeleteSegment(slug models.Slug) error {
 	return r0
 }
 
-// GetAllSegments provides a mock function with no fields
-func (_m *ISegmentService) GetAllSegments() ([]models.Segments, error) {
-	ret := _m.Called()
+// GetAllSegments provides a mock function with given fields: ctx, filter
+func (_m *ISegmentService) GetAllSegments(ctx context.Context, filter models.SegmentFilter) ([]models.Segments, error) {
+	ret := _m.Called(ctx, filter)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetAllSegments")
@@ -59,19 +60,177 @@ func (_m *ISegmentService) GetAllSegments() ([]models.Segments, error) {
 
 	var r0 []models.Segments
 	var r1 error
-	if rf, ok := ret.Get(0).(func() ([]models.Segments, error)); ok {
-		return rf()
+	if rf, ok := ret.Get(0).(func(context.Context, models.SegmentFilter) ([]models.Segments, error)); ok {
+		return rf(ctx, filter)
 	}
-	if rf, ok := ret.Get(0).(func() []models.Segments); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(context.Context, models.SegmentFilter) []models.Segments); ok {
+		r0 = rf(ctx, filter)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.Segments)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(context.Context, models.SegmentFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSegment provides a mock function with given fields: ctx, slug
+func (_m *ISegmentService) GetSegment(ctx context.Context, slug models.Slug) (models.Segments, error) {
+	ret := _m.Called(ctx, slug)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSegment")
+	}
+
+	var r0 models.Segments
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) (models.Segments, error)); ok {
+		return rf(ctx, slug)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) models.Segments); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Get(0).(models.Segments)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Slug) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSegmentACL provides a mock function with given fields: ctx, slug
+func (_m *ISegmentService) GetSegmentACL(ctx context.Context, slug models.Slug) (models.SegmentACL, error) {
+	ret := _m.Called(ctx, slug)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSegmentACL")
+	}
+
+	var r0 models.SegmentACL
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) (models.SegmentACL, error)); ok {
+		return rf(ctx, slug)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) models.SegmentACL); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Get(0).(models.SegmentACL)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Slug) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeSegment provides a mock function with given fields: ctx, slug
+func (_m *ISegmentService) PurgeSegment(ctx context.Context, slug models.Slug) (models.Job, error) {
+	ret := _m.Called(ctx, slug)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeSegment")
+	}
+
+	var r0 models.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) (models.Job, error)); ok {
+		return rf(ctx, slug)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) models.Job); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Get(0).(models.Job)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Slug) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RestoreSegment provides a mock function with given fields: ctx, slug
+func (_m *ISegmentService) RestoreSegment(ctx context.Context, slug models.Slug) error {
+	ret := _m.Called(ctx, slug)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreSegment")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) error); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetSegmentACL provides a mock function with given fields: ctx, slug, req
+func (_m *ISegmentService) SetSegmentACL(ctx context.Context, slug models.Slug, req models.SegmentACLRequest) (models.SegmentACL, error) {
+	ret := _m.Called(ctx, slug, req)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetSegmentACL")
+	}
+
+	var r0 models.SegmentACL
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug, models.SegmentACLRequest) (models.SegmentACL, error)); ok {
+		return rf(ctx, slug, req)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug, models.SegmentACLRequest) models.SegmentACL); ok {
+		r0 = rf(ctx, slug, req)
+	} else {
+		r0 = ret.Get(0).(models.SegmentACL)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Slug, models.SegmentACLRequest) error); ok {
+		r1 = rf(ctx, slug, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateSegment provides a mock function with given fields: ctx, slug, patch
+func (_m *ISegmentService) UpdateSegment(ctx context.Context, slug models.Slug, patch models.SegmentPatch) (models.Segments, error) {
+	ret := _m.Called(ctx, slug, patch)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateSegment")
+	}
+
+	var r0 models.Segments
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug, models.SegmentPatch) (models.Segments, error)); ok {
+		return rf(ctx, slug, patch)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug, models.SegmentPatch) models.Segments); ok {
+		r0 = rf(ctx, slug, patch)
+	} else {
+		r0 = ret.Get(0).(models.Segments)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Slug, models.SegmentPatch) error); ok {
+		r1 = rf(ctx, slug, patch)
 	} else {
 		r1 = ret.Error(1)
 	}
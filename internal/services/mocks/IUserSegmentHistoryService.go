@@ -1,35 +1,70 @@
-// Code generated by mockery v2.49.2. DO NOT EDIT.
+// Code generated by mockery v2.53.5. DO NOT EDIT.
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+	io "io"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "API/internal/models"
+)
 
 // IUserSegmentHistoryService is an autogenerated mock type for the IUserSegmentHistoryService type
 type IUserSegmentHistoryService struct {
 	mock.Mock
 }
 
-// GenerateUserHistoryCSV provides a mock function with given fields: userID, date
-func (_m *IUserSegmentHistoryService) GenerateUserHistoryCSV(userID int64, date string) (string, error) {
-	ret := _m.Called(userID, date)
+// EnqueueUserHistoryCSV provides a mock function with given fields: ctx, userID, date
+func (_m *IUserSegmentHistoryService) EnqueueUserHistoryCSV(ctx context.Context, userID int64, date string) (models.Job, error) {
+	ret := _m.Called(ctx, userID, date)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnqueueUserHistoryCSV")
+	}
+
+	var r0 models.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) (models.Job, error)); ok {
+		return rf(ctx, userID, date)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) models.Job); ok {
+		r0 = rf(ctx, userID, date)
+	} else {
+		r0 = ret.Get(0).(models.Job)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string) error); ok {
+		r1 = rf(ctx, userID, date)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GenerateUserHistoryCSV provides a mock function with given fields: ctx, userID, date
+func (_m *IUserSegmentHistoryService) GenerateUserHistoryCSV(ctx context.Context, userID int64, date string) (models.HistoryReportResult, error) {
+	ret := _m.Called(ctx, userID, date)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GenerateUserHistoryCSV")
 	}
 
-	var r0 string
+	var r0 models.HistoryReportResult
 	var r1 error
-	if rf, ok := ret.Get(0).(func(int64, string) (string, error)); ok {
-		return rf(userID, date)
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) (models.HistoryReportResult, error)); ok {
+		return rf(ctx, userID, date)
 	}
-	if rf, ok := ret.Get(0).(func(int64, string) string); ok {
-		r0 = rf(userID, date)
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) models.HistoryReportResult); ok {
+		r0 = rf(ctx, userID, date)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(models.HistoryReportResult)
 	}
 
-	if rf, ok := ret.Get(1).(func(int64, string) error); ok {
-		r1 = rf(userID, date)
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string) error); ok {
+		r1 = rf(ctx, userID, date)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -37,6 +72,24 @@ func (_m *IUserSegmentHistoryService) GenerateUserHistoryCSV(userID int64, date
 	return r0, r1
 }
 
+// WriteUserHistoryCSV provides a mock function with given fields: ctx, userID, date, w
+func (_m *IUserSegmentHistoryService) WriteUserHistoryCSV(ctx context.Context, userID int64, date string, w io.Writer) error {
+	ret := _m.Called(ctx, userID, date, w)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WriteUserHistoryCSV")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, io.Writer) error); ok {
+		r0 = rf(ctx, userID, date, w)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewIUserSegmentHistoryService creates a new instance of IUserSegmentHistoryService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewIUserSegmentHistoryService(t interface {
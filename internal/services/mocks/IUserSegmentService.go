@@ -1,9 +1,10 @@
-// Code generated by mockery v2.49.2. DO NOT EDIT.
+// Code generated by mockery v2.53.5. DO NOT EDIT.
 
 package mocks
 
 import (
 	models "API/internal/models"
+	context "context"
 
 	mock "github.com/stretchr/testify/mock"
 
@@ -15,17 +16,45 @@ type IUserSegmentService struct {
 	mock.Mock
 }
 
-// DeleteUserSegment provides a mock function with given fields: userID, slug
-func (_m *IUserSegmentService) DeleteUserSegment(userID int64, slug models.Slug) error {
-	ret := _m.Called(userID, slug)
+// CountSegmentUsers provides a mock function with given fields: ctx, slug
+func (_m *IUserSegmentService) CountSegmentUsers(ctx context.Context, slug models.Slug) (models.SegmentUsers, error) {
+	ret := _m.Called(ctx, slug)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountSegmentUsers")
+	}
+
+	var r0 models.SegmentUsers
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) (models.SegmentUsers, error)); ok {
+		return rf(ctx, slug)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug) models.SegmentUsers); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Get(0).(models.SegmentUsers)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Slug) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteUserSegment provides a mock function with given fields: ctx, userID, slug
+func (_m *IUserSegmentService) DeleteUserSegment(ctx context.Context, userID int64, slug models.Slug) error {
+	ret := _m.Called(ctx, userID, slug)
 
 	if len(ret) == 0 {
 		panic("no return value specified for DeleteUserSegment")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(int64, models.Slug) error); ok {
-		r0 = rf(userID, slug)
+	if rf, ok := ret.Get(0).(func(context.Context, int64, models.Slug) error); ok {
+		r0 = rf(ctx, userID, slug)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -33,9 +62,9 @@ func (_m *IUserSegmentService) DeleteUserSegment(userID int64, slug models.Slug)
 	return r0
 }
 
-// GetAllUserSegments provides a mock function with no fields
-func (_m *IUserSegmentService) GetAllUserSegments() ([]models.UserSegment, error) {
-	ret := _m.Called()
+// GetAllUserSegments provides a mock function with given fields: ctx
+func (_m *IUserSegmentService) GetAllUserSegments(ctx context.Context) ([]models.UserSegment, error) {
+	ret := _m.Called(ctx)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetAllUserSegments")
@@ -43,19 +72,47 @@ func (_m *IUserSegmentService) GetAllUserSegments() ([]models.UserSegment, error
 
 	var r0 []models.UserSegment
 	var r1 error
-	if rf, ok := ret.Get(0).(func() ([]models.UserSegment, error)); ok {
-		return rf()
+	if rf, ok := ret.Get(0).(func(context.Context) ([]models.UserSegment, error)); ok {
+		return rf(ctx)
 	}
-	if rf, ok := ret.Get(0).(func() []models.UserSegment); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(context.Context) []models.UserSegment); ok {
+		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.UserSegment)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSegmentUsers provides a mock function with given fields: ctx, slug, cursor, limit, includeTTL
+func (_m *IUserSegmentService) GetSegmentUsers(ctx context.Context, slug models.Slug, cursor int64, limit int, includeTTL bool) (models.SegmentUsers, error) {
+	ret := _m.Called(ctx, slug, cursor, limit, includeTTL)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSegmentUsers")
+	}
+
+	var r0 models.SegmentUsers
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug, int64, int, bool) (models.SegmentUsers, error)); ok {
+		return rf(ctx, slug, cursor, limit, includeTTL)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.Slug, int64, int, bool) models.SegmentUsers); ok {
+		r0 = rf(ctx, slug, cursor, limit, includeTTL)
+	} else {
+		r0 = ret.Get(0).(models.SegmentUsers)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.Slug, int64, int, bool) error); ok {
+		r1 = rf(ctx, slug, cursor, limit, includeTTL)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -63,9 +120,9 @@ func (_m *IUserSegmentService) GetAllUserSegments() ([]models.UserSegment, error
 	return r0, r1
 }
 
-// GetUserSegments provides a mock function with given fields: userID
-func (_m *IUserSegmentService) GetUserSegments(userID int64) (models.UserSegments, error) {
-	ret := _m.Called(userID)
+// GetUserSegments provides a mock function with given fields: ctx, userID
+func (_m *IUserSegmentService) GetUserSegments(ctx context.Context, userID int64) (models.UserSegments, error) {
+	ret := _m.Called(ctx, userID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetUserSegments")
@@ -73,17 +130,17 @@ func (_m *IUserSegmentService) GetUserSegments(userID int64) (models.UserSegment
 
 	var r0 models.UserSegments
 	var r1 error
-	if rf, ok := ret.Get(0).(func(int64) (models.UserSegments, error)); ok {
-		return rf(userID)
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (models.UserSegments, error)); ok {
+		return rf(ctx, userID)
 	}
-	if rf, ok := ret.Get(0).(func(int64) models.UserSegments); ok {
-		r0 = rf(userID)
+	if rf, ok := ret.Get(0).(func(context.Context, int64) models.UserSegments); ok {
+		r0 = rf(ctx, userID)
 	} else {
 		r0 = ret.Get(0).(models.UserSegments)
 	}
 
-	if rf, ok := ret.Get(1).(func(int64) error); ok {
-		r1 = rf(userID)
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, userID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -91,22 +148,32 @@ func (_m *IUserSegmentService) GetUserSegments(userID int64) (models.UserSegment
 	return r0, r1
 }
 
-// UpdateUserSegments provides a mock function with given fields: userID, slugsToAdd, slugsToDelete, ttl
-func (_m *IUserSegmentService) UpdateUserSegments(userID int64, slugsToAdd []models.Slug, slugsToDelete []models.Slug, ttl *time.Time) error {
-	ret := _m.Called(userID, slugsToAdd, slugsToDelete, ttl)
+// UpdateUserSegments provides a mock function with given fields: ctx, userID, slugsToAdd, slugsToDelete, ttl, mode
+func (_m *IUserSegmentService) UpdateUserSegments(ctx context.Context, userID int64, slugsToAdd []models.Slug, slugsToDelete []models.Slug, ttl *time.Time, mode models.UpdateMode) (models.UpdateSegmentsResult, error) {
+	ret := _m.Called(ctx, userID, slugsToAdd, slugsToDelete, ttl, mode)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateUserSegments")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(int64, []models.Slug, []models.Slug, *time.Time) error); ok {
-		r0 = rf(userID, slugsToAdd, slugsToDelete, ttl)
+	var r0 models.UpdateSegmentsResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, []models.Slug, []models.Slug, *time.Time, models.UpdateMode) (models.UpdateSegmentsResult, error)); ok {
+		return rf(ctx, userID, slugsToAdd, slugsToDelete, ttl, mode)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, []models.Slug, []models.Slug, *time.Time, models.UpdateMode) models.UpdateSegmentsResult); ok {
+		r0 = rf(ctx, userID, slugsToAdd, slugsToDelete, ttl, mode)
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(models.UpdateSegmentsResult)
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, int64, []models.Slug, []models.Slug, *time.Time, models.UpdateMode) error); ok {
+		r1 = rf(ctx, userID, slugsToAdd, slugsToDelete, ttl, mode)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // NewIUserSegmentService creates a new instance of IUserSegmentService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
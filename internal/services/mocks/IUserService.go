@@ -1,9 +1,10 @@
-// Code generated by mockery v2.49.2. DO NOT EDIT.
+// Code generated by mockery v2.53.5. DO NOT EDIT.
 
 package mocks
 
 import (
 	models "API/internal/models"
+	context "context"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -13,17 +14,17 @@ type IUserService struct {
 	mock.Mock
 }
 
-// CreateUser provides a mock function with given fields: user
-func (_m *IUserService) CreateUser(user *models.Users) error {
-	ret := _m.Called(user)
+// CreateUser provides a mock function with given fields: ctx, user
+func (_m *IUserService) CreateUser(ctx context.Context, user *models.Users) error {
+	ret := _m.Called(ctx, user)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateUser")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*models.Users) error); ok {
-		r0 = rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Users) error); ok {
+		r0 = rf(ctx, user)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -31,17 +32,17 @@ func (_m *IUserService) CreateUser(user *models.Users) error {
 	return r0
 }
 
-// DeleteUser provides a mock function with given fields: userID
-func (_m *IUserService) DeleteUser(userID int64) error {
-	ret := _m.Called(userID)
+// DeleteUser provides a mock function with given fields: ctx, userID
+func (_m *IUserService) DeleteUser(ctx context.Context, userID int64) error {
+	ret := _m.Called(ctx, userID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for DeleteUser")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(int64) error); ok {
-		r0 = rf(userID)
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, userID)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -49,9 +50,9 @@ func (_m *IUserService) DeleteUser(userID int64) error {
 	return r0
 }
 
-// GetAllUsers provides a mock function with no fields
-func (_m *IUserService) GetAllUsers() ([]models.Users, error) {
-	ret := _m.Called()
+// GetAllUsers provides a mock function with given fields: ctx
+func (_m *IUserService) GetAllUsers(ctx context.Context) ([]models.Users, error) {
+	ret := _m.Called(ctx)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetAllUsers")
@@ -59,19 +60,19 @@ func (_m *IUserService) GetAllUsers() ([]models.Users, error) {
 
 	var r0 []models.Users
 	var r1 error
-	if rf, ok := ret.Get(0).(func() ([]models.Users, error)); ok {
-		return rf()
+	if rf, ok := ret.Get(0).(func(context.Context) ([]models.Users, error)); ok {
+		return rf(ctx)
 	}
-	if rf, ok := ret.Get(0).(func() []models.Users); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(context.Context) []models.Users); ok {
+		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.Users)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
 	} else {
 		r1 = ret.Error(1)
 	}
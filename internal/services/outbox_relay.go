@@ -0,0 +1,115 @@
+package services
+
+import (
+	"API/internal/kafka"
+	"API/internal/logging"
+	"API/internal/models"
+	"API/internal/repository"
+	"API/internal/tracing"
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+const outboxCleanupInterval = time.Minute
+
+// OutboxRelay publishes events stored in the outbox table to Kafka in the
+// order they were written. A failed publish is retried with exponential
+// backoff up to MaxBackoff; events stay in the outbox until Kafka accepts them.
+type OutboxRelay struct {
+	Repo       repository.OutboxRepository
+	Producer   *kafka.Producer
+	Interval   time.Duration
+	MaxBackoff time.Duration
+	Retention  time.Duration
+	BatchSize  int
+	Logger     *slog.Logger
+}
+
+func NewOutboxRelay(repo repository.OutboxRepository, producer *kafka.Producer, interval, maxBackoff, retention time.Duration, batchSize int, logger *slog.Logger) *OutboxRelay {
+	return &OutboxRelay{
+		Repo:       repo,
+		Producer:   producer,
+		Interval:   interval,
+		MaxBackoff: maxBackoff,
+		Retention:  retention,
+		BatchSize:  batchSize,
+		Logger:     logger,
+	}
+}
+
+// Run relays outbox events until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	r.Logger.Info("Started outbox relay", "interval", r.Interval, "batch_size", r.BatchSize)
+
+	delay := r.Interval
+	var lastCleanup time.Time
+	for {
+		if _, err := r.Relay(ctx); err != nil {
+			delay = min(delay*2, r.MaxBackoff)
+			r.Logger.Error("Outbox relay failed", "retry_in", delay, "error", err)
+		} else {
+			delay = r.Interval
+		}
+
+		if time.Since(lastCleanup) >= outboxCleanupInterval {
+			if _, err := r.Repo.DeleteSentBefore(ctx, time.Now().Add(-r.Retention)); err != nil {
+				r.Logger.Error("Outbox cleanup failed", "error", err)
+			}
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Relay publishes pending events batch by batch until the outbox is drained
+// or a publish fails, and returns the number of published events.
+func (r *OutboxRelay) Relay(ctx context.Context) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		sent, err := r.Repo.PublishPending(ctx, r.BatchSize, func(event models.OutboxEvent) error {
+			return r.publish(ctx, event)
+		})
+		total += sent
+		if err != nil {
+			return total, err
+		}
+		if sent < r.BatchSize {
+			break
+		}
+	}
+	return total, nil
+}
+
+// publish sends event with its stored headers. The publish span continues the
+// trace of the change that wrote the event and is logged with its request ID.
+func (r *OutboxRelay) publish(ctx context.Context, event models.OutboxEvent) error {
+	msg := &sarama.ProducerMessage{
+		Topic: event.Topic,
+		Key:   sarama.StringEncoder(event.Key),
+		Value: sarama.ByteEncoder(event.Payload),
+	}
+
+	keys := make([]string, 0, len(event.Headers))
+	for key := range event.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(event.Headers[key])})
+	}
+
+	ctx = tracing.Extract(ctx, event.Headers)
+	if requestID := event.Headers[logging.RequestIDKey]; requestID != "" {
+		ctx = logging.WithRequestID(ctx, requestID)
+	}
+	return r.Producer.Send(ctx, msg)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"API/internal/storage"
+	"API/internal/tracing"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// ReportCleaner periodically deletes stored reports older than Retention.
+type ReportCleaner struct {
+	Storage   storage.ReportStorage
+	Interval  time.Duration
+	Retention time.Duration
+	Logger    *slog.Logger
+}
+
+func NewReportCleaner(reports storage.ReportStorage, interval, retention time.Duration, logger *slog.Logger) *ReportCleaner {
+	return &ReportCleaner{
+		Storage:   reports,
+		Interval:  interval,
+		Retention: retention,
+		Logger:    logger,
+	}
+}
+
+// Run deletes expired reports every Interval until ctx is cancelled.
+func (c *ReportCleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	c.Logger.Info("Started report cleaner", "interval", c.Interval, "retention", c.Retention)
+
+	for {
+		if deleted, err := c.Clean(ctx); err != nil {
+			c.Logger.Error("Report cleanup failed", "error", err)
+		} else if deleted > 0 {
+			c.Logger.Info("Expired reports deleted", "deleted", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Clean deletes the reports stored before now minus Retention and returns
+// how many were deleted.
+func (c *ReportCleaner) Clean(ctx context.Context) (int, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "report cleanup")
+	defer span.End()
+
+	return c.Storage.DeleteBefore(ctx, time.Now().Add(-c.Retention))
+}
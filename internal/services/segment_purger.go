@@ -0,0 +1,73 @@
+package services
+
+import (
+	"API/internal/repository"
+	"API/internal/tracing"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SegmentPurger periodically deletes segments that have been archived for
+// longer than Retention, together with their memberships.
+type SegmentPurger struct {
+	Repo      repository.SegmentRepository
+	Interval  time.Duration
+	Retention time.Duration
+	BatchSize int
+	Logger    *slog.Logger
+}
+
+func NewSegmentPurger(repo repository.SegmentRepository, interval, retention time.Duration, batchSize int, logger *slog.Logger) *SegmentPurger {
+	return &SegmentPurger{
+		Repo:      repo,
+		Interval:  interval,
+		Retention: retention,
+		BatchSize: batchSize,
+		Logger:    logger,
+	}
+}
+
+// Run purges archived segments every Interval until ctx is cancelled.
+func (p *SegmentPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	p.Logger.Info("Started archived segment purger", "interval", p.Interval, "retention", p.Retention)
+
+	for {
+		if purged, err := p.Purge(ctx); err != nil {
+			p.Logger.Error("Archived segment purge failed", "error", err)
+		} else if purged > 0 {
+			p.Logger.Info("Archived segments purged", "purged", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes segments archived before now minus Retention batch by batch
+// until a batch comes back incomplete, and returns the number of deleted
+// segments.
+func (p *SegmentPurger) Purge(ctx context.Context) (int, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "segment purge")
+	defer span.End()
+
+	before := time.Now().Add(-p.Retention)
+	purged := 0
+	for ctx.Err() == nil {
+		slugs, err := p.Repo.PurgeArchivedSegments(ctx, before, p.BatchSize)
+		purged += len(slugs)
+		if err != nil {
+			return purged, err
+		}
+		if len(slugs) < p.BatchSize {
+			break
+		}
+	}
+	return purged, nil
+}
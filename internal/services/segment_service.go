@@ -1,44 +1,222 @@
-package services
-
-import (
-	"API/internal/models"
-	"API/internal/repository"
-	"fmt"
-)
-
-//go:generate mockery --name=ISegmentService --output=mocks --outpkg=mocks
-type ISegmentService interface {
-	GetAllSegments() ([]models.Segments, error)
-	CreateSegment(slug models.Slug) error
-	DeleteSegment(slug models.Slug) error
-}
-
-type SegmentService struct {
-	Repo repository.SegmentRepository
-}
-
-func NewSegmentService(repo repository.SegmentRepository) *SegmentService {
-	return &SegmentService{Repo: repo}
-}
-
-func (s *SegmentService) GetAllSegments() ([]models.Segments, error) {
-	segments, err := s.Repo.SelectAllSegmentsDB()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get all segments: %w", err)
-	}
-	return segments, nil
-}
-
-func (s *SegmentService) CreateSegment(slug models.Slug) error {
-	if err := s.Repo.CreateSegmentDB(slug); err != nil {
-		return fmt.Errorf("failed to create segment: %w", err)
-	}
-	return nil
-}
-
-func (s *SegmentService) DeleteSegment(slug models.Slug) error {
-	if err := s.Repo.DeleteSegmentDB(slug); err != nil {
-		return fmt.Errorf("failed to delete segment: %w", err)
-	}
-	return nil
-}
+package services
+
+import (
+	"API/internal/auth"
+	"API/internal/models"
+	"API/internal/repository"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+//go:generate mockery --name=ISegmentService --output=mocks --outpkg=mocks
+type ISegmentService interface {
+	GetAllSegments(ctx context.Context, filter models.SegmentFilter) ([]models.Segments, error)
+	GetSegment(ctx context.Context, slug models.Slug) (models.Segments, error)
+	UpdateSegment(ctx context.Context, slug models.Slug, patch models.SegmentPatch) (models.Segments, error)
+	CreateSegment(ctx context.Context, segment models.SegmentRequest) error
+	DeleteSegment(ctx context.Context, slug models.Slug) error
+	PurgeSegment(ctx context.Context, slug models.Slug) (models.Job, error)
+	RestoreSegment(ctx context.Context, slug models.Slug) error
+	GetSegmentACL(ctx context.Context, slug models.Slug) (models.SegmentACL, error)
+	SetSegmentACL(ctx context.Context, slug models.Slug, req models.SegmentACLRequest) (models.SegmentACL, error)
+}
+
+type SegmentService struct {
+	Repo   repository.SegmentRepository
+	Jobs   IJobQueue
+	Logger *slog.Logger
+}
+
+func NewSegmentService(repo repository.SegmentRepository, jobs IJobQueue, logger *slog.Logger) *SegmentService {
+	return &SegmentService{Repo: repo, Jobs: jobs, Logger: logger}
+}
+
+func (s *SegmentService) GetAllSegments(ctx context.Context, filter models.SegmentFilter) ([]models.Segments, error) {
+	segments, err := s.Repo.SelectAllSegmentsDB(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all segments: %w", err)
+	}
+	return segments, nil
+}
+
+func (s *SegmentService) GetSegment(ctx context.Context, slug models.Slug) (models.Segments, error) {
+	return s.Repo.GetSegmentDB(ctx, slug)
+}
+
+// UpdateSegment changes the metadata of the segment if the calling principal
+// manages it.
+func (s *SegmentService) UpdateSegment(ctx context.Context, slug models.Slug, patch models.SegmentPatch) (models.Segments, error) {
+	if err := authorizeSegments(ctx, s.Repo, []models.Slug{slug}, models.SegmentACL.CanManage); err != nil {
+		return models.Segments{}, err
+	}
+
+	segment, err := s.Repo.GetSegmentDB(ctx, slug)
+	if err != nil {
+		return models.Segments{}, err
+	}
+	if err := patch.Apply(&segment); err != nil {
+		return models.Segments{}, err
+	}
+	return s.Repo.UpdateSegmentDB(ctx, segment)
+}
+
+// CreateSegment creates the segment owned by segment.Owner or, when it is not
+// set, by the calling principal.
+func (s *SegmentService) CreateSegment(ctx context.Context, segment models.SegmentRequest) error {
+	owner := segment.Owner
+	if principal, ok := auth.PrincipalFrom(ctx); ok && owner == nil {
+		owner = &principal.Subject
+	}
+
+	created := models.Segments{
+		Slug:         segment.Slug,
+		AutoPercent:  segment.AutoPercent,
+		Owner:        owner,
+		Description:  segment.Description,
+		Tags:         segment.Tags,
+		ActiveFrom:   segment.ActiveFrom,
+		ActiveUntil:  segment.ActiveUntil,
+		ExpiresAt:    segment.ExpiresAt,
+		ExpiryAction: segment.ExpiryAction,
+	}
+	if err := created.Validate(); err != nil {
+		return err
+	}
+
+	enrolled, err := s.Repo.CreateSegmentDB(ctx, created)
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+
+	if segment.AutoPercent != nil {
+		s.Logger.InfoContext(ctx, "Segment created, users enrolled automatically", "slug", segment.Slug, "enrolled", enrolled)
+	}
+	return nil
+}
+
+// DeleteSegment archives the segment if the calling principal manages it.
+// Its memberships are kept until the segment is restored or purged.
+func (s *SegmentService) DeleteSegment(ctx context.Context, slug models.Slug) error {
+	if err := authorizeSegments(ctx, s.Repo, []models.Slug{slug}, models.SegmentACL.CanManage); err != nil {
+		return err
+	}
+	return s.archive(ctx, slug)
+}
+
+func (s *SegmentService) archive(ctx context.Context, slug models.Slug) error {
+	if err := s.Repo.ArchiveSegmentDB(ctx, slug); err != nil {
+		return fmt.Errorf("failed to delete segment: %w", err)
+	}
+	s.Logger.InfoContext(ctx, "Segment archived", "slug", slug)
+	return nil
+}
+
+// PurgeSegment queues a segment_purge job that deletes the segment with its
+// memberships at once, instead of after the retention, if the calling
+// principal manages it. An active segment is archived first; an archived one
+// is purged as it is.
+func (s *SegmentService) PurgeSegment(ctx context.Context, slug models.Slug) (models.Job, error) {
+	if err := authorizeSegments(ctx, s.Repo, []models.Slug{slug}, models.SegmentACL.CanManage); err != nil {
+		return models.Job{}, err
+	}
+	refs, err := s.Repo.LookupSegments(ctx, []models.Slug{slug})
+	if err != nil {
+		return models.Job{}, err
+	}
+	if len(refs) == 0 {
+		return models.Job{}, fmt.Errorf("%w: %s", models.ErrSegmentNotFound, slug)
+	}
+	if !refs[0].Archived {
+		if err := s.archive(ctx, slug); err != nil {
+			return models.Job{}, err
+		}
+	}
+	return s.Jobs.Enqueue(ctx, models.JobSegmentPurge, models.SegmentPurgePayload{Slug: slug})
+}
+
+// ProcessSegmentPurge is the JobHandler of segment_purge jobs. A segment
+// restored before the job runs is kept.
+func (s *SegmentService) ProcessSegmentPurge(ctx context.Context, job models.Job, _ func(float64) error) (any, error) {
+	var payload models.SegmentPurgePayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	removed, err := s.Repo.PurgeSegmentDB(ctx, payload.Slug)
+	if err != nil {
+		return nil, err
+	}
+	return models.SegmentPurgeResult{Slug: payload.Slug, Removed: removed}, nil
+}
+
+// RestoreSegment brings back an archived segment if the calling principal
+// manages it.
+func (s *SegmentService) RestoreSegment(ctx context.Context, slug models.Slug) error {
+	if err := authorizeSegments(ctx, s.Repo, []models.Slug{slug}, models.SegmentACL.CanManage); err != nil {
+		return err
+	}
+	if err := s.Repo.RestoreSegmentDB(ctx, slug); err != nil {
+		return fmt.Errorf("failed to restore segment: %w", err)
+	}
+	s.Logger.InfoContext(ctx, "Segment restored", "slug", slug)
+	return nil
+}
+
+func (s *SegmentService) GetSegmentACL(ctx context.Context, slug models.Slug) (models.SegmentACL, error) {
+	acls, err := s.Repo.GetSegmentACLs(ctx, []models.Slug{slug})
+	if err != nil {
+		return models.SegmentACL{}, err
+	}
+	if len(acls) == 0 {
+		return models.SegmentACL{}, fmt.Errorf("%w: %s", models.ErrSegmentNotFound, slug)
+	}
+	return acls[0], nil
+}
+
+// SetSegmentACL replaces the owner and writers of the segment if the calling
+// principal manages it.
+func (s *SegmentService) SetSegmentACL(ctx context.Context, slug models.Slug, req models.SegmentACLRequest) (models.SegmentACL, error) {
+	current, err := s.GetSegmentACL(ctx, slug)
+	if err != nil {
+		return models.SegmentACL{}, err
+	}
+	if principal, ok := auth.PrincipalFrom(ctx); ok && !current.CanManage(principal) {
+		return models.SegmentACL{}, fmt.Errorf("%w: %s may not change segment %s", models.ErrForbidden, principal.Subject, slug)
+	}
+
+	acl := models.SegmentACL{
+		Slug:    slug,
+		Owner:   req.Owner,
+		Writers: req.Writers,
+	}
+	if acl.Writers == nil {
+		acl.Writers = []string{}
+	}
+	if err := s.Repo.SetSegmentACL(ctx, acl); err != nil {
+		return models.SegmentACL{}, err
+	}
+	return acl, nil
+}
+
+// authorizeSegments checks allowed against the ACL of every existing segment
+// among slugs. Changes without an authenticated principal, such as Kafka
+// commands and background jobs, are not restricted.
+func authorizeSegments(ctx context.Context, repo repository.SegmentRepository, slugs []models.Slug, allowed func(models.SegmentACL, models.Principal) bool) error {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok || len(slugs) == 0 {
+		return nil
+	}
+
+	acls, err := repo.GetSegmentACLs(ctx, slugs)
+	if err != nil {
+		return err
+	}
+	for _, acl := range acls {
+		if !allowed(acl, principal) {
+			return fmt.Errorf("%w: %s may not change segment %s", models.ErrForbidden, principal.Subject, acl.Slug)
+		}
+	}
+	return nil
+}
@@ -1,86 +1,973 @@
-package services_test
-
-import (
-	"API/internal/models"
-	"API/internal/repository/mocks"
-	"API/internal/services"
-	"errors"
-	"testing"
-
-	"github.com/stretchr/testify/assert"
-)
-
-func TestUserService_GetAllUsers(t *testing.T) {
-	mockRepo := new(mocks.UserRepository)
-	userService := services.NewUserService(mockRepo)
-
-	expectedUsers := []models.Users{
-		{ID: 1, Name: "Alice"},
-		{ID: 2, Name: "Bob"},
-	}
-
-	mockRepo.On("GetAllUsersDB").Return(expectedUsers, nil)
-
-	users, err := userService.GetAllUsers()
-
-	assert.NoError(t, err)
-	assert.Equal(t, expectedUsers, users)
-
-	mockRepo.AssertExpectations(t)
-}
-
-func TestUserService_CreateUser(t *testing.T) {
-	mockRepo := new(mocks.UserRepository)
-	userService := services.NewUserService(mockRepo)
-
-	newUser := &models.Users{ID: 3, Name: "Charlie"}
-
-	mockRepo.On("CreateUserDB", newUser).Return(nil)
-
-	err := userService.CreateUser(newUser)
-
-	assert.NoError(t, err)
-	mockRepo.AssertCalled(t, "CreateUserDB", newUser)
-}
-
-func TestUserService_CreateUser_Error(t *testing.T) {
-	mockRepo := new(mocks.UserRepository)
-	userService := services.NewUserService(mockRepo)
-
-	newUser := &models.Users{ID: 3, Name: "Charlie"}
-
-	mockRepo.On("CreateUserDB", newUser).Return(errors.New("failed to create user"))
-
-	err := userService.CreateUser(newUser)
-
-	assert.Error(t, err)
-	mockRepo.AssertCalled(t, "CreateUserDB", newUser)
-}
-
-func TestUserService_DeleteUser(t *testing.T) {
-	mockRepo := new(mocks.UserRepository)
-	userService := services.NewUserService(mockRepo)
-
-	userID := int64(1)
-
-	mockRepo.On("DeleteUserDB", userID).Return(nil)
-
-	err := userService.DeleteUser(userID)
-
-	assert.NoError(t, err)
-	mockRepo.AssertCalled(t, "DeleteUserDB", userID)
-}
-
-func TestUserService_DeleteUser_Error(t *testing.T) {
-	mockRepo := new(mocks.UserRepository)
-	userService := services.NewUserService(mockRepo)
-
-	userID := int64(1)
-
-	mockRepo.On("DeleteUserDB", userID).Return(errors.New("failed to delete user"))
-
-	err := userService.DeleteUser(userID)
-
-	assert.Error(t, err)
-	mockRepo.AssertCalled(t, "DeleteUserDB", userID)
-}
+package services_test
+
+import (
+	"API/internal/audit"
+	"API/internal/auth"
+	"API/internal/config"
+	"API/internal/logging"
+	"API/internal/models"
+	"API/internal/repository"
+	"API/internal/repository/mocks"
+	"API/internal/services"
+	serviceMocks "API/internal/services/mocks"
+	"API/internal/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUserService_GetAllUsers(t *testing.T) {
+	mockRepo := new(mocks.UserRepository)
+	userService := services.NewUserService(mockRepo, nil)
+
+	expectedUsers := []models.Users{
+		{ID: 1, Name: "Alice"},
+		{ID: 2, Name: "Bob"},
+	}
+
+	mockRepo.On("GetAllUsersDB", mock.Anything, mock.Anything).Return(expectedUsers, nil)
+
+	users, err := userService.GetAllUsers(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedUsers, users)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserService_CreateUser(t *testing.T) {
+	mockRepo := new(mocks.UserRepository)
+	mockUserSegmentRepo := new(mocks.UserSegmentRepository)
+	userService := services.NewUserService(mockRepo, mockUserSegmentRepo)
+
+	newUser := &models.Users{ID: 3, Name: "Charlie"}
+
+	mockUserSegmentRepo.On("CreateAutoEnrolledUser", mock.Anything, newUser).Return([]models.Slug{"DISCOUNT_30"}, nil)
+
+	err := userService.CreateUser(context.Background(), newUser)
+
+	assert.NoError(t, err)
+	mockUserSegmentRepo.AssertCalled(t, "CreateAutoEnrolledUser", mock.Anything, newUser)
+	mockRepo.AssertNotCalled(t, "CreateUserDB", mock.Anything, mock.Anything)
+}
+
+func TestUserService_CreateUser_Error(t *testing.T) {
+	mockRepo := new(mocks.UserRepository)
+	mockUserSegmentRepo := new(mocks.UserSegmentRepository)
+	userService := services.NewUserService(mockRepo, mockUserSegmentRepo)
+
+	newUser := &models.Users{ID: 3, Name: "Charlie"}
+
+	mockUserSegmentRepo.On("CreateAutoEnrolledUser", mock.Anything, newUser).Return(nil, errors.New("database error"))
+
+	err := userService.CreateUser(context.Background(), newUser)
+
+	assert.Error(t, err)
+	mockUserSegmentRepo.AssertCalled(t, "CreateAutoEnrolledUser", mock.Anything, newUser)
+}
+
+func TestUserService_DeleteUser(t *testing.T) {
+	mockRepo := new(mocks.UserRepository)
+	userService := services.NewUserService(mockRepo, nil)
+
+	userID := int64(1)
+
+	mockRepo.On("DeleteUserDB", mock.Anything, userID).Return(nil)
+
+	err := userService.DeleteUser(context.Background(), userID)
+
+	assert.NoError(t, err)
+	mockRepo.AssertCalled(t, "DeleteUserDB", mock.Anything, userID)
+}
+
+func TestUserService_DeleteUser_Error(t *testing.T) {
+	mockRepo := new(mocks.UserRepository)
+	userService := services.NewUserService(mockRepo, nil)
+
+	userID := int64(1)
+
+	mockRepo.On("DeleteUserDB", mock.Anything, userID).Return(errors.New("failed to delete user"))
+
+	err := userService.DeleteUser(context.Background(), userID)
+
+	assert.Error(t, err)
+	mockRepo.AssertCalled(t, "DeleteUserDB", mock.Anything, userID)
+}
+
+func TestExpiryWorker_Sweep_NothingExpired(t *testing.T) {
+	mockRepo := new(mocks.UserSegmentRepository)
+	worker := services.NewExpiryWorker(mockRepo, nil, time.Minute, 100, logging.Nop())
+
+	mockRepo.On("DeleteExpiredUserSegments", mock.Anything, 100).Return([]models.UserSegment{}, nil).Once()
+
+	removed, err := worker.Sweep(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExpiryWorker_Sweep_Error(t *testing.T) {
+	mockRepo := new(mocks.UserSegmentRepository)
+	worker := services.NewExpiryWorker(mockRepo, nil, time.Minute, 100, logging.Nop())
+
+	mockRepo.On("DeleteExpiredUserSegments", mock.Anything, 100).Return(nil, errors.New("database error")).Once()
+
+	removed, err := worker.Sweep(context.Background())
+
+	assert.EqualError(t, err, "database error")
+	assert.Equal(t, 0, removed)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExpiryWorker_SweepSegments(t *testing.T) {
+	mockSegments := new(mocks.SegmentRepository)
+	worker := services.NewExpiryWorker(nil, mockSegments, time.Minute, 100, logging.Nop())
+
+	mockSegments.On("ExpireSegments", mock.Anything, 10).Return([]models.Slug{"BLACK_FRIDAY"}, nil).Once()
+
+	expired, err := worker.SweepSegments(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	mockSegments.AssertExpectations(t)
+}
+
+func TestSegmentPurger_Purge(t *testing.T) {
+	mockRepo := new(mocks.SegmentRepository)
+	purger := services.NewSegmentPurger(mockRepo, time.Hour, 24*time.Hour, 2, logging.Nop())
+
+	archivedBefore := mock.MatchedBy(func(before time.Time) bool {
+		return time.Since(before) >= 24*time.Hour
+	})
+	mockRepo.On("PurgeArchivedSegments", mock.Anything, archivedBefore, 2).Return([]models.Slug{"VIDEO", "MUSIC"}, nil).Once()
+	mockRepo.On("PurgeArchivedSegments", mock.Anything, archivedBefore, 2).Return([]models.Slug{"BOOKS"}, nil).Once()
+
+	purged, err := purger.Purge(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, purged)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserSegmentService_ProcessCommandMessage(t *testing.T) {
+	commandMessage := func(value string) *sarama.ConsumerMessage {
+		return &sarama.ConsumerMessage{Topic: "user-segment-commands", Value: []byte(value)}
+	}
+	replyWithStatus := func(status string) interface{} {
+		return mock.MatchedBy(func(events []models.OutboxEvent) bool {
+			var reply models.CommandReply
+			if len(events) != 1 || json.Unmarshal(events[0].Payload, &reply) != nil {
+				return false
+			}
+			return events[0].Topic == "user-segment-replies" && events[0].Key == "req-1" && reply.Status == status
+		})
+	}
+
+	t.Run("should apply command and reply ok", func(t *testing.T) {
+		mockRepo := new(mocks.UserSegmentRepository)
+		mockOutbox := new(mocks.EventQueue)
+		service := services.NewUserSegmentService(mockRepo, nil, mockOutbox, "user-segment-replies", logging.Nop())
+
+		fromKafka := mock.MatchedBy(func(ctx context.Context) bool {
+			return audit.Source(ctx) == models.SourceKafka && audit.Reason(ctx) == "promo" && logging.RequestID(ctx) == "req-1"
+		})
+		mockRepo.On("UpdateUserSegments", fromKafka, []models.Slug{"DISCOUNT_30"}, []models.Slug(nil), int64(1000), (*time.Time)(nil), models.ModeStrict).
+			Return(models.UpdateSegmentsResult{Applied: true}, nil).Once()
+		mockOutbox.On("Enqueue", mock.Anything, replyWithStatus(models.CommandStatusOK)).Return(nil).Once()
+
+		err := service.ProcessCommandMessage(context.Background(), commandMessage(`{"request_id":"req-1","user_id":1000,"action":"add","segment":"DISCOUNT_30","reason":"promo"}`))
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+		mockOutbox.AssertExpectations(t)
+	})
+
+	t.Run("should reply error for unknown user", func(t *testing.T) {
+		mockRepo := new(mocks.UserSegmentRepository)
+		mockOutbox := new(mocks.EventQueue)
+		service := services.NewUserSegmentService(mockRepo, nil, mockOutbox, "user-segment-replies", logging.Nop())
+
+		mockRepo.On("UpdateUserSegments", mock.Anything, []models.Slug(nil), []models.Slug{"VIDEO"}, int64(1000), (*time.Time)(nil), models.ModeStrict).
+			Return(models.UpdateSegmentsResult{}, fmt.Errorf("%w: %d", models.ErrUserNotFound, 1000)).Once()
+		mockOutbox.On("Enqueue", mock.Anything, replyWithStatus(models.CommandStatusError)).Return(nil).Once()
+
+		err := service.ProcessCommandMessage(context.Background(), commandMessage(`{"request_id":"req-1","user_id":1000,"delete_segments":["VIDEO"]}`))
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+		mockOutbox.AssertExpectations(t)
+	})
+
+	t.Run("should return error to retry when database fails", func(t *testing.T) {
+		mockRepo := new(mocks.UserSegmentRepository)
+		mockOutbox := new(mocks.EventQueue)
+		service := services.NewUserSegmentService(mockRepo, nil, mockOutbox, "user-segment-replies", logging.Nop())
+
+		mockRepo.On("UpdateUserSegments", mock.Anything, []models.Slug{"VIDEO"}, []models.Slug(nil), int64(1000), (*time.Time)(nil), models.ModeStrict).
+			Return(models.UpdateSegmentsResult{}, errors.New("database error")).Once()
+
+		err := service.ProcessCommandMessage(context.Background(), commandMessage(`{"request_id":"req-1","user_id":1000,"action":"add","segment":"VIDEO"}`))
+
+		assert.EqualError(t, err, "database error")
+		mockOutbox.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should reply error for unknown action", func(t *testing.T) {
+		mockRepo := new(mocks.UserSegmentRepository)
+		mockOutbox := new(mocks.EventQueue)
+		service := services.NewUserSegmentService(mockRepo, nil, mockOutbox, "user-segment-replies", logging.Nop())
+
+		mockOutbox.On("Enqueue", mock.Anything, replyWithStatus(models.CommandStatusError)).Return(nil).Once()
+
+		err := service.ProcessCommandMessage(context.Background(), commandMessage(`{"request_id":"req-1","user_id":1000,"action":"move","segment":"VIDEO"}`))
+
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "UpdateUserSegments", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockOutbox.AssertExpectations(t)
+	})
+
+	t.Run("should reply rejected outcomes in strict mode", func(t *testing.T) {
+		mockRepo := new(mocks.UserSegmentRepository)
+		mockOutbox := new(mocks.EventQueue)
+		service := services.NewUserSegmentService(mockRepo, nil, mockOutbox, "user-segment-replies", logging.Nop())
+
+		rejected := models.UpdateSegmentsResult{
+			UserID:  1000,
+			Mode:    models.ModeStrict,
+			Results: []models.SlugOutcome{{Slug: "OLD", Action: models.ActionAdd, Outcome: models.OutcomeArchived}},
+		}
+		mockRepo.On("UpdateUserSegments", mock.Anything, []models.Slug{"OLD"}, []models.Slug(nil), int64(1000), (*time.Time)(nil), models.ModeStrict).
+			Return(rejected, models.ErrMembershipRejected).Once()
+		mockOutbox.On("Enqueue", mock.Anything, mock.MatchedBy(func(events []models.OutboxEvent) bool {
+			var reply models.CommandReply
+			return len(events) == 1 && json.Unmarshal(events[0].Payload, &reply) == nil &&
+				reply.Status == models.CommandStatusError && len(reply.Results) == 1 && reply.Results[0].Outcome == models.OutcomeArchived
+		})).Return(nil).Once()
+
+		err := service.ProcessCommandMessage(context.Background(), commandMessage(`{"request_id":"req-1","user_id":1000,"action":"add","segment":"OLD"}`))
+
+		assert.NoError(t, err)
+		mockOutbox.AssertExpectations(t)
+	})
+}
+
+func TestHealthService_Readiness(t *testing.T) {
+	t.Run("should be up when all dependencies are up", func(t *testing.T) {
+		db, producer, consumer := new(serviceMocks.Pinger), new(serviceMocks.Pinger), new(serviceMocks.LagReporter)
+		service := services.NewHealthService(db, producer, consumer, time.Second, 100)
+
+		db.On("Ping", mock.Anything).Return(nil)
+		producer.On("Ping", mock.Anything).Return(nil)
+		consumer.On("Ping", mock.Anything).Return(nil)
+		consumer.On("Lag", mock.Anything).Return(int64(7), nil)
+
+		report := service.Readiness(context.Background())
+
+		assert.Equal(t, models.HealthStatusUp, report.Status)
+		assert.Len(t, report.Dependencies, 3)
+		assert.Equal(t, int64(7), *report.Dependencies["kafka_consumer"].Lag)
+	})
+
+	t.Run("should be down when a dependency fails", func(t *testing.T) {
+		db, producer, consumer := new(serviceMocks.Pinger), new(serviceMocks.Pinger), new(serviceMocks.LagReporter)
+		service := services.NewHealthService(db, producer, consumer, time.Second, 100)
+
+		db.On("Ping", mock.Anything).Return(errors.New("connection refused"))
+		producer.On("Ping", mock.Anything).Return(nil)
+		consumer.On("Ping", mock.Anything).Return(nil)
+		consumer.On("Lag", mock.Anything).Return(int64(101), nil)
+
+		report := service.Readiness(context.Background())
+
+		assert.Equal(t, models.HealthStatusDown, report.Status)
+		assert.Equal(t, "connection refused", report.Dependencies["postgres"].Error)
+		assert.Equal(t, models.HealthStatusUp, report.Dependencies["kafka_producer"].Status)
+		assert.Equal(t, "consumer lag 101 exceeds 100", report.Dependencies["kafka_consumer"].Error)
+	})
+
+	t.Run("should be down while shutting down", func(t *testing.T) {
+		db, producer, consumer := new(serviceMocks.Pinger), new(serviceMocks.Pinger), new(serviceMocks.LagReporter)
+		service := services.NewHealthService(db, producer, consumer, time.Second, 0)
+
+		service.SetShuttingDown()
+		report := service.Readiness(context.Background())
+
+		assert.Equal(t, models.HealthStatusDown, report.Status)
+		db.AssertNotCalled(t, "Ping", mock.Anything)
+	})
+}
+
+func TestAuthService_AuthenticateAPIKey(t *testing.T) {
+	verifier, err := auth.NewJWTVerifier(config.JWTConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create JWT verifier: %v", err)
+	}
+
+	t.Run("should return the principal of a stored key", func(t *testing.T) {
+		keys := new(mocks.APIKeyRepository)
+		service := services.NewAuthService(keys, verifier, "")
+
+		keys.On("AuthenticateAPIKey", mock.Anything, auth.HashAPIKey("ugk_secret")).
+			Return(models.APIKey{Name: "billing", Scopes: []models.Scope{models.ScopeMembershipsWrite}}, nil)
+
+		principal, err := service.AuthenticateToken(context.Background(), "ugk_secret")
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.Principal{
+			Subject: "billing",
+			Method:  models.AuthMethodAPIKey,
+			Scopes:  []models.Scope{models.ScopeMembershipsWrite},
+		}, principal)
+	})
+
+	t.Run("should reject an unknown key", func(t *testing.T) {
+		keys := new(mocks.APIKeyRepository)
+		service := services.NewAuthService(keys, verifier, "")
+
+		keys.On("AuthenticateAPIKey", mock.Anything, mock.Anything).Return(models.APIKey{}, models.ErrAPIKeyNotFound)
+
+		_, err := service.AuthenticateAPIKey(context.Background(), "ugk_unknown")
+
+		assert.ErrorIs(t, err, models.ErrUnauthenticated)
+	})
+
+	t.Run("should grant every scope to the bootstrap key", func(t *testing.T) {
+		keys := new(mocks.APIKeyRepository)
+		service := services.NewAuthService(keys, verifier, "bootstrap-secret")
+
+		principal, err := service.AuthenticateAPIKey(context.Background(), "bootstrap-secret")
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.Scopes, principal.Scopes)
+		keys.AssertNotCalled(t, "AuthenticateAPIKey", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should reject a JWT when JWT is not configured", func(t *testing.T) {
+		service := services.NewAuthService(new(mocks.APIKeyRepository), verifier, "")
+
+		_, err := service.AuthenticateToken(context.Background(), "eyJhbGciOiJIUzI1NiJ9.e30.sig")
+
+		assert.ErrorIs(t, err, models.ErrUnauthenticated)
+	})
+}
+
+func TestAuthService_CreateAPIKey(t *testing.T) {
+	keys := new(mocks.APIKeyRepository)
+	service := services.NewAuthService(keys, nil, "")
+
+	var storedHash string
+	keys.On("CreateAPIKey", mock.Anything, mock.AnythingOfType("*models.APIKey"), mock.AnythingOfType("string")).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*models.APIKey).ID = 3
+			storedHash = args.String(2)
+		}).
+		Return(nil)
+
+	created, err := service.CreateAPIKey(context.Background(), models.CreateAPIKeyRequest{
+		Name:   "billing",
+		Scopes: []models.Scope{models.ScopeReportsRead},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), created.ID)
+	assert.True(t, auth.IsAPIKey(created.Key))
+	assert.Equal(t, auth.HashAPIKey(created.Key), storedHash)
+	assert.Equal(t, created.Key[:len(created.Prefix)], created.Prefix)
+}
+
+func TestUserSegmentService_UpdateUserSegments_ACL(t *testing.T) {
+	owner := "growth"
+	acls := []models.SegmentACL{{Slug: "DISCOUNT_30", Owner: &owner, Writers: []string{"billing"}}}
+
+	t.Run("should reject a principal that may not write the segment", func(t *testing.T) {
+		mockRepo, mockSegments := new(mocks.UserSegmentRepository), new(mocks.SegmentRepository)
+		service := services.NewUserSegmentService(mockRepo, mockSegments, nil, "", logging.Nop())
+		ctx := auth.WithPrincipal(context.Background(), models.Principal{Subject: "crm"})
+
+		mockSegments.On("GetSegmentACLs", ctx, []models.Slug{"DISCOUNT_30"}).Return(acls, nil)
+
+		_, err := service.UpdateUserSegments(ctx, 1000, []models.Slug{"DISCOUNT_30"}, nil, nil, models.ModeStrict)
+
+		assert.ErrorIs(t, err, models.ErrForbidden)
+		mockRepo.AssertNotCalled(t, "UpdateUserSegments", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should apply the change of a writer", func(t *testing.T) {
+		mockRepo, mockSegments := new(mocks.UserSegmentRepository), new(mocks.SegmentRepository)
+		service := services.NewUserSegmentService(mockRepo, mockSegments, nil, "", logging.Nop())
+		ctx := auth.WithPrincipal(context.Background(), models.Principal{Subject: "billing"})
+
+		mockSegments.On("GetSegmentACLs", ctx, []models.Slug{"VIDEO", "DISCOUNT_30"}).Return(acls, nil)
+		mockRepo.On("UpdateUserSegments", ctx, []models.Slug{"VIDEO"}, []models.Slug{"DISCOUNT_30"}, int64(1000), (*time.Time)(nil), models.ModeStrict).
+			Return(models.UpdateSegmentsResult{Applied: true}, nil)
+
+		_, err := service.UpdateUserSegments(ctx, 1000, []models.Slug{"VIDEO"}, []models.Slug{"DISCOUNT_30"}, nil, models.ModeStrict)
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestSegmentService_CreateSegment(t *testing.T) {
+	expiresAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should create the segment with its expiry", func(t *testing.T) {
+		mockSegments := new(mocks.SegmentRepository)
+		service := services.NewSegmentService(mockSegments, nil, logging.Nop())
+
+		mockSegments.On("CreateSegmentDB", mock.Anything, mock.MatchedBy(func(segment models.Segments) bool {
+			return segment.ExpiresAt != nil && segment.ExpiresAt.Equal(expiresAt) && segment.ExpiryAction == models.SegmentExpiryDelete
+		})).Return(int64(0), nil).Once()
+
+		err := service.CreateSegment(context.Background(), models.SegmentRequest{
+			Slug: "PROMO", ExpiresAt: &expiresAt, ExpiryAction: models.SegmentExpiryDelete,
+		})
+
+		assert.NoError(t, err)
+		mockSegments.AssertExpectations(t)
+	})
+
+	t.Run("should archive on expiry by default", func(t *testing.T) {
+		mockSegments := new(mocks.SegmentRepository)
+		service := services.NewSegmentService(mockSegments, nil, logging.Nop())
+
+		mockSegments.On("CreateSegmentDB", mock.Anything, mock.MatchedBy(func(segment models.Segments) bool {
+			return segment.ExpiresAt != nil && segment.ExpiryAction == models.SegmentExpiryArchive
+		})).Return(int64(0), nil).Once()
+
+		err := service.CreateSegment(context.Background(), models.SegmentRequest{Slug: "PROMO", ExpiresAt: &expiresAt})
+
+		assert.NoError(t, err)
+		mockSegments.AssertExpectations(t)
+	})
+
+	t.Run("should reject an unknown expiry action", func(t *testing.T) {
+		mockSegments := new(mocks.SegmentRepository)
+		service := services.NewSegmentService(mockSegments, nil, logging.Nop())
+
+		err := service.CreateSegment(context.Background(), models.SegmentRequest{
+			Slug: "PROMO", ExpiresAt: &expiresAt, ExpiryAction: "forget",
+		})
+
+		assert.ErrorIs(t, err, models.ErrInvalidSegment)
+		mockSegments.AssertNotCalled(t, "CreateSegmentDB", mock.Anything, mock.Anything)
+	})
+}
+
+func TestSegmentService_DeleteSegment_ACL(t *testing.T) {
+	owner := "growth"
+	acls := []models.SegmentACL{{Slug: "DISCOUNT_30", Owner: &owner, Writers: []string{"billing"}}}
+
+	mockSegments := new(mocks.SegmentRepository)
+	service := services.NewSegmentService(mockSegments, nil, logging.Nop())
+	ctx := auth.WithPrincipal(context.Background(), models.Principal{Subject: "billing"})
+
+	mockSegments.On("GetSegmentACLs", ctx, []models.Slug{"DISCOUNT_30"}).Return(acls, nil)
+
+	err := service.DeleteSegment(ctx, "DISCOUNT_30")
+
+	assert.ErrorIs(t, err, models.ErrForbidden)
+	mockSegments.AssertNotCalled(t, "ArchiveSegmentDB", mock.Anything, mock.Anything)
+}
+
+func TestSegmentService_PurgeSegment(t *testing.T) {
+	ctx := context.Background()
+	queued := models.Job{ID: 43, Kind: models.JobSegmentPurge, Status: models.JobQueued}
+
+	t.Run("should archive an active segment and queue its purge", func(t *testing.T) {
+		mockSegments, mockJobs := new(mocks.SegmentRepository), new(serviceMocks.IJobQueue)
+		service := services.NewSegmentService(mockSegments, mockJobs, logging.Nop())
+
+		mockSegments.On("LookupSegments", ctx, []models.Slug{"VIDEO"}).Return([]models.SegmentRef{{ID: 2, Slug: "VIDEO"}}, nil)
+		mockSegments.On("ArchiveSegmentDB", ctx, models.Slug("VIDEO")).Return(nil)
+		mockJobs.On("Enqueue", ctx, models.JobSegmentPurge, models.SegmentPurgePayload{Slug: "VIDEO"}).Return(queued, nil)
+
+		job, err := service.PurgeSegment(ctx, "VIDEO")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(43), job.ID)
+		mockSegments.AssertExpectations(t)
+		mockJobs.AssertExpectations(t)
+	})
+
+	t.Run("should queue the purge of an archived segment", func(t *testing.T) {
+		mockSegments, mockJobs := new(mocks.SegmentRepository), new(serviceMocks.IJobQueue)
+		service := services.NewSegmentService(mockSegments, mockJobs, logging.Nop())
+
+		mockSegments.On("LookupSegments", ctx, []models.Slug{"VIDEO"}).
+			Return([]models.SegmentRef{{ID: 2, Slug: "VIDEO", Archived: true}}, nil)
+		mockJobs.On("Enqueue", ctx, models.JobSegmentPurge, models.SegmentPurgePayload{Slug: "VIDEO"}).Return(queued, nil)
+
+		job, err := service.PurgeSegment(ctx, "VIDEO")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(43), job.ID)
+		mockSegments.AssertNotCalled(t, "ArchiveSegmentDB", mock.Anything, mock.Anything)
+		mockJobs.AssertExpectations(t)
+	})
+
+	t.Run("should refuse a principal that does not manage the archived segment", func(t *testing.T) {
+		mockSegments, mockJobs := new(mocks.SegmentRepository), new(serviceMocks.IJobQueue)
+		service := services.NewSegmentService(mockSegments, mockJobs, logging.Nop())
+		owner := "growth"
+		billing := auth.WithPrincipal(ctx, models.Principal{Subject: "billing"})
+
+		mockSegments.On("GetSegmentACLs", billing, []models.Slug{"VIDEO"}).
+			Return([]models.SegmentACL{{Slug: "VIDEO", Owner: &owner}}, nil)
+
+		_, err := service.PurgeSegment(billing, "VIDEO")
+
+		assert.ErrorIs(t, err, models.ErrForbidden)
+		mockJobs.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should return not found for an unknown segment", func(t *testing.T) {
+		mockSegments, mockJobs := new(mocks.SegmentRepository), new(serviceMocks.IJobQueue)
+		service := services.NewSegmentService(mockSegments, mockJobs, logging.Nop())
+
+		mockSegments.On("LookupSegments", ctx, []models.Slug{"VIDEO"}).Return([]models.SegmentRef{}, nil)
+
+		_, err := service.PurgeSegment(ctx, "VIDEO")
+
+		assert.ErrorIs(t, err, models.ErrSegmentNotFound)
+		mockJobs.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestBulkMembershipService_SubmitBulkJob(t *testing.T) {
+	t.Run("should reject archived segments", func(t *testing.T) {
+		mockBulk, mockSegments, mockJobs := new(mocks.BulkJobRepository), new(mocks.SegmentRepository), new(serviceMocks.IJobQueue)
+		service := services.NewBulkMembershipService(mockBulk, mockSegments, mockJobs, 1000, 10, logging.Nop())
+
+		mockSegments.On("LookupSegments", mock.Anything, []models.Slug{"VIDEO", "OLD"}).
+			Return([]models.SegmentRef{{ID: 1, Slug: "VIDEO"}, {ID: 2, Slug: "OLD", Archived: true}}, nil)
+
+		req := models.BulkMembershipRequest{UserIDs: []int64{1000}, AddSegments: []models.Slug{"VIDEO", "OLD"}}
+		_, err := service.SubmitBulkJob(context.Background(), req, models.NewUserIDRows(req.UserIDs))
+
+		assert.ErrorIs(t, err, models.ErrMembershipRejected)
+		mockBulk.AssertNotCalled(t, "CreateBulkJob", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockJobs.AssertNotCalled(t, "Wake")
+	})
+
+	t.Run("should store the job of the caller and queue it", func(t *testing.T) {
+		mockBulk, mockSegments, mockJobs := new(mocks.BulkJobRepository), new(mocks.SegmentRepository), new(serviceMocks.IJobQueue)
+		service := services.NewBulkMembershipService(mockBulk, mockSegments, mockJobs, 1000, 10, logging.Nop())
+		ctx := logging.WithRequestID(context.Background(), "req-1")
+		queued := models.Job{Kind: models.JobBulkMembership, MaxAttempts: 3, RequestID: "req-1"}
+
+		mockSegments.On("LookupSegments", ctx, []models.Slug{"VIDEO"}).Return([]models.SegmentRef{{ID: 1, Slug: "VIDEO"}}, nil)
+		mockJobs.On("NewJob", ctx, models.JobBulkMembership, json.RawMessage(nil)).Return(queued)
+		mockBulk.On("CreateBulkJob", ctx, mock.MatchedBy(func(job models.BulkJob) bool {
+			return job.RequestID == "req-1" && job.Reason == "campaign"
+		}), queued, mock.Anything).Return(models.BulkJob{ID: 7, JobID: 43, Status: models.BulkJobPending}, nil)
+		mockJobs.On("Wake").Return().Once()
+
+		req := models.BulkMembershipRequest{UserIDs: []int64{1000}, DeleteSegments: []models.Slug{"VIDEO"}, Reason: "campaign"}
+		job, err := service.SubmitBulkJob(ctx, req, models.NewUserIDRows(req.UserIDs))
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), job.ID)
+		assert.Equal(t, int64(43), job.JobID)
+		mockBulk.AssertExpectations(t)
+		mockJobs.AssertExpectations(t)
+	})
+}
+
+func TestBulkMembershipService_GetBulkJob(t *testing.T) {
+	mockBulk := new(mocks.BulkJobRepository)
+	service := services.NewBulkMembershipService(mockBulk, nil, nil, 2, 10, logging.Nop())
+	mockBulk.On("GetBulkJob", mock.Anything, int64(7)).Return(models.BulkJob{ID: 7, Actor: "billing"}, nil)
+
+	t.Run("should refuse the bulk job of another principal", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), models.Principal{Subject: "crm"})
+
+		_, err := service.GetBulkJob(ctx, 7)
+		assert.ErrorIs(t, err, models.ErrForbidden)
+
+		err = service.BulkJobErrors(ctx, 7, func(models.BulkRow) error { return nil })
+		assert.ErrorIs(t, err, models.ErrForbidden)
+		mockBulk.AssertNotCalled(t, "BulkJobErrors", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should return the bulk job to its submitter and admins", func(t *testing.T) {
+		for _, principal := range []models.Principal{
+			{Subject: "billing"},
+			{Subject: "ops", Scopes: []models.Scope{models.ScopeAdmin}},
+		} {
+			job, err := service.GetBulkJob(auth.WithPrincipal(context.Background(), principal), 7)
+
+			assert.NoError(t, err)
+			assert.Equal(t, int64(7), job.ID)
+		}
+	})
+}
+
+func TestBulkMembershipService_ProcessBulkJob(t *testing.T) {
+	job := models.Job{ID: 43, Kind: models.JobBulkMembership, Payload: json.RawMessage(`{"bulk_job_id":7}`), Attempts: 1, MaxAttempts: 3}
+
+	t.Run("should apply chunks until the bulk job is done", func(t *testing.T) {
+		mockBulk := new(mocks.BulkJobRepository)
+		service := services.NewBulkMembershipService(mockBulk, nil, nil, 2, 10, logging.Nop())
+
+		asBulk := mock.MatchedBy(func(ctx context.Context) bool {
+			return audit.Source(ctx) == models.SourceBulk && audit.Reason(ctx) == "campaign"
+		})
+		mockBulk.On("GetBulkJob", mock.Anything, int64(7)).
+			Return(models.BulkJob{ID: 7, Status: models.BulkJobPending, Reason: "campaign"}, nil)
+		mockBulk.On("ApplyBulkChunk", asBulk, int64(7), int64(2)).
+			Return(models.BulkJob{ID: 7, Status: models.BulkJobRunning, Progress: 50}, nil).Once()
+		mockBulk.On("ApplyBulkChunk", asBulk, int64(7), int64(2)).
+			Return(models.BulkJob{ID: 7, Status: models.BulkJobDone, Progress: 100}, nil).Once()
+
+		var reported []float64
+		result, err := service.ProcessBulkJob(context.Background(), job, func(percent float64) error {
+			reported = append(reported, percent)
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.BulkJobDone, result.(models.BulkJob).Status)
+		assert.Equal(t, []float64{0, 50}, reported)
+		mockBulk.AssertExpectations(t)
+	})
+
+	t.Run("should stop without touching the bulk job when the attempt has to stop", func(t *testing.T) {
+		mockBulk := new(mocks.BulkJobRepository)
+		service := services.NewBulkMembershipService(mockBulk, nil, nil, 2, 10, logging.Nop())
+
+		mockBulk.On("GetBulkJob", mock.Anything, int64(7)).Return(models.BulkJob{ID: 7, Status: models.BulkJobRunning}, nil)
+
+		_, err := service.ProcessBulkJob(context.Background(), job, func(float64) error { return models.ErrJobCancelled })
+
+		assert.ErrorIs(t, err, models.ErrJobCancelled)
+		mockBulk.AssertNotCalled(t, "ApplyBulkChunk", mock.Anything, mock.Anything, mock.Anything)
+		mockBulk.AssertNotCalled(t, "FailBulkJob", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestBulkMembershipService_FailBulkJob(t *testing.T) {
+	mockBulk := new(mocks.BulkJobRepository)
+	service := services.NewBulkMembershipService(mockBulk, nil, nil, 2, 10, logging.Nop())
+	job := models.Job{ID: 43, Kind: models.JobBulkMembership, Payload: json.RawMessage(`{"bulk_job_id":7}`)}
+
+	mockBulk.On("FailBulkJob", mock.Anything, int64(7), "database error").Return(nil).Once()
+
+	err := service.FailBulkJob(context.Background(), job, errors.New("database error"))
+
+	assert.NoError(t, err)
+	mockBulk.AssertExpectations(t)
+}
+
+func TestJobQueue_RunNext(t *testing.T) {
+	newQueue := func(lease time.Duration) (*services.JobQueue, *mocks.JobStore) {
+		store := new(mocks.JobStore)
+		return services.NewJobQueue(store, "replica-1", 1, time.Second, lease, 10*time.Second, 3, time.Hour, logging.Nop()), store
+	}
+	claimed := models.Job{ID: 43, Kind: models.JobHistoryReport, Status: models.JobRunning, Attempts: 1, MaxAttempts: 3,
+		Actor: "billing", RequestID: "req-1"}
+	failing := func(context.Context, models.Job, func(float64) error) (any, error) {
+		return nil, errors.New("disk full")
+	}
+
+	t.Run("should run the job as its submitter and store the result", func(t *testing.T) {
+		queue, store := newQueue(time.Minute)
+		queue.Register(models.JobHistoryReport, func(ctx context.Context, job models.Job, _ func(float64) error) (any, error) {
+			if audit.Actor(ctx) != "billing" || logging.RequestID(ctx) != "req-1" {
+				return nil, errors.New("job does not run as its submitter")
+			}
+			return models.HistoryReportResult{File: "report.csv"}, nil
+		})
+
+		store.On("ClaimJob", mock.Anything, "replica-1", []string{models.JobHistoryReport}, time.Minute).Return(claimed, true, nil)
+		store.On("CompleteJob", mock.Anything, int64(43), "replica-1", mock.MatchedBy(func(result []byte) bool {
+			return string(result) == `{"file":"report.csv"}`
+		})).Return(nil).Once()
+
+		ran, err := queue.RunNext(context.Background())
+
+		assert.NoError(t, err)
+		assert.True(t, ran)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("should retry a failed attempt with backoff", func(t *testing.T) {
+		queue, store := newQueue(time.Minute)
+		queue.Register(models.JobHistoryReport, failing)
+
+		second := claimed
+		second.Attempts = 2
+		store.On("ClaimJob", mock.Anything, "replica-1", mock.Anything, time.Minute).Return(second, true, nil)
+		store.On("RetryJob", mock.Anything, int64(43), "replica-1", "disk full", 20*time.Second).Return(nil).Once()
+
+		_, err := queue.RunNext(context.Background())
+
+		assert.NoError(t, err)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("should cap the backoff of late attempts", func(t *testing.T) {
+		queue, store := newQueue(time.Minute)
+		queue.Register(models.JobHistoryReport, failing)
+
+		late := claimed
+		late.Attempts, late.MaxAttempts = 70, 100
+		store.On("ClaimJob", mock.Anything, "replica-1", mock.Anything, time.Minute).Return(late, true, nil)
+		store.On("RetryJob", mock.Anything, int64(43), "replica-1", "disk full", 24*time.Hour).Return(nil).Once()
+
+		_, err := queue.RunNext(context.Background())
+
+		assert.NoError(t, err)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("should fail the job after the last attempt", func(t *testing.T) {
+		queue, store := newQueue(time.Minute)
+		queue.Register(models.JobHistoryReport, failing)
+
+		last := claimed
+		last.Attempts = 3
+		store.On("ClaimJob", mock.Anything, "replica-1", mock.Anything, time.Minute).Return(last, true, nil)
+		store.On("FailJob", mock.Anything, int64(43), "replica-1", "disk full").Return(nil).Once()
+
+		_, err := queue.RunNext(context.Background())
+
+		assert.NoError(t, err)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("should stop a running job that is cancelled", func(t *testing.T) {
+		queue, store := newQueue(30 * time.Millisecond)
+		queue.Register(models.JobHistoryReport, func(_ context.Context, _ models.Job, progress func(float64) error) (any, error) {
+			for {
+				if err := progress(50); err != nil {
+					return nil, err
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+
+		store.On("ClaimJob", mock.Anything, "replica-1", mock.Anything, 30*time.Millisecond).Return(claimed, true, nil)
+		store.On("HeartbeatJob", mock.Anything, int64(43), "replica-1", 50.0, 30*time.Millisecond).Return(true, nil).Once()
+		store.On("MarkJobCancelled", mock.Anything, int64(43), "replica-1").Return(nil).Once()
+
+		_, err := queue.RunNext(context.Background())
+
+		assert.NoError(t, err)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("should fail the bulk job when the job is cancelled while a chunk is applied", func(t *testing.T) {
+		queue, store := newQueue(30 * time.Millisecond)
+		mockBulk := new(mocks.BulkJobRepository)
+		bulk := services.NewBulkMembershipService(mockBulk, nil, nil, 2, 10, logging.Nop())
+		queue.Register(models.JobBulkMembership, bulk.ProcessBulkJob)
+		queue.OnFailure(models.JobBulkMembership, bulk.FailBulkJob)
+
+		job := claimed
+		job.Kind = models.JobBulkMembership
+		job.Payload = json.RawMessage(`{"bulk_job_id":7}`)
+		store.On("ClaimJob", mock.Anything, "replica-1", mock.Anything, 30*time.Millisecond).Return(job, true, nil)
+		store.On("HeartbeatJob", mock.Anything, int64(43), "replica-1", 0.0, 30*time.Millisecond).Return(true, nil).Once()
+		store.On("MarkJobCancelled", mock.Anything, int64(43), "replica-1").Return(nil).Once()
+		mockBulk.On("GetBulkJob", mock.Anything, int64(7)).Return(models.BulkJob{ID: 7, Status: models.BulkJobRunning}, nil)
+		mockBulk.On("ApplyBulkChunk", mock.Anything, int64(7), int64(2)).
+			Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+			Return(models.BulkJob{}, context.Canceled).Once()
+		mockBulk.On("FailBulkJob", mock.Anything, int64(7), "job cancelled").Return(nil).Once()
+
+		_, err := queue.RunNext(context.Background())
+
+		assert.NoError(t, err)
+		store.AssertExpectations(t)
+		mockBulk.AssertExpectations(t)
+	})
+
+	t.Run("should run the failure hook of a job that ran out of attempts", func(t *testing.T) {
+		queue, store := newQueue(time.Minute)
+		queue.Register(models.JobHistoryReport, failing)
+		var reasons []string
+		queue.OnFailure(models.JobHistoryReport, func(_ context.Context, job models.Job, reason error) error {
+			reasons = append(reasons, reason.Error())
+			return nil
+		})
+
+		abandoned := claimed
+		abandoned.Attempts = 4
+		store.On("ClaimJob", mock.Anything, "replica-1", mock.Anything, time.Minute).Return(abandoned, true, nil)
+		store.On("FailJob", mock.Anything, int64(43), "replica-1", "the worker of the last attempt stopped").Return(nil).Once()
+
+		_, err := queue.RunNext(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"the worker of the last attempt stopped"}, reasons)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("should run the job with the teams and scopes of its submitter", func(t *testing.T) {
+		queue, store := newQueue(time.Minute)
+		var ran models.Principal
+		queue.Register(models.JobHistoryReport, func(ctx context.Context, _ models.Job, _ func(float64) error) (any, error) {
+			ran, _ = auth.PrincipalFrom(ctx)
+			return nil, nil
+		})
+
+		submitted := claimed
+		submitted.ActorTeams = []string{"growth"}
+		submitted.ActorScopes = []models.Scope{models.ScopeAdmin}
+		store.On("ClaimJob", mock.Anything, "replica-1", mock.Anything, time.Minute).Return(submitted, true, nil)
+		store.On("CompleteJob", mock.Anything, int64(43), "replica-1", mock.Anything).Return(nil).Once()
+
+		_, err := queue.RunNext(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.Principal{Subject: "billing", Teams: []string{"growth"}, Scopes: []models.Scope{models.ScopeAdmin}}, ran)
+		assert.True(t, ran.HasScope(models.ScopeAdmin))
+	})
+
+	t.Run("should report when no job is due", func(t *testing.T) {
+		queue, store := newQueue(time.Minute)
+		store.On("ClaimJob", mock.Anything, "replica-1", []string{}, time.Minute).Return(models.Job{}, false, nil)
+
+		ran, err := queue.RunNext(context.Background())
+
+		assert.NoError(t, err)
+		assert.False(t, ran)
+	})
+}
+
+func TestJobQueue_NewJob(t *testing.T) {
+	queue := services.NewJobQueue(new(mocks.JobStore), "replica-1", 1, time.Second, time.Minute, time.Second, 3, time.Hour, logging.Nop())
+	ctx := auth.WithPrincipal(context.Background(), models.Principal{Subject: "billing", Teams: []string{"growth"},
+		Scopes: []models.Scope{models.ScopeUsersWrite}})
+
+	job := queue.NewJob(ctx, models.JobHistoryReport, nil)
+
+	submitter, ok := job.Submitter()
+	assert.True(t, ok)
+	assert.Equal(t, "billing", submitter.Subject)
+	assert.Equal(t, []string{"growth"}, submitter.Teams)
+	assert.Equal(t, []models.Scope{models.ScopeUsersWrite}, submitter.Scopes)
+}
+
+func TestJobQueue_CancelJob(t *testing.T) {
+	store := new(mocks.JobStore)
+	queue := services.NewJobQueue(store, "replica-1", 1, time.Second, time.Minute, time.Second, 3, time.Hour, logging.Nop())
+	store.On("GetJob", mock.Anything, int64(43)).Return(models.Job{ID: 43, Status: models.JobRunning, Actor: "billing"}, nil)
+
+	t.Run("should refuse the job of another principal", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), models.Principal{Subject: "crm"})
+
+		_, err := queue.CancelJob(ctx, 43)
+
+		assert.ErrorIs(t, err, models.ErrForbidden)
+		store.AssertNotCalled(t, "CancelJob", mock.Anything, mock.Anything)
+	})
+
+	t.Run("should cancel the job of its submitter", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), models.Principal{Subject: "billing"})
+		store.On("CancelJob", ctx, int64(43)).
+			Return(models.Job{ID: 43, Status: models.JobRunning, CancelRequested: true}, nil).Once()
+
+		job, err := queue.CancelJob(ctx, 43)
+
+		assert.NoError(t, err)
+		assert.True(t, job.CancelRequested)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("should run the failure hook of a queued job", func(t *testing.T) {
+		queue := services.NewJobQueue(store, "replica-1", 1, time.Second, time.Minute, time.Second, 3, time.Hour, logging.Nop())
+		var settled []int64
+		queue.OnFailure(models.JobBulkMembership, func(_ context.Context, job models.Job, reason error) error {
+			if errors.Is(reason, models.ErrJobCancelled) {
+				settled = append(settled, job.ID)
+			}
+			return nil
+		})
+		store.On("CancelJob", mock.Anything, int64(43)).
+			Return(models.Job{ID: 43, Kind: models.JobBulkMembership, Status: models.JobCancelled, CancelRequested: true}, nil).Once()
+
+		_, err := queue.CancelJob(context.Background(), 43)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{43}, settled)
+	})
+}
+
+// historyRows is a UserSegmentHistoryRepository holding the history of one user.
+type historyRows []models.UserSegmentsHistory
+
+func (h historyRows) SaveHistoryEntries(context.Context, repository.DBTX, []models.UserSegmentsHistory) error {
+	return nil
+}
+
+func (h historyRows) EachUserHistory(_ context.Context, _ int64, _, _ time.Time, fn func(models.UserSegmentsHistory) error) error {
+	for _, row := range h {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestUserSegmentHistoryService_WriteUserHistoryCSV(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	service := services.NewUserSegmentHistoryService(historyRows{
+		{UserID: 1000, SegmentSlug: "VIDEO", OperationType: models.ADD, OperationDate: date,
+			Reason: "=HYPERLINK(\"http://evil\")", Source: models.SourceAPI, Actor: "@billing", RequestID: "-1+1"},
+		{UserID: 1000, SegmentSlug: "VIDEO", OperationType: models.DELETE, OperationDate: date,
+			Reason: "expired", Source: models.SourceTTL},
+	}, nil, nil)
+
+	var out strings.Builder
+	err := service.WriteUserHistoryCSV(context.Background(), 1000, "2024-05", &out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "UserID,SegmentSlug,OperationType,OperationDate,Reason,Source,Actor,RequestID\n"+
+		"1000,VIDEO,ADD,2024-05-01 12:00:00,\"'=HYPERLINK(\"\"http://evil\"\")\",api,'@billing,'-1+1\n"+
+		"1000,VIDEO,DELETE,2024-05-01 12:00:00,expired,ttl,,\n", out.String())
+
+	err = service.WriteUserHistoryCSV(context.Background(), 1000, "May", &out)
+	assert.ErrorIs(t, err, models.ErrInvalidReportDate)
+}
+
+func TestUserSegmentHistoryService_PresentHistoryReport(t *testing.T) {
+	reports := storage.NewLocalReportStorage(t.TempDir(), "/reports", storage.URLSigner{Key: []byte("secret"), TTL: time.Hour})
+	store := new(mocks.JobStore)
+	queue := services.NewJobQueue(store, "replica-1", 1, time.Second, time.Minute, time.Second, 3, time.Hour, logging.Nop())
+	service := services.NewUserSegmentHistoryService(historyRows{}, reports, queue)
+	queue.OnRead(models.JobHistoryReport, service.PresentHistoryReport)
+
+	t.Run("should link the report of a succeeded job as it is read", func(t *testing.T) {
+		store.On("GetJob", mock.Anything, int64(43)).Return(models.Job{ID: 43, Kind: models.JobHistoryReport,
+			Status: models.JobSucceeded, Result: json.RawMessage(`{"file":"report.csv"}`)}, nil).Once()
+
+		job, err := queue.GetJob(context.Background(), 43)
+
+		assert.NoError(t, err)
+		var result models.HistoryReportResult
+		assert.NoError(t, json.Unmarshal(job.Result, &result))
+		assert.Equal(t, "report.csv", result.File)
+		assert.True(t, strings.HasPrefix(result.URL, "/reports/report.csv?"), result.URL)
+	})
+
+	t.Run("should leave a job without result as is", func(t *testing.T) {
+		store.On("GetJob", mock.Anything, int64(44)).Return(models.Job{ID: 44, Kind: models.JobHistoryReport,
+			Status: models.JobRunning}, nil).Once()
+
+		job, err := queue.GetJob(context.Background(), 44)
+
+		assert.NoError(t, err)
+		assert.Empty(t, job.Result)
+	})
+}
@@ -1,64 +1,165 @@
-package services
-
-import (
-	"API/internal/repository"
-	"API/internal/utils"
-	"encoding/csv"
-	"fmt"
-	"os"
-)
-
-//go:generate mockery --name=IUserSegmentHistoryService --output=mocks --outpkg=mocks
-type IUserSegmentHistoryService interface {
-	GenerateUserHistoryCSV(userID int64, date string) (string, error)
-}
-
-type UserSegmentHistoryService struct {
-	Repository repository.UserSegmentHistoryRepository
-}
-
-func NewUserSegmentHistoryService(repo repository.UserSegmentHistoryRepository) *UserSegmentHistoryService {
-	return &UserSegmentHistoryService{Repository: repo}
-}
-
-func (s *UserSegmentHistoryService) GenerateUserHistoryCSV(userID int64, date string) (string, error) {
-	start, end, err := utils.ParseYearMonth(date)
-	if err != nil {
-		return "", fmt.Errorf("invalid date format: %w", err)
-	}
-
-	histories, err := s.Repository.GetUserHistory(userID, start, end)
-	if err != nil {
-		return "", fmt.Errorf("failed to retrieve user history: %w", err)
-	}
-
-	fileName := fmt.Sprintf("user_%d_history_%s.csv", userID, date)
-	filePath := fmt.Sprintf("csv_reports/%s", fileName)
-
-	file, err := os.Create(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create CSV file: %w", err)
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	if err := writer.Write([]string{"UserID", "SegmentSlug", "OperationType", "OperationDate"}); err != nil {
-		return "", fmt.Errorf("failed to write CSV header: %w", err)
-	}
-
-	for _, history := range histories {
-		record := []string{
-			fmt.Sprintf("%d", history.UserID),
-			string(history.SegmentSlug),
-			string(history.OperationType),
-			history.OperationDate.Format("2006-01-02 15:04:05"),
-		}
-		if err := writer.Write(record); err != nil {
-			return "", fmt.Errorf("failed to write CSV record: %w", err)
-		}
-	}
-
-	return fileName, nil
-}
+package services
+
+import (
+	"API/internal/models"
+	"API/internal/repository"
+	"API/internal/storage"
+	"API/internal/utils"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:generate mockery --name=IUserSegmentHistoryService --output=mocks --outpkg=mocks
+type IUserSegmentHistoryService interface {
+	WriteUserHistoryCSV(ctx context.Context, userID int64, date string, w io.Writer) error
+	GenerateUserHistoryCSV(ctx context.Context, userID int64, date string) (models.HistoryReportResult, error)
+	EnqueueUserHistoryCSV(ctx context.Context, userID int64, date string) (models.Job, error)
+}
+
+type UserSegmentHistoryService struct {
+	Repository repository.UserSegmentHistoryRepository
+	Storage    storage.ReportStorage
+	Jobs       IJobQueue
+}
+
+func NewUserSegmentHistoryService(repo repository.UserSegmentHistoryRepository, reports storage.ReportStorage, jobs IJobQueue) *UserSegmentHistoryService {
+	return &UserSegmentHistoryService{Repository: repo, Storage: reports, Jobs: jobs}
+}
+
+// EnqueueUserHistoryCSV queues a history_report job that generates the report
+// of GenerateUserHistoryCSV in the background.
+func (s *UserSegmentHistoryService) EnqueueUserHistoryCSV(ctx context.Context, userID int64, date string) (models.Job, error) {
+	if _, _, err := parseReportDate(date); err != nil {
+		return models.Job{}, err
+	}
+	return s.Jobs.Enqueue(ctx, models.JobHistoryReport, models.HistoryReportPayload{UserID: userID, Date: date})
+}
+
+// ProcessHistoryReport is the JobHandler of history_report jobs. The result
+// only names the report, as a link would expire long before the job is
+// deleted; PresentHistoryReport links it when the job is read.
+func (s *UserSegmentHistoryService) ProcessHistoryReport(ctx context.Context, job models.Job, _ func(float64) error) (any, error) {
+	var payload models.HistoryReportPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	fileName, err := s.saveUserHistoryCSV(ctx, payload.UserID, payload.Date)
+	if err != nil {
+		return nil, err
+	}
+	return models.HistoryReportResult{File: fileName}, nil
+}
+
+// PresentHistoryReport is the JobPresenter of history_report jobs. It adds a
+// download link to the result of a succeeded job, fresh on every read.
+func (s *UserSegmentHistoryService) PresentHistoryReport(ctx context.Context, job models.Job) (models.Job, error) {
+	if len(job.Result) == 0 {
+		return job, nil
+	}
+	var result models.HistoryReportResult
+	if err := json.Unmarshal(job.Result, &result); err != nil {
+		return models.Job{}, fmt.Errorf("invalid result of job %d: %w", job.ID, err)
+	}
+	url, err := s.Storage.URL(ctx, result.File)
+	if err != nil {
+		return models.Job{}, fmt.Errorf("failed to get report URL: %w", err)
+	}
+	result.URL = url
+	if job.Result, err = json.Marshal(result); err != nil {
+		return models.Job{}, fmt.Errorf("failed to encode result of job %d: %w", job.ID, err)
+	}
+	return job, nil
+}
+
+// GenerateUserHistoryCSV stores the history report of the user for the month
+// date in the report storage and returns where it can be downloaded.
+func (s *UserSegmentHistoryService) GenerateUserHistoryCSV(ctx context.Context, userID int64, date string) (models.HistoryReportResult, error) {
+	fileName, err := s.saveUserHistoryCSV(ctx, userID, date)
+	if err != nil {
+		return models.HistoryReportResult{}, err
+	}
+
+	url, err := s.Storage.URL(ctx, fileName)
+	if err != nil {
+		return models.HistoryReportResult{}, fmt.Errorf("failed to get report URL: %w", err)
+	}
+	return models.HistoryReportResult{File: fileName, URL: url}, nil
+}
+
+// saveUserHistoryCSV stores the history report of the user for the month
+// date in the report storage and returns its name.
+func (s *UserSegmentHistoryService) saveUserHistoryCSV(ctx context.Context, userID int64, date string) (string, error) {
+	if _, _, err := parseReportDate(date); err != nil {
+		return "", err
+	}
+
+	fileName := models.HistoryReportName(userID, date)
+	err := s.Storage.Save(ctx, fileName, func(w io.Writer) error {
+		return s.WriteUserHistoryCSV(ctx, userID, date, w)
+	})
+	if err != nil {
+		return "", err
+	}
+	return fileName, nil
+}
+
+// WriteUserHistoryCSV writes the history report of the user for the month
+// date to w row by row as it is read from the database. Nothing is written
+// when the date is invalid or the history cannot be queried.
+func (s *UserSegmentHistoryService) WriteUserHistoryCSV(ctx context.Context, userID int64, date string, w io.Writer) error {
+	start, end, err := parseReportDate(date)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"UserID", "SegmentSlug", "OperationType", "OperationDate", "Reason", "Source", "Actor", "RequestID"}); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	err = s.Repository.EachUserHistory(ctx, userID, start, end, func(history models.UserSegmentsHistory) error {
+		record := []string{
+			strconv.FormatInt(history.UserID, 10),
+			string(history.SegmentSlug),
+			string(history.OperationType),
+			history.OperationDate.Format("2006-01-02 15:04:05"),
+			csvText(history.Reason),
+			string(history.Source),
+			csvText(history.Actor),
+			csvText(history.RequestID),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user history: %w", err)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvText keeps free-form text from being read as a formula by spreadsheet
+// tools by prefixing cells that start with a formula character with a quote.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func parseReportDate(date string) (start, end time.Time, err error) {
+	start, end, err = utils.ParseYearMonth(date)
+	if err != nil {
+		return start, end, fmt.Errorf("%w: %v", models.ErrInvalidReportDate, err)
+	}
+	return start, end, nil
+}
@@ -1,195 +1,207 @@
-package services
-
-import (
-	"API/internal/kafka"
-	"API/internal/models"
-	"API/internal/repository"
-	"encoding/json"
-	"fmt"
-	"log"
-	"strconv"
-	"time"
-
-	"github.com/IBM/sarama"
-)
-
-//go:generate mockery --name=IUserSegmentService --output=mocks --outpkg=mocks
-type IUserSegmentService interface {
-	GetUserSegments(userID int64) (models.UserSegments, error)
-	GetAllUserSegments() ([]models.UserSegment, error)
-	UpdateUserSegments(userID int64, slugsToAdd, slugsToDelete []models.Slug, ttl *time.Time) error
-	DeleteUserSegment(userID int64, slug models.Slug) error
-}
-
-type UserSegmentService struct {
-	Repo        repository.UserSegmentRepository
-	HistoryRepo repository.UserSegmentHistoryRepository
-	Producer    *kafka.Producer
-}
-
-func NewUserSegmentService(repo repository.UserSegmentRepository, historyRepo repository.UserSegmentHistoryRepository, producer *kafka.Producer) *UserSegmentService {
-	return &UserSegmentService{
-		Repo:        repo,
-		HistoryRepo: historyRepo,
-		Producer:    producer,
-	}
-}
-
-func (s *UserSegmentService) GetUserSegments(userID int64) (models.UserSegments, error) {
-	return s.Repo.GetUserSegmentsDВ(userID)
-}
-
-func (s *UserSegmentService) GetAllUserSegments() ([]models.UserSegment, error) {
-	return s.Repo.GetAllUserSegmentsDB()
-}
-
-func (s *UserSegmentService) UpdateUserSegments(userID int64, slugsToAdd, slugsToDelete []models.Slug, ttl *time.Time) error {
-	err := s.Repo.UpdateUserSegments(slugsToAdd, slugsToDelete, userID, ttl)
-	if err != nil {
-		return err
-	}
-
-	for _, slug := range slugsToAdd {
-		event := map[string]interface{}{
-			"user_id": userID,
-			"segment": slug,
-			"action":  "add",
-		}
-		if ttl != nil {
-			event["ttl"] = ttl.Format(time.RFC3339)
-			err := s.Producer.SendMessage("segment_expiry", strconv.FormatInt(userID, 10), event)
-			if err != nil {
-				log.Printf("Failed to send TTL Kafka message: %v", err)
-				return err
-			}
-			log.Printf("Kafka message sent for TTL expiry: %v", event)
-		}
-		key := strconv.FormatInt(userID, 10)
-		err := s.Producer.SendMessage("user-segments", key, event)
-		if err != nil {
-			return err
-		}
-	}
-
-	for _, slug := range slugsToDelete {
-		event := map[string]interface{}{
-			"user_id": userID,
-			"segment": slug,
-			"action":  "delete",
-		}
-		key := strconv.FormatInt(userID, 10)
-		err := s.Producer.SendMessage("user-segments", key, event)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
-func (s *UserSegmentService) DeleteUserSegment(userID int64, slug models.Slug) error {
-	if err := s.Repo.DeleteUserSegment(userID, slug); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (s *UserSegmentService) ProcessKafkaMessage(message *sarama.ConsumerMessage) {
-	var event struct {
-		Action  string `json:"action"`
-		UserID  int64  `json:"user_id"`
-		Segment string `json:"segment"`
-		TTL     string `json:"ttl"`
-	}
-	if err := json.Unmarshal(message.Value, &event); err != nil {
-		log.Printf("Failed to parse Kafka message: %v", err)
-		return
-	}
-
-	log.Printf("Processing Kafka message: action=%s, userID=%d, segment=%s, ttl=%s", event.Action, event.UserID, event.Segment, event.TTL)
-
-	segmentSlug := models.Slug(event.Segment)
-
-	var ttl *time.Time
-	if event.TTL != "" {
-		parsedTTL, err := time.Parse(time.RFC3339, event.TTL)
-		if err != nil {
-			log.Printf("Invalid TTL format in Kafka message: %v", err)
-			return
-		}
-		ttl = &parsedTTL
-	}
-
-	switch event.Action {
-	case "add":
-		err := s.Repo.UpdateUserSegments([]models.Slug{segmentSlug}, nil, event.UserID, ttl)
-		if err != nil {
-			log.Printf("Failed to add user to segment: %v", err)
-			return
-		}
-
-		err = s.HistoryRepo.SaveHistoryEntry(models.UserSegmentsHistory{
-			UserID:        event.UserID,
-			SegmentSlug:   segmentSlug,
-			OperationType: models.ADD,
-			OperationDate: time.Now(),
-		})
-		if err != nil {
-			log.Printf("Failed to save add action to history: %v", err)
-			return
-		}
-
-		log.Printf("User %d successfully added to segment %s", event.UserID, event.Segment)
-
-	case "remove":
-		err := s.DeleteUserSegment(event.UserID, segmentSlug)
-		if err != nil {
-			log.Printf("Failed to delete user segment: %v", err)
-			return
-		}
-
-		err = s.HistoryRepo.SaveHistoryEntry(models.UserSegmentsHistory{
-			UserID:        event.UserID,
-			SegmentSlug:   segmentSlug,
-			OperationType: models.DELETE,
-			OperationDate: time.Now(),
-		})
-		if err != nil {
-			log.Printf("Failed to save delete action to history: %v", err)
-			return
-		}
-
-		log.Printf("User %d successfully removed from segment %s", event.UserID, event.Segment)
-
-	default:
-		log.Printf("Unknown action: %s", event.Action)
-	}
-}
-
-func (s *UserSegmentService) ProcessTTLExpiryMessage(msg *sarama.ConsumerMessage) error {
-	var event struct {
-		UserID   int64  `json:"user_id"`
-		Segment  string `json:"segment"`
-		ExpireAt string `json:"ttl"`
-	}
-
-	if err := json.Unmarshal(msg.Value, &event); err != nil {
-		return fmt.Errorf("failed to parse Kafka message: %v", err)
-	}
-
-	expireTime, err := time.Parse(time.RFC3339, event.ExpireAt)
-	if err != nil {
-		return fmt.Errorf("invalid expire_at format: %v", err)
-	}
-	if time.Now().Before(expireTime) {
-		log.Printf("TTL not expired yet for segment %s (user_id: %d)", event.Segment, event.UserID)
-		return nil
-	}
-
-	if err := s.DeleteUserSegment(event.UserID, models.Slug(event.Segment)); err != nil {
-		return fmt.Errorf("failed to delete expired segment: %v", err)
-	}
-
-	log.Printf("Successfully deleted expired segment %s for user %d", event.Segment, event.UserID)
-	return nil
-}
+package services
+
+import (
+	"API/internal/audit"
+	"API/internal/logging"
+	"API/internal/metrics"
+	"API/internal/models"
+	"API/internal/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+//go:generate mockery --name=IUserSegmentService --output=mocks --outpkg=mocks
+type IUserSegmentService interface {
+	GetUserSegments(ctx context.Context, userID int64) (models.UserSegments, error)
+	GetAllUserSegments(ctx context.Context) ([]models.UserSegment, error)
+	UpdateUserSegments(ctx context.Context, userID int64, slugsToAdd, slugsToDelete []models.Slug, ttl *time.Time, mode models.UpdateMode) (models.UpdateSegmentsResult, error)
+	DeleteUserSegment(ctx context.Context, userID int64, slug models.Slug) error
+	GetSegmentUsers(ctx context.Context, slug models.Slug, cursor int64, limit int, includeTTL bool) (models.SegmentUsers, error)
+	CountSegmentUsers(ctx context.Context, slug models.Slug) (models.SegmentUsers, error)
+}
+
+type UserSegmentService struct {
+	Repo       repository.UserSegmentRepository
+	Segments   repository.SegmentRepository
+	Replies    repository.EventQueue
+	ReplyTopic string
+	Logger     *slog.Logger
+}
+
+func NewUserSegmentService(repo repository.UserSegmentRepository, segments repository.SegmentRepository, replies repository.EventQueue, replyTopic string, logger *slog.Logger) *UserSegmentService {
+	return &UserSegmentService{
+		Repo:       repo,
+		Segments:   segments,
+		Replies:    replies,
+		ReplyTopic: replyTopic,
+		Logger:     logger,
+	}
+}
+
+func (s *UserSegmentService) GetUserSegments(ctx context.Context, userID int64) (models.UserSegments, error) {
+	return s.Repo.GetUserSegmentsDВ(ctx, userID)
+}
+
+func (s *UserSegmentService) GetAllUserSegments(ctx context.Context) ([]models.UserSegment, error) {
+	return s.Repo.GetAllUserSegmentsDB(ctx)
+}
+
+// GetSegmentUsers returns one page of the segment members. NextCursor is set
+// only when the page is full and more members may follow.
+func (s *UserSegmentService) GetSegmentUsers(ctx context.Context, slug models.Slug, cursor int64, limit int, includeTTL bool) (models.SegmentUsers, error) {
+	members, err := s.Repo.GetSegmentUsersDB(ctx, slug, cursor, limit)
+	if err != nil {
+		return models.SegmentUsers{}, err
+	}
+
+	if !includeTTL {
+		for i := range members {
+			members[i].TTL = nil
+		}
+	}
+
+	result := models.SegmentUsers{
+		Slug:  slug,
+		Users: members,
+	}
+	if len(members) == limit {
+		next := members[len(members)-1].UserID
+		result.NextCursor = &next
+	}
+	return result, nil
+}
+
+func (s *UserSegmentService) CountSegmentUsers(ctx context.Context, slug models.Slug) (models.SegmentUsers, error) {
+	count, err := s.Repo.CountSegmentUsersDB(ctx, slug)
+	if err != nil {
+		return models.SegmentUsers{}, err
+	}
+	return models.SegmentUsers{
+		Slug:  slug,
+		Count: &count,
+	}, nil
+}
+
+// UpdateUserSegments applies the membership change if the calling principal
+// may write every segment involved and returns the outcome of every slug.
+// Kafka events are written to the outbox in the same transaction and
+// published by the OutboxRelay.
+func (s *UserSegmentService) UpdateUserSegments(ctx context.Context, userID int64, slugsToAdd, slugsToDelete []models.Slug, ttl *time.Time, mode models.UpdateMode) (models.UpdateSegmentsResult, error) {
+	slugs := append(slices.Clip(slugsToAdd), slugsToDelete...)
+	if err := authorizeSegments(ctx, s.Segments, slugs, models.SegmentACL.CanWrite); err != nil {
+		return models.UpdateSegmentsResult{}, err
+	}
+	return s.Repo.UpdateUserSegments(ctx, slugsToAdd, slugsToDelete, userID, ttl, mode)
+}
+
+func (s *UserSegmentService) DeleteUserSegment(ctx context.Context, userID int64, slug models.Slug) error {
+	if err := s.Repo.DeleteUserSegment(ctx, userID, slug); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ProcessCommandMessage applies a membership command from the command topic
+// with the same validation, TTL and history semantics as PATCH /user_segments
+// and enqueues a reply keyed by the request ID. Invalid commands and unknown
+// users or segments are answered with an error reply; other failures are
+// returned so the consumer retries the message.
+func (s *UserSegmentService) ProcessCommandMessage(ctx context.Context, message *sarama.ConsumerMessage) error {
+	var cmd models.MembershipCommand
+	if err := json.Unmarshal(message.Value, &cmd); err != nil {
+		s.Logger.WarnContext(ctx, "Failed to parse membership command", "error", err)
+		return s.reply(ctx, cmd, nil, fmt.Errorf("invalid command: %w", err))
+	}
+
+	// The command request ID correlates the change when the message did not
+	// carry a request_id header.
+	if logging.RequestID(ctx) == "" && cmd.RequestID != "" {
+		ctx = logging.WithRequestID(ctx, cmd.RequestID)
+	}
+	ctx = audit.WithSource(ctx, models.SourceKafka)
+	if cmd.Reason != "" {
+		ctx = audit.WithReason(ctx, cmd.Reason)
+	}
+	s.Logger.InfoContext(ctx, "Processing membership command",
+		"user_id", cmd.UserID, "add", cmd.AddSegments, "delete", cmd.DeleteSegments,
+		"action", cmd.Action, "segment", cmd.Segment)
+
+	if err := cmd.Normalize(); err != nil {
+		return s.reply(ctx, cmd, nil, err)
+	}
+	if err := cmd.Validate(); err != nil {
+		return s.reply(ctx, cmd, nil, err)
+	}
+
+	ttl, err := cmd.ParseTTL()
+	if err != nil {
+		return s.reply(ctx, cmd, nil, err)
+	}
+
+	result, err := s.UpdateUserSegments(ctx, cmd.UserID, cmd.AddSegments, cmd.DeleteSegments, ttl, cmd.Mode)
+	if err != nil && !isRejected(err) {
+		return err
+	}
+	return s.reply(ctx, cmd, result.Results, err)
+}
+
+// reply enqueues the acknowledgement of cmd. Commands without a request ID
+// are not acknowledged.
+func (s *UserSegmentService) reply(ctx context.Context, cmd models.MembershipCommand, results []models.SlugOutcome, cmdErr error) error {
+	if cmd.RequestID == "" {
+		if cmdErr != nil {
+			s.Logger.WarnContext(ctx, "Membership command without request ID failed", "error", cmdErr)
+		}
+		return nil
+	}
+
+	event, err := models.NewCommandReplyEvent(s.ReplyTopic, cmd, results, cmdErr)
+	if err != nil {
+		return err
+	}
+	return s.Replies.Enqueue(ctx, []models.OutboxEvent{event})
+}
+
+// isRejected reports whether err is final for the command, so it is answered
+// instead of retried.
+func isRejected(err error) bool {
+	return errors.Is(err, models.ErrUserNotFound) || errors.Is(err, models.ErrSegmentNotFound) ||
+		errors.Is(err, models.ErrMembershipRejected)
+}
+
+func (s *UserSegmentService) ProcessTTLExpiryMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	var event struct {
+		UserID   int64  `json:"user_id"`
+		Segment  string `json:"segment"`
+		ExpireAt string `json:"ttl"`
+	}
+
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return fmt.Errorf("failed to parse Kafka message: %v", err)
+	}
+
+	expireTime, err := time.Parse(time.RFC3339, event.ExpireAt)
+	if err != nil {
+		return fmt.Errorf("invalid expire_at format: %v", err)
+	}
+	if time.Now().Before(expireTime) {
+		s.Logger.DebugContext(ctx, "TTL not expired yet", "segment", event.Segment, "user_id", event.UserID)
+		return nil
+	}
+
+	ctx = audit.WithReason(audit.WithSource(ctx, models.SourceTTL), models.ReasonExpired)
+	if err := s.DeleteUserSegment(ctx, event.UserID, models.Slug(event.Segment)); err != nil {
+		return fmt.Errorf("failed to delete expired segment: %v", err)
+	}
+	metrics.TTLExpirations.WithLabelValues("kafka").Inc()
+
+	s.Logger.InfoContext(ctx, "Deleted expired segment", "segment", event.Segment, "user_id", event.UserID)
+	return nil
+}
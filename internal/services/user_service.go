@@ -1,33 +1,44 @@
-package services
-
-import (
-	"API/internal/models"
-	"API/internal/repository"
-)
-
-//go:generate mockery --name=IUserService --output=mocks --outpkg=mocks
-type IUserService interface {
-	GetAllUsers() ([]models.Users, error)
-	CreateUser(user *models.Users) error
-	DeleteUser(userID int64) error
-}
-
-type UserService struct {
-	Repo repository.UserRepository
-}
-
-func NewUserService(repo repository.UserRepository) *UserService {
-	return &UserService{Repo: repo}
-}
-
-func (s *UserService) GetAllUsers() ([]models.Users, error) {
-	return s.Repo.GetAllUsersDB()
-}
-
-func (s *UserService) CreateUser(user *models.Users) error {
-	return s.Repo.CreateUserDB(user)
-}
-
-func (s *UserService) DeleteUser(userID int64) error {
-	return s.Repo.DeleteUserDB(userID)
-}
+package services
+
+import (
+	"API/internal/models"
+	"API/internal/repository"
+	"context"
+	"fmt"
+)
+
+//go:generate mockery --name=IUserService --output=mocks --outpkg=mocks
+type IUserService interface {
+	GetAllUsers(ctx context.Context) ([]models.Users, error)
+	CreateUser(ctx context.Context, user *models.Users) error
+	DeleteUser(ctx context.Context, userID int64) error
+}
+
+type UserService struct {
+	Repo            repository.UserRepository
+	UserSegmentRepo repository.UserSegmentRepository
+}
+
+func NewUserService(repo repository.UserRepository, userSegmentRepo repository.UserSegmentRepository) *UserService {
+	return &UserService{
+		Repo:            repo,
+		UserSegmentRepo: userSegmentRepo,
+	}
+}
+
+func (s *UserService) GetAllUsers(ctx context.Context) ([]models.Users, error) {
+	return s.Repo.GetAllUsersDB(ctx)
+}
+
+// CreateUser creates the user together with its memberships of the segments
+// with auto_percent; neither is kept when the other fails.
+func (s *UserService) CreateUser(ctx context.Context, user *models.Users) error {
+	if _, err := s.UserSegmentRepo.CreateAutoEnrolledUser(ctx, user); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+func (s *UserService) DeleteUser(ctx context.Context, userID int64) error {
+	return s.Repo.DeleteUserDB(ctx, userID)
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"API/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalReportStorage keeps reports as files in Dir, which are downloaded from
+// this service under BaseURL with links signed by Signer. Every replica has
+// its own Dir, so it only suits a single replica or a shared volume.
+type LocalReportStorage struct {
+	Dir     string
+	BaseURL string
+	Signer  URLSigner
+}
+
+func NewLocalReportStorage(dir, baseURL string, signer URLSigner) *LocalReportStorage {
+	return &LocalReportStorage{Dir: dir, BaseURL: baseURL, Signer: signer}
+}
+
+// Save writes the report to a temporary file that is renamed to name once it
+// is complete, so a report is never served half-written. Dir is created when
+// it does not exist.
+func (s *LocalReportStorage) Save(_ context.Context, name string, write func(w io.Writer) error) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(s.Dir, "."+name+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write report file: %w", err)
+	}
+	if err := os.Rename(file.Name(), filepath.Join(s.Dir, name)); err != nil {
+		return fmt.Errorf("failed to store report file: %w", err)
+	}
+	return nil
+}
+
+// URL returns the path of the report on this service with a signature that
+// expires after Signer.TTL.
+func (s *LocalReportStorage) URL(_ context.Context, name string) (string, error) {
+	return path.Join(s.BaseURL, name) + "?" + s.Signer.Sign(name, time.Now()).Encode(), nil
+}
+
+// Open returns the report name when query is a valid signature of it. It
+// returns ErrInvalidReportLink for a missing, wrong or expired signature and
+// ErrReportNotFound when the report does not exist.
+func (s *LocalReportStorage) Open(name string, query url.Values) (*os.File, error) {
+	if err := s.Signer.Verify(name, query, time.Now()); err != nil {
+		return nil, err
+	}
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, models.ErrReportNotFound
+	}
+
+	file, err := os.Open(filepath.Join(s.Dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, models.ErrReportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open report file: %w", err)
+	}
+	return file, nil
+}
+
+// DeleteBefore deletes the files of Dir last modified before t, including
+// temporary files left behind by a crash.
+func (s *LocalReportStorage) DeleteBefore(_ context.Context, t time.Time) (int, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read report directory: %w", err)
+	}
+
+	deleted := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to stat report file: %w", err)
+		}
+		if !info.ModTime().Before(t) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.Dir, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return deleted, fmt.Errorf("failed to delete report file: %w", err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"API/internal/models"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalReportStorage_Save(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "reports")
+	reports := NewLocalReportStorage(dir, "/reports", URLSigner{Key: []byte("secret"), TTL: time.Hour})
+
+	err := reports.Save(context.Background(), "report.csv", func(w io.Writer) error {
+		_, err := io.WriteString(w, "a,b\n")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "report.csv")); err != nil || string(content) != "a,b\n" {
+		t.Errorf("Expected the report to be stored, got %q, %v", content, err)
+	}
+
+	failure := errors.New("query failed")
+	err = reports.Save(context.Background(), "broken.csv", func(w io.Writer) error {
+		io.WriteString(w, "a,b\n")
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected the error of write, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected only the complete report to be left, got %d files", len(entries))
+	}
+}
+
+func TestLocalReportStorage_Open(t *testing.T) {
+	dir := t.TempDir()
+	reports := NewLocalReportStorage(dir, "/reports", URLSigner{Key: []byte("secret"), TTL: time.Hour})
+	if err := os.WriteFile(filepath.Join(dir, "report.csv"), []byte("a,b\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+
+	link, _ := reports.URL(context.Background(), "report.csv")
+	path, rawQuery, _ := strings.Cut(link, "?")
+	if path != "/reports/report.csv" {
+		t.Errorf("Expected /reports/report.csv, got %s", path)
+	}
+	query, _ := url.ParseQuery(rawQuery)
+
+	file, err := reports.Open("report.csv", query)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	file.Close()
+
+	if _, err := reports.Open("other.csv", query); !errors.Is(err, models.ErrInvalidReportLink) {
+		t.Errorf("Expected ErrInvalidReportLink for another report, got %v", err)
+	}
+	if _, err := reports.Open("report.csv", url.Values{}); !errors.Is(err, models.ErrInvalidReportLink) {
+		t.Errorf("Expected ErrInvalidReportLink without a signature, got %v", err)
+	}
+	expired := URLSigner{Key: []byte("secret"), TTL: -time.Minute}.Sign("report.csv", time.Now())
+	if _, err := reports.Open("report.csv", expired); !errors.Is(err, models.ErrInvalidReportLink) {
+		t.Errorf("Expected ErrInvalidReportLink for an expired link, got %v", err)
+	}
+	missing := reports.Signer.Sign("missing.csv", time.Now())
+	if _, err := reports.Open("missing.csv", missing); !errors.Is(err, models.ErrReportNotFound) {
+		t.Errorf("Expected ErrReportNotFound, got %v", err)
+	}
+}
+
+func TestLocalReportStorage_DeleteBefore(t *testing.T) {
+	dir := t.TempDir()
+	reports := NewLocalReportStorage(dir, "/reports", URLSigner{Key: []byte("secret"), TTL: time.Hour})
+	for _, name := range []string{"old.csv", ".new.csv.123", "new.csv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatalf("Failed to write report: %v", err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(filepath.Join(dir, "old.csv"), old, old)
+	os.Chtimes(filepath.Join(dir, ".new.csv.123"), old, old)
+
+	deleted, err := reports.DeleteBefore(context.Background(), time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 deleted files, got %d", deleted)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || entries[0].Name() != "new.csv" {
+		t.Errorf("Expected only new.csv to be left, got %v", entries)
+	}
+}
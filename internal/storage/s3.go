@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"API/internal/config"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3EmptyPayload    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	s3DateFormat      = "20060102T150405Z"
+)
+
+// S3ReportStorage keeps reports as objects under Prefix in Bucket of an
+// S3-compatible service at Endpoint. Requests are signed with Signature
+// Version 4, and download links are presigned for URLExpiry so reports are
+// downloaded from the service directly.
+type S3ReportStorage struct {
+	Endpoint  *url.URL
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+	URLExpiry time.Duration
+	Client    *http.Client
+}
+
+func NewS3ReportStorage(cfg config.S3Config, urlExpiry time.Duration) (*S3ReportStorage, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("S3 bucket is required")
+	}
+	return &S3ReportStorage{
+		Endpoint:  endpoint,
+		Region:    cfg.Region,
+		Bucket:    cfg.Bucket,
+		Prefix:    cfg.Prefix,
+		AccessKey: cfg.AccessKey,
+		SecretKey: cfg.SecretKey,
+		PathStyle: cfg.PathStyle,
+		URLExpiry: urlExpiry,
+		Client:    &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// Save spools the report to a temporary file to learn its length and hash,
+// which a single PUT needs, and uploads it. A report that fails to be written
+// is never uploaded.
+func (s *S3ReportStorage) Save(ctx context.Context, name string, write func(w io.Writer) error) error {
+	file, err := os.CreateTemp("", "report-*.csv")
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	hash := sha256.New()
+	if err := write(io.MultiWriter(file, hash)); err != nil {
+		return err
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to write report file: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to write report file: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(s.Prefix+name).String(), io.NopCloser(file))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	resp, err := s.do(req, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return fmt.Errorf("failed to upload report: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// URL returns a link to the object of the report presigned for URLExpiry.
+func (s *S3ReportStorage) URL(_ context.Context, name string) (string, error) {
+	return s.presign(name, time.Now()), nil
+}
+
+// DeleteBefore lists the objects under Prefix and deletes those last
+// modified before t.
+func (s *S3ReportStorage) DeleteBefore(ctx context.Context, t time.Time) (int, error) {
+	deleted := 0
+	token := ""
+	for {
+		page, err := s.list(ctx, token)
+		if err != nil {
+			return deleted, err
+		}
+		for _, object := range page.Contents {
+			if !object.LastModified.Before(t) {
+				continue
+			}
+			if err := s.delete(ctx, object.Key); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+		if !page.IsTruncated {
+			return deleted, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+func (s *S3ReportStorage) list(ctx context.Context, token string) (s3ListResult, error) {
+	query := url.Values{"list-type": {"2"}, "prefix": {s.Prefix}}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+	bucket := s.objectURL("")
+	bucket.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bucket.String(), nil)
+	if err != nil {
+		return s3ListResult{}, err
+	}
+	resp, err := s.do(req, s3EmptyPayload)
+	if err != nil {
+		return s3ListResult{}, fmt.Errorf("failed to list reports: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result s3ListResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return s3ListResult{}, fmt.Errorf("failed to decode report list: %w", err)
+	}
+	return result, nil
+}
+
+func (s *S3ReportStorage) delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, s3EmptyPayload)
+	if err != nil {
+		return fmt.Errorf("failed to delete report %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do signs req with the SHA-256 of its body and sends it. A response other
+// than 2xx is returned as an error with the start of its body.
+func (s *S3ReportStorage) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash, time.Now())
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("S3 responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// objectURL returns the URL of the object key, or of the bucket when key is
+// empty.
+func (s *S3ReportStorage) objectURL(key string) *url.URL {
+	u := *s.Endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+	if s.PathStyle {
+		u.Path = base + "/" + s.Bucket + "/" + key
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = base + "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	return &u
+}
+
+// sign adds the Authorization header of Signature Version 4 to req, covering
+// the host, the payload hash and the date.
+func (s *S3ReportStorage) sign(req *http.Request, payloadHash string, now time.Time) {
+	date := now.UTC().Format(s3DateFormat)
+	req.Header.Set("X-Amz-Date", date)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	headers := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + date + "\n"
+	signature := s.signature(now, req.Method, req.URL.EscapedPath(), req.URL.RawQuery, headers, signedHeaders, payloadHash)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.AccessKey, s.scope(now), signedHeaders, signature))
+}
+
+// presign returns a GET link to the object of the report name that is valid
+// for URLExpiry from now.
+func (s *S3ReportStorage) presign(name string, now time.Time) string {
+	u := s.objectURL(s.Prefix + name)
+	query := url.Values{
+		"X-Amz-Algorithm":     {s3Algorithm},
+		"X-Amz-Credential":    {s.AccessKey + "/" + s.scope(now)},
+		"X-Amz-Date":          {now.UTC().Format(s3DateFormat)},
+		"X-Amz-Expires":       {strconv.FormatInt(int64(s.URLExpiry/time.Second), 10)},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	signature := s.signature(now, http.MethodGet, u.EscapedPath(), u.RawQuery, "host:"+u.Host+"\n", "host", s3UnsignedPayload)
+	u.RawQuery += "&X-Amz-Signature=" + signature
+	return u.String()
+}
+
+func (s *S3ReportStorage) scope(now time.Time) string {
+	return now.UTC().Format("20060102") + "/" + s.Region + "/s3/aws4_request"
+}
+
+// signature returns the Signature Version 4 of a request described by its
+// canonical parts; query must already be canonical.
+func (s *S3ReportStorage) signature(now time.Time, method, path, query, headers, signedHeaders, payloadHash string) string {
+	canonical := strings.Join([]string{method, path, query, headers, signedHeaders, payloadHash}, "\n")
+	digest := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{s3Algorithm, now.UTC().Format(s3DateFormat), s.scope(now), hex.EncodeToString(digest[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), now.UTC().Format("20060102"))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by name the way Signature Version 4
+// expects it.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes every byte of s except unreserved characters,
+// and slashes unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
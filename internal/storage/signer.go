@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"API/internal/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// URLSigner signs download links of reports with an HMAC of the report name
+// and the expiry, so a link cannot be made up from the name of a report and
+// stops working TTL after it is signed.
+type URLSigner struct {
+	Key []byte
+	TTL time.Duration
+}
+
+// Sign returns the query parameters that make a link to name valid until
+// now plus TTL.
+func (s URLSigner) Sign(name string, now time.Time) url.Values {
+	expires := strconv.FormatInt(now.Add(s.TTL).Unix(), 10)
+	return url.Values{
+		"expires":   {expires},
+		"signature": {s.signature(name, expires)},
+	}
+}
+
+// Verify returns ErrInvalidReportLink unless query holds a signature of name
+// that has not expired at now.
+func (s URLSigner) Verify(name string, query url.Values, now time.Time) error {
+	expires := query.Get("expires")
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > unix {
+		return models.ErrInvalidReportLink
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(query.Get("signature"))
+	if err != nil || !hmac.Equal(signature, s.mac(name, expires)) {
+		return models.ErrInvalidReportLink
+	}
+	return nil
+}
+
+func (s URLSigner) signature(name, expires string) string {
+	return base64.RawURLEncoding.EncodeToString(s.mac(name, expires))
+}
+
+func (s URLSigner) mac(name, expires string) []byte {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(name + "\n" + expires))
+	return mac.Sum(nil)
+}
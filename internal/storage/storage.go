@@ -0,0 +1,46 @@
+// Package storage keeps generated reports until they are downloaded.
+package storage
+
+import (
+	"API/internal/config"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ReportStorage stores generated reports under a file name and tells where
+// they can be downloaded from.
+type ReportStorage interface {
+	// Save stores the report name with the content written by write. A
+	// report that could not be written completely is not stored.
+	Save(ctx context.Context, name string, write func(w io.Writer) error) error
+	// URL returns a download link of the report name that expires. It is
+	// absolute, or a path on this service.
+	URL(ctx context.Context, name string) (string, error)
+	// DeleteBefore deletes the reports stored before t and returns how many
+	// were deleted.
+	DeleteBefore(ctx context.Context, t time.Time) (int, error)
+}
+
+// LocalURLPrefix is the path on this service local reports are downloaded from.
+const LocalURLPrefix = "/reports"
+
+// NewReportStorage returns the storage selected by cfg.Storage.
+func NewReportStorage(cfg config.ReportsConfig) (ReportStorage, error) {
+	switch cfg.Storage {
+	case "local":
+		key := []byte(cfg.SigningKey)
+		if len(key) == 0 {
+			key = make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, fmt.Errorf("failed to generate signing key: %w", err)
+			}
+		}
+		return NewLocalReportStorage(cfg.Dir, LocalURLPrefix, URLSigner{Key: key, TTL: cfg.URLExpiry}), nil
+	case "s3":
+		return NewS3ReportStorage(cfg.S3, cfg.URLExpiry)
+	}
+	return nil, fmt.Errorf("unknown report storage %q", cfg.Storage)
+}
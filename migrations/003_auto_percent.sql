@@ -0,0 +1,2 @@
+ALTER TABLE segments ADD COLUMN auto_percent NUMERIC(5, 2) NULL
+    CHECK (auto_percent > 0 AND auto_percent <= 100);
\ No newline at end of file
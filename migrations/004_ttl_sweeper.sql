@@ -0,0 +1,3 @@
+ALTER TABLE user_segments_history ADD COLUMN reason TEXT NULL;
+
+CREATE INDEX idx_user_segments_ttl ON user_segments(ttl) WHERE ttl IS NOT NULL;
\ No newline at end of file
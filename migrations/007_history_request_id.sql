@@ -0,0 +1 @@
+ALTER TABLE user_segments_history ADD COLUMN request_id TEXT NULL;
\ No newline at end of file
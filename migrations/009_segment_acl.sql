@@ -0,0 +1,9 @@
+ALTER TABLE segments ADD COLUMN owner TEXT NULL;
+
+CREATE TABLE IF NOT EXISTS segment_writers(
+    segment_id BIGINT NOT NULL REFERENCES segments(id) ON DELETE CASCADE,
+    principal TEXT NOT NULL,
+    PRIMARY KEY (segment_id, principal)
+);
+
+ALTER TABLE user_segments_history ADD COLUMN actor TEXT NULL;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE user_segments_history ADD COLUMN source TEXT NULL;
+
+UPDATE user_segments_history SET source = 'ttl' WHERE reason = 'expired';
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE segments ADD COLUMN archived_at TIMESTAMPTZ NULL;
+
+CREATE INDEX IF NOT EXISTS idx_segments_archived_at ON segments (archived_at) WHERE archived_at IS NOT NULL;
\ No newline at end of file
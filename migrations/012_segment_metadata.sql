@@ -0,0 +1,11 @@
+ALTER TABLE segments
+    ADD COLUMN description TEXT NULL,
+    ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN active_from TIMESTAMPTZ NULL,
+    ADD COLUMN active_until TIMESTAMPTZ NULL,
+    ADD CONSTRAINT segments_active_period CHECK (active_from IS NULL OR active_until IS NULL OR active_from < active_until);
+
+CREATE INDEX IF NOT EXISTS idx_segments_tags ON segments USING GIN (tags);
+CREATE INDEX IF NOT EXISTS idx_segments_owner ON segments (owner);
\ No newline at end of file
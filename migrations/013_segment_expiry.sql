@@ -0,0 +1,5 @@
+ALTER TABLE segments
+    ADD COLUMN expires_at TIMESTAMPTZ NULL,
+    ADD COLUMN expiry_action TEXT NOT NULL DEFAULT 'archive' CHECK (expiry_action IN ('archive', 'delete'));
+
+CREATE INDEX IF NOT EXISTS idx_segments_expires_at ON segments (expires_at) WHERE expires_at IS NOT NULL AND archived_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS bulk_jobs(
+    id BIGSERIAL PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+    add_segments TEXT[] NOT NULL DEFAULT '{}',
+    delete_segments TEXT[] NOT NULL DEFAULT '{}',
+    ttl TIMESTAMP NULL,
+    reason TEXT NULL,
+    actor TEXT NULL,
+    request_id TEXT NULL,
+    total_rows BIGINT NOT NULL DEFAULT 0,
+    processed_rows BIGINT NOT NULL DEFAULT 0,
+    failed_rows BIGINT NOT NULL DEFAULT 0,
+    error TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_bulk_jobs_unfinished ON bulk_jobs (id) WHERE status IN ('pending', 'running');
+
+CREATE TABLE IF NOT EXISTS bulk_job_rows(
+    job_id BIGINT NOT NULL REFERENCES bulk_jobs (id) ON DELETE CASCADE,
+    row_number BIGINT NOT NULL,
+    user_id BIGINT NULL,
+    error TEXT NULL,
+    PRIMARY KEY (job_id, row_number)
+);
\ No newline at end of file
@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS jobs(
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'failed', 'cancelled')),
+    progress DOUBLE PRECISION NOT NULL DEFAULT 0,
+    result JSONB NULL,
+    error TEXT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 3,
+    cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+    actor TEXT NULL,
+    request_id TEXT NULL,
+    run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_by TEXT NULL,
+    locked_until TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP NULL,
+    finished_at TIMESTAMP NULL
+);
+
+-- Workers claim queued jobs that are due and running jobs whose lease expired.
+CREATE INDEX IF NOT EXISTS idx_jobs_runnable ON jobs (run_at, id) WHERE status IN ('queued', 'running');
+CREATE INDEX IF NOT EXISTS idx_jobs_finished_at ON jobs (finished_at) WHERE finished_at IS NOT NULL;
+
+ALTER TABLE bulk_jobs ADD COLUMN IF NOT EXISTS job_id BIGINT NULL UNIQUE REFERENCES jobs (id) ON DELETE SET NULL;
+
+-- Bulk jobs were applied by their own worker before; queue the unfinished ones.
+WITH unfinished AS (
+    SELECT id, actor, request_id, created_at
+    FROM bulk_jobs
+    WHERE status IN ('pending', 'running')
+    AND job_id IS NULL
+), queued AS (
+    INSERT INTO jobs (kind, payload, actor, request_id, run_at, created_at)
+    SELECT 'bulk_membership', json_build_object('bulk_job_id', id), actor, request_id, NOW(), created_at
+    FROM unfinished
+    ORDER BY id
+    RETURNING id, (payload->>'bulk_job_id')::BIGINT AS bulk_job_id
+)
+UPDATE bulk_jobs b
+SET job_id = q.id
+FROM queued q
+WHERE b.id = q.bulk_job_id;
\ No newline at end of file
@@ -0,0 +1,24 @@
+-- Bulk jobs and their rows are deleted with their job after jobs.retention.
+ALTER TABLE bulk_jobs DROP CONSTRAINT IF EXISTS bulk_jobs_job_id_fkey;
+ALTER TABLE bulk_jobs ADD CONSTRAINT bulk_jobs_job_id_fkey FOREIGN KEY (job_id) REFERENCES jobs (id) ON DELETE CASCADE;
+
+-- Bulk jobs that finished before they were run as jobs get a finished job,
+-- so they are deleted after the retention as well.
+WITH finished AS (
+    SELECT id, status, error, actor, request_id, created_at, updated_at, finished_at
+    FROM bulk_jobs
+    WHERE status IN ('done', 'failed')
+    AND job_id IS NULL
+), adopted AS (
+    INSERT INTO jobs (kind, payload, status, progress, error, actor, request_id, created_at, updated_at, finished_at)
+    SELECT 'bulk_membership', json_build_object('bulk_job_id', id),
+        CASE WHEN status = 'done' THEN 'succeeded' ELSE 'failed' END, 100, error, actor, request_id,
+        created_at, updated_at, COALESCE(finished_at, updated_at)
+    FROM finished
+    ORDER BY id
+    RETURNING id, (payload->>'bulk_job_id')::BIGINT AS bulk_job_id
+)
+UPDATE bulk_jobs b
+SET job_id = a.id
+FROM adopted a
+WHERE b.id = a.bulk_job_id;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- Jobs run with the teams and scopes their submitter had, not only its name.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS actor_teams TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS actor_scopes TEXT[] NOT NULL DEFAULT '{}';
\ No newline at end of file
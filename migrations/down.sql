@@ -1,4 +1,10 @@
-DROP TABLE IF EXISTS user_segments_history;
-DROP TABLE IF EXISTS user_segments;
-DROP TABLE IF EXISTS segments;
+DROP TABLE IF EXISTS jobs CASCADE;
+DROP TABLE IF EXISTS bulk_job_rows;
+DROP TABLE IF EXISTS bulk_jobs;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS outbox;
+DROP TABLE IF EXISTS user_segments_history;
+DROP TABLE IF EXISTS segment_writers;
+DROP TABLE IF EXISTS user_segments;
+DROP TABLE IF EXISTS segments;
 DROP TABLE IF EXISTS users;
\ No newline at end of file